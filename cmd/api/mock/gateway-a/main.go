@@ -2,11 +2,18 @@ package main
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,8 +21,65 @@ import (
 
 // Struct for JSON requests and responses
 type Request struct {
-	Amount      float64 `json:"amount"`
-	CallbackURL string  `json:"callback_url"`
+	MerchantReference string       `json:"merchant_reference"`
+	Amount            float64      `json:"amount"`
+	Currency          string       `json:"currency"`
+	CallbackURL       string       `json:"callback_url"`
+	Card              *Card        `json:"card"`
+	BankAccount       *BankAccount `json:"bank_account"`
+}
+
+type Card struct {
+	Number   string `json:"number"`
+	ExpMonth int    `json:"exp_month"`
+	ExpYear  int    `json:"exp_year"`
+	CVC      string `json:"cvc"`
+}
+
+type BankAccount struct {
+	AccountNumber   string `json:"account_number"`
+	RoutingCode     string `json:"routing_code"`
+	RoutingCodeType string `json:"routing_code_type"`
+}
+
+// validateCard checks the card of a deposit can be charged
+func validateCard(card *Card) error {
+	if card == nil {
+		return errors.New("card is required")
+	}
+	if card.Number == "" {
+		return errors.New("card.number is required")
+	}
+	if card.ExpMonth < 1 || card.ExpMonth > 12 {
+		return errors.New("card.exp_month is invalid")
+	}
+	now := time.Now()
+	if card.ExpYear < now.Year() || (card.ExpYear == now.Year() && card.ExpMonth < int(now.Month())) {
+		return errors.New("card is expired")
+	}
+	if len(card.CVC) < 3 || len(card.CVC) > 4 {
+		return errors.New("card.cvc is invalid")
+	}
+	return nil
+}
+
+// validateBankAccount checks the bank account of a withdrawal can be paid out to
+func validateBankAccount(account *BankAccount) error {
+	if account == nil {
+		return errors.New("bank_account is required")
+	}
+	if account.AccountNumber == "" || account.RoutingCode == "" || account.RoutingCodeType == "" {
+		return errors.New("bank_account.account_number, routing_code and routing_code_type are required")
+	}
+	return nil
+}
+
+type RefundRequest struct {
+	MerchantReference string  `json:"merchant_reference"`
+	ReferenceID       string  `json:"reference_id"`
+	Amount            float64 `json:"amount"`
+	Currency          string  `json:"currency"`
+	CallbackURL       string  `json:"callback_url"`
 }
 
 type Response struct {
@@ -24,6 +88,61 @@ type Response struct {
 	ReferenceID string `json:"id"`
 }
 
+// payments keeps the status of every payment by reference ID, for the /status endpoint
+var (
+	paymentsMu sync.Mutex
+	payments   = map[string]string{}
+)
+
+func setStatus(referenceID, status string) {
+	paymentsMu.Lock()
+	defer paymentsMu.Unlock()
+	payments[referenceID] = status
+}
+
+func getStatus(referenceID string) (string, bool) {
+	paymentsMu.Lock()
+	defer paymentsMu.Unlock()
+	status, ok := payments[referenceID]
+	return status, ok
+}
+
+// references keeps the first response for every merchant reference, so a retried
+// request does not create a second payment
+var references = map[string]Response{}
+
+// merchantReference returns the key deduping a request: the Idempotency-Key header,
+// or the merchant reference of the body
+func merchantReference(r *http.Request, reference string) string {
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		return key
+	}
+	return reference
+}
+
+// reserveReference stores the response of a new payment, or returns the original
+// payment when the merchant reference was already used
+func reserveReference(key string, response Response) (Response, bool) {
+	paymentsMu.Lock()
+	defer paymentsMu.Unlock()
+
+	if original, ok := references[key]; ok {
+		original.Status = payments[original.ReferenceID]
+		original.Message = "Duplicate merchant reference"
+		return original, true
+	}
+
+	references[key] = response
+	return response, false
+}
+
+// findReference returns the reference ID of the payment requested with the merchant reference
+func findReference(key string) string {
+	paymentsMu.Lock()
+	defer paymentsMu.Unlock()
+	return references[key].ReferenceID
+}
+
 // Helper function to generate a random reference ID
 func generateReferenceID() string {
 	return uuid.NewString()
@@ -36,6 +155,7 @@ func triggerAsyncCallback(referenceID, callbackURL string, amount float64) {
 	if validateTransactionAmount(amount) {
 		status = "success"
 	}
+	setStatus(referenceID, status)
 	log.Printf("Sending async callback for reference ID: %s with status: %s to %s\n", referenceID, status, callbackURL)
 
 	callbackPayload := Response{
@@ -45,7 +165,15 @@ func triggerAsyncCallback(referenceID, callbackURL string, amount float64) {
 	}
 
 	jsonData, _ := json.Marshal(callbackPayload)
-	resp, err := http.Post(callbackURL, "application/json", io.NopCloser(bytes.NewReader(jsonData)))
+	req, err := http.NewRequest(http.MethodPost, callbackURL, bytes.NewReader(jsonData))
+	if err != nil {
+		log.Printf("Failed to create callback: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signCallback(req, jsonData)
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Printf("Failed to send callback: %v", err)
 		return
@@ -56,6 +184,21 @@ func triggerAsyncCallback(referenceID, callbackURL string, amount float64) {
 	log.Printf("Callback response: %s", body)
 }
 
+// callbackSecret is the secret shared with the wallet service to sign callbacks
+var callbackSecret = os.Getenv("GATEWAY_A_CALLBACK_SECRET")
+
+// signCallback sets the HMAC-SHA256 signature of "<timestamp>.<body>" on the callback
+func signCallback(req *http.Request, body []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(callbackSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	req.Header.Set("X-Signature-Timestamp", timestamp)
+	req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+}
+
 // Validate the transaction based on the amount
 func validateTransactionAmount(amount float64) bool {
 	return int(amount)%2 == 0
@@ -66,6 +209,17 @@ func deposit(w http.ResponseWriter, r *http.Request) {
 	var request Request
 	json.Unmarshal(body, &request)
 
+	key := merchantReference(r, request.MerchantReference)
+	if key == "" {
+		http.Error(w, "merchant_reference is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateCard(request.Card); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	referenceID := generateReferenceID()
 
 	response := Response{
@@ -74,6 +228,12 @@ func deposit(w http.ResponseWriter, r *http.Request) {
 		ReferenceID: referenceID,
 	}
 
+	if original, ok := reserveReference(key, response); ok {
+		renderDuplicate(w, original)
+		return
+	}
+	setStatus(referenceID, "pending")
+
 	renderResponse(w, response)
 
 	go triggerAsyncCallback(referenceID, request.CallbackURL, request.Amount)
@@ -85,6 +245,17 @@ func withdrawal(w http.ResponseWriter, r *http.Request) {
 	var request Request
 	json.Unmarshal(body, &request)
 
+	key := merchantReference(r, request.MerchantReference)
+	if key == "" {
+		http.Error(w, "merchant_reference is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateBankAccount(request.BankAccount); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	referenceID := generateReferenceID()
 
 	response := Response{
@@ -92,11 +263,79 @@ func withdrawal(w http.ResponseWriter, r *http.Request) {
 		Message:     "Withdrawal is being processed in Gateway A",
 		ReferenceID: referenceID,
 	}
+	if original, ok := reserveReference(key, response); ok {
+		renderDuplicate(w, original)
+		return
+	}
+	setStatus(referenceID, "pending")
+
+	renderResponse(w, response)
+
+	go triggerAsyncCallback(referenceID, request.CallbackURL, request.Amount)
+}
+
+// refund simulates a refund of a previous payment
+func refund(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var request RefundRequest
+	json.Unmarshal(body, &request)
+
+	if request.ReferenceID == "" {
+		http.Error(w, "reference_id is required", http.StatusBadRequest)
+		return
+	}
+
+	key := merchantReference(r, request.MerchantReference)
+	if key == "" {
+		http.Error(w, "merchant_reference is required", http.StatusBadRequest)
+		return
+	}
+
+	referenceID := generateReferenceID()
+
+	response := Response{
+		Status:      "pending",
+		Message:     "Refund is being processed in Gateway A",
+		ReferenceID: referenceID,
+	}
+	if original, ok := reserveReference(key, response); ok {
+		renderDuplicate(w, original)
+		return
+	}
+	setStatus(referenceID, "pending")
+
 	renderResponse(w, response)
 
 	go triggerAsyncCallback(referenceID, request.CallbackURL, request.Amount)
 }
 
+// status reports the current status of a payment, by reference ID or merchant reference
+func status(w http.ResponseWriter, r *http.Request) {
+	referenceID := r.URL.Query().Get("id")
+	if reference := r.URL.Query().Get("merchant_reference"); reference != "" {
+		referenceID = findReference(reference)
+	}
+	status, ok := getStatus(referenceID)
+	if !ok {
+		http.Error(w, "payment not found", http.StatusNotFound)
+		return
+	}
+
+	response := Response{
+		Status:      status,
+		Message:     "Transaction status in Gateway A",
+		ReferenceID: referenceID,
+	}
+	renderResponse(w, response)
+}
+
+// renderDuplicate answers a request reusing a merchant reference with the original payment
+func renderDuplicate(w http.ResponseWriter, res Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(res)
+}
+
 func renderResponse(w http.ResponseWriter, res interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -105,8 +344,14 @@ func renderResponse(w http.ResponseWriter, res interface{}) {
 
 // Main function to set up routes and start the server
 func main() {
+	if callbackSecret == "" {
+		log.Fatal("GATEWAY_A_CALLBACK_SECRET is required")
+	}
+
 	http.HandleFunc("/deposit", deposit)
 	http.HandleFunc("/withdrawal", withdrawal)
+	http.HandleFunc("/refund", refund)
+	http.HandleFunc("/status", status)
 
 	fmt.Println("Mock server Gateway A running on port 8090...")
 	log.Fatal(http.ListenAndServe(":8090", nil))
@@ -2,11 +2,18 @@ package main
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/3bd-dev/wallet-service/pkg/web"
@@ -15,9 +22,72 @@ import (
 
 // Struct for SOAP requests and responses
 type Request struct {
-	XMLName     xml.Name `xml:"Envelope"`
-	Amount      float64  `xml:"Body>amount"`
-	CallbackURL string   `xml:"Body>callback_url"`
+	XMLName           xml.Name     `xml:"Envelope"`
+	MerchantReference string       `xml:"Body>merchant_reference"`
+	Amount            float64      `xml:"Body>amount"`
+	Currency          string       `xml:"Body>currency"`
+	CallbackURL       string       `xml:"Body>callback_url"`
+	Card              *Card        `xml:"Body>card"`
+	Beneficiary       *Beneficiary `xml:"Body>beneficiary"`
+}
+
+type Card struct {
+	PAN        string `xml:"pan"`
+	ExpiryDate string `xml:"expiry_date"`
+	CVV        string `xml:"cvv"`
+}
+
+type Beneficiary struct {
+	Account      string `xml:"account"`
+	BankCode     string `xml:"bank_code"`
+	BankCodeType string `xml:"bank_code_type"`
+}
+
+// validateCard checks the card of a deposit can be charged
+func validateCard(card *Card) error {
+	if card == nil {
+		return errors.New("card is required")
+	}
+	if card.PAN == "" {
+		return errors.New("card.pan is required")
+	}
+	expiry, err := time.Parse("0106", card.ExpiryDate)
+	if err != nil {
+		return errors.New("card.expiry_date must be MMYY")
+	}
+	if expiry.AddDate(0, 1, 0).Before(time.Now()) {
+		return errors.New("card is expired")
+	}
+	if len(card.CVV) != 3 {
+		return errors.New("card.cvv is invalid")
+	}
+	return nil
+}
+
+// validateBeneficiary checks the beneficiary of a withdrawal can be paid out to
+func validateBeneficiary(beneficiary *Beneficiary) error {
+	if beneficiary == nil {
+		return errors.New("beneficiary is required")
+	}
+	if beneficiary.Account == "" || beneficiary.BankCode == "" || beneficiary.BankCodeType == "" {
+		return errors.New("beneficiary.account, bank_code and bank_code_type are required")
+	}
+	return nil
+}
+
+type RefundRequest struct {
+	XMLName           xml.Name `xml:"Envelope"`
+	MerchantReference string   `xml:"Body>merchant_reference"`
+	ReferenceID       string   `xml:"Body>reference_id"`
+	Amount            float64  `xml:"Body>amount"`
+	Currency          string   `xml:"Body>currency"`
+	CallbackURL       string   `xml:"Body>callback_url"`
+}
+
+type StatusRequest struct {
+	XMLName           xml.Name `xml:"Envelope"`
+	ReferenceID       string   `xml:"Body>reference_id"`
+	MerchantReference string   `xml:"Body>merchant_reference"`
 }
 
 type Response struct {
@@ -27,6 +97,61 @@ type Response struct {
 	ReferenceID string   `xml:"SOAP-ENV:Body>id"`
 }
 
+// payments keeps the status of every payment by reference ID, for the /status endpoint
+var (
+	paymentsMu sync.Mutex
+	payments   = map[string]string{}
+)
+
+func setStatus(referenceID, status string) {
+	paymentsMu.Lock()
+	defer paymentsMu.Unlock()
+	payments[referenceID] = status
+}
+
+func getStatus(referenceID string) (string, bool) {
+	paymentsMu.Lock()
+	defer paymentsMu.Unlock()
+	status, ok := payments[referenceID]
+	return status, ok
+}
+
+// references keeps the first response for every merchant reference, so a retried
+// request does not create a second payment
+var references = map[string]Response{}
+
+// merchantReference returns the key deduping a request: the Idempotency-Key header,
+// or the merchant reference of the body
+func merchantReference(r *http.Request, reference string) string {
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		return key
+	}
+	return reference
+}
+
+// reserveReference stores the response of a new payment, or returns the original
+// payment when the merchant reference was already used
+func reserveReference(key string, response Response) (Response, bool) {
+	paymentsMu.Lock()
+	defer paymentsMu.Unlock()
+
+	if original, ok := references[key]; ok {
+		original.Status = payments[original.ReferenceID]
+		original.Message = "Duplicate merchant reference"
+		return original, true
+	}
+
+	references[key] = response
+	return response, false
+}
+
+// findReference returns the reference ID of the payment requested with the merchant reference
+func findReference(key string) string {
+	paymentsMu.Lock()
+	defer paymentsMu.Unlock()
+	return references[key].ReferenceID
+}
+
 // Helper function to generate a random reference ID
 func generateReferenceID() string {
 	return uuid.NewString()
@@ -39,6 +164,7 @@ func triggerAsyncCallback(referenceID, callbackURL string, amount float64) {
 	if validateTransactionAmount(amount) {
 		status = "success"
 	}
+	setStatus(referenceID, status)
 	log.Printf("Sending async callback for reference ID: %s with status: %s to %s\n", referenceID, status, callbackURL)
 	callbackPayload := Response{
 		Status:      status,
@@ -47,7 +173,15 @@ func triggerAsyncCallback(referenceID, callbackURL string, amount float64) {
 	}
 
 	xmlData, _ := xml.Marshal(callbackPayload)
-	resp, err := http.Post(callbackURL, "application/xml", io.NopCloser(bytes.NewReader(xmlData)))
+	req, err := http.NewRequest(http.MethodPost, callbackURL, bytes.NewReader(xmlData))
+	if err != nil {
+		log.Printf("Failed to create callback: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	signCallback(req, xmlData)
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Printf("Failed to send callback: %v", err)
 		return
@@ -58,6 +192,21 @@ func triggerAsyncCallback(referenceID, callbackURL string, amount float64) {
 	log.Printf("Callback response: %s - %s", body, callbackPayload)
 }
 
+// callbackSecret is the secret shared with the wallet service to sign callbacks
+var callbackSecret = os.Getenv("GATEWAY_B_CALLBACK_SECRET")
+
+// signCallback sets the HMAC-SHA256 signature of "<timestamp>.<body>" on the callback
+func signCallback(req *http.Request, body []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(callbackSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	req.Header.Set("X-Signature-Timestamp", timestamp)
+	req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+}
+
 // Validate the transaction based on the amount
 func validateTransactionAmount(amount float64) bool {
 	return int(amount)%2 == 0
@@ -72,6 +221,18 @@ func deposit(w http.ResponseWriter, r *http.Request) {
 		web.RenderErr(w, err)
 		return
 	}
+
+	key := merchantReference(r, request.MerchantReference)
+	if key == "" {
+		http.Error(w, "merchant_reference is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateCard(request.Card); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	referenceID := generateReferenceID()
 
 	// Initial response with pending status
@@ -81,6 +242,12 @@ func deposit(w http.ResponseWriter, r *http.Request) {
 		ReferenceID: referenceID,
 	}
 
+	if original, ok := reserveReference(key, response); ok {
+		renderDuplicate(w, original)
+		return
+	}
+	setStatus(referenceID, "pending")
+
 	renderResponse(w, response)
 
 	// Trigger async callback after a delay
@@ -97,6 +264,17 @@ func withdrawal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	key := merchantReference(r, request.MerchantReference)
+	if key == "" {
+		http.Error(w, "merchant_reference is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateBeneficiary(request.Beneficiary); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	referenceID := generateReferenceID()
 
 	response := Response{
@@ -105,11 +283,94 @@ func withdrawal(w http.ResponseWriter, r *http.Request) {
 		ReferenceID: referenceID,
 	}
 
+	if original, ok := reserveReference(key, response); ok {
+		renderDuplicate(w, original)
+		return
+	}
+	setStatus(referenceID, "pending")
+
 	renderResponse(w, response)
 
 	go triggerAsyncCallback(referenceID, request.CallbackURL, request.Amount)
 }
 
+// refund to simulate a refund of a previous payment
+func refund(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var request RefundRequest
+	err := xml.Unmarshal(body, &request)
+	if err != nil {
+		web.RenderErr(w, err)
+		return
+	}
+
+	if request.ReferenceID == "" {
+		http.Error(w, "reference_id is required", http.StatusBadRequest)
+		return
+	}
+
+	key := merchantReference(r, request.MerchantReference)
+	if key == "" {
+		http.Error(w, "merchant_reference is required", http.StatusBadRequest)
+		return
+	}
+
+	referenceID := generateReferenceID()
+
+	response := Response{
+		Status:      "pending",
+		Message:     "Refund is being processed in Gateway B",
+		ReferenceID: referenceID,
+	}
+
+	if original, ok := reserveReference(key, response); ok {
+		renderDuplicate(w, original)
+		return
+	}
+	setStatus(referenceID, "pending")
+
+	renderResponse(w, response)
+
+	go triggerAsyncCallback(referenceID, request.CallbackURL, request.Amount)
+}
+
+// status to report the current status of a payment, by reference ID or merchant reference
+func status(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var request StatusRequest
+	err := xml.Unmarshal(body, &request)
+	if err != nil {
+		web.RenderErr(w, err)
+		return
+	}
+
+	referenceID := request.ReferenceID
+	if request.MerchantReference != "" {
+		referenceID = findReference(request.MerchantReference)
+	}
+
+	status, ok := getStatus(referenceID)
+	if !ok {
+		http.Error(w, "payment not found", http.StatusNotFound)
+		return
+	}
+
+	response := Response{
+		Status:      status,
+		Message:     "Transaction status in Gateway B",
+		ReferenceID: referenceID,
+	}
+
+	renderResponse(w, response)
+}
+
+// renderDuplicate answers a request reusing a merchant reference with the original payment
+func renderDuplicate(w http.ResponseWriter, res Response) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusConflict)
+	xml.NewEncoder(w).Encode(res)
+}
+
 func renderResponse(w http.ResponseWriter, res interface{}) {
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(http.StatusOK)
@@ -118,8 +379,14 @@ func renderResponse(w http.ResponseWriter, res interface{}) {
 
 // Main function to set up routes and start the server
 func main() {
+	if callbackSecret == "" {
+		log.Fatal("GATEWAY_B_CALLBACK_SECRET is required")
+	}
+
 	http.HandleFunc("/deposit", deposit)
 	http.HandleFunc("/withdraw", withdrawal)
+	http.HandleFunc("/refund", refund)
+	http.HandleFunc("/status", status)
 
 	fmt.Println("Mock server Gateway B running on port 8091...")
 	log.Fatal(http.ListenAndServe(":8091", nil))
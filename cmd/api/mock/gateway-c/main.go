@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Struct for JSON requests and responses, amounts are in minor units
+type Request struct {
+	Reference   string       `json:"reference"`
+	Amount      int64        `json:"amount"`
+	Currency    string       `json:"currency"`
+	WebhookURL  string       `json:"webhook_url"`
+	Card        *Card        `json:"card"`
+	Beneficiary *Beneficiary `json:"beneficiary"`
+}
+
+type Card struct {
+	Number      string `json:"number"`
+	ExpiryMonth int    `json:"expiry_month"`
+	ExpiryYear  int    `json:"expiry_year"`
+	CVC         string `json:"cvc"`
+}
+
+type Beneficiary struct {
+	AccountNumber string `json:"account_number"`
+	BankCode      string `json:"bank_code"`
+	BankCodeType  string `json:"bank_code_type"`
+}
+
+// validateCard checks the card of a payment can be charged
+func validateCard(card *Card) error {
+	if card == nil {
+		return errors.New("card is required")
+	}
+	if card.Number == "" {
+		return errors.New("card.number is required")
+	}
+	if card.ExpiryMonth < 1 || card.ExpiryMonth > 12 {
+		return errors.New("card.expiry_month is invalid")
+	}
+	now := time.Now()
+	if card.ExpiryYear < now.Year() || (card.ExpiryYear == now.Year() && card.ExpiryMonth < int(now.Month())) {
+		return errors.New("card is expired")
+	}
+	if len(card.CVC) < 3 || len(card.CVC) > 4 {
+		return errors.New("card.cvc is invalid")
+	}
+	return nil
+}
+
+// validateBeneficiary checks the beneficiary of a payout can be paid out to
+func validateBeneficiary(beneficiary *Beneficiary) error {
+	if beneficiary == nil {
+		return errors.New("beneficiary is required")
+	}
+	if beneficiary.AccountNumber == "" || beneficiary.BankCode == "" || beneficiary.BankCodeType == "" {
+		return errors.New("beneficiary.account_number, bank_code and bank_code_type are required")
+	}
+	return nil
+}
+
+type RefundRequest struct {
+	Reference  string `json:"reference"`
+	PaymentID  string `json:"payment_id"`
+	Amount     int64  `json:"amount"`
+	Currency   string `json:"currency"`
+	WebhookURL string `json:"webhook_url"`
+}
+
+type Transaction struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+type Event struct {
+	ID   string      `json:"id"`
+	Type string      `json:"type"`
+	Data Transaction `json:"data"`
+}
+
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type Error struct {
+	Error string `json:"error"`
+}
+
+// tokenTTL is the lifetime of the issued access tokens
+const tokenTTL = 15 * time.Minute
+
+var (
+	clientID     = os.Getenv("GATEWAY_C_CLIENT_ID")
+	clientSecret = os.Getenv("GATEWAY_C_CLIENT_SECRET")
+	// callbackSecret is the secret shared with the wallet service to sign webhook events
+	callbackSecret = os.Getenv("GATEWAY_C_CALLBACK_SECRET")
+)
+
+// tokens keeps the expiry of every issued access token
+var (
+	tokensMu sync.Mutex
+	tokens   = map[string]time.Time{}
+)
+
+// token issues access tokens with the OAuth2 client credentials grant
+func token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+		renderError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	id, secret, ok := r.BasicAuth()
+	if !ok || subtle.ConstantTimeCompare([]byte(id), []byte(clientID)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(secret), []byte(clientSecret)) != 1 {
+		renderError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	b := make([]byte, 32)
+	rand.Read(b)
+	accessToken := hex.EncodeToString(b)
+
+	tokensMu.Lock()
+	tokens[accessToken] = time.Now().Add(tokenTTL)
+	tokensMu.Unlock()
+
+	renderJSON(w, http.StatusOK, Token{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(tokenTTL.Seconds()),
+	})
+}
+
+// authorized rejects requests without a valid bearer token
+func authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accessToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			renderError(w, http.StatusUnauthorized, "missing access token")
+			return
+		}
+
+		tokensMu.Lock()
+		expiry, found := tokens[accessToken]
+		tokensMu.Unlock()
+
+		if !found || time.Now().After(expiry) {
+			renderError(w, http.StatusUnauthorized, "invalid access token")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// transactions keeps the status of every transaction by ID, for the transactions endpoint
+var (
+	transactionsMu sync.Mutex
+	transactions   = map[string]string{}
+)
+
+func setStatus(id, status string) {
+	transactionsMu.Lock()
+	defer transactionsMu.Unlock()
+	transactions[id] = status
+}
+
+func getStatus(id string) (string, bool) {
+	transactionsMu.Lock()
+	defer transactionsMu.Unlock()
+	status, ok := transactions[id]
+	return status, ok
+}
+
+// idempotencyKeys keeps the transaction created for every idempotency key, so a
+// retried request does not create a second transaction
+var idempotencyKeys = map[string]string{}
+
+// reserveKey stores the transaction created for a new idempotency key, or returns the
+// original transaction when the key was already used
+func reserveKey(key, id string) (Transaction, bool) {
+	transactionsMu.Lock()
+	defer transactionsMu.Unlock()
+
+	if original, ok := idempotencyKeys[key]; ok {
+		return Transaction{ID: original, Status: transactions[original]}, true
+	}
+
+	idempotencyKeys[key] = id
+	transactions[id] = "processing"
+	return Transaction{ID: id, Status: "processing"}, false
+}
+
+// create answers a request creating a transaction, and sends its webhook event later
+func create(w http.ResponseWriter, r *http.Request, webhookURL string, amount int64) {
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" {
+		renderError(w, http.StatusBadRequest, "Idempotency-Key header is required")
+		return
+	}
+
+	tran, duplicate := reserveKey(key, uuid.NewString())
+	if duplicate {
+		renderJSON(w, http.StatusOK, tran)
+		return
+	}
+
+	renderJSON(w, http.StatusCreated, tran)
+
+	go triggerWebhook(tran.ID, webhookURL, amount)
+}
+
+// payment simulates a card payment
+func payment(w http.ResponseWriter, r *http.Request) {
+	var request Request
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		renderError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := validateCard(request.Card); err != nil {
+		renderError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	create(w, r, request.WebhookURL, request.Amount)
+}
+
+// payout simulates a bank payout
+func payout(w http.ResponseWriter, r *http.Request) {
+	var request Request
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		renderError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := validateBeneficiary(request.Beneficiary); err != nil {
+		renderError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	create(w, r, request.WebhookURL, request.Amount)
+}
+
+// refund simulates a refund of a previous payment
+func refund(w http.ResponseWriter, r *http.Request) {
+	var request RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		renderError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if request.PaymentID == "" {
+		renderError(w, http.StatusUnprocessableEntity, "payment_id is required")
+		return
+	}
+
+	create(w, r, request.WebhookURL, request.Amount)
+}
+
+// transaction reports the current status of a transaction
+func transaction(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	status, ok := getStatus(id)
+	if !ok {
+		renderError(w, http.StatusNotFound, "transaction not found")
+		return
+	}
+
+	renderJSON(w, http.StatusOK, Transaction{ID: id, Status: status})
+}
+
+// transactionByKey reports the current status of the transaction created with an idempotency key
+func transactionByKey(w http.ResponseWriter, r *http.Request) {
+	transactionsMu.Lock()
+	id, ok := idempotencyKeys[r.URL.Query().Get("reference")]
+	status := transactions[id]
+	transactionsMu.Unlock()
+
+	if !ok {
+		renderError(w, http.StatusNotFound, "transaction not found")
+		return
+	}
+
+	renderJSON(w, http.StatusOK, Transaction{ID: id, Status: status})
+}
+
+// Async webhook function to simulate delayed processing
+func triggerWebhook(id, webhookURL string, amount int64) {
+	time.Sleep(5 * time.Second)
+	status := "declined"
+	if validateTransactionAmount(amount) {
+		status = "succeeded"
+	}
+	setStatus(id, status)
+	log.Printf("Sending webhook event for transaction: %s with status: %s to %s\n", id, status, webhookURL)
+
+	event := Event{
+		ID:   uuid.NewString(),
+		Type: "transaction.updated",
+		Data: Transaction{ID: id, Status: status},
+	}
+
+	jsonData, _ := json.Marshal(event)
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(jsonData))
+	if err != nil {
+		log.Printf("Failed to create webhook event: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signWebhook(req, jsonData)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("Failed to send webhook event: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	log.Printf("Webhook response: %s", body)
+}
+
+// signWebhook sets the HMAC-SHA256 signature of "<timestamp>.<body>" on the webhook event
+func signWebhook(req *http.Request, body []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(callbackSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	req.Header.Set("Gateway-C-Signature", fmt.Sprintf("t=%s,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil))))
+}
+
+// Validate the transaction based on the amount in minor units
+func validateTransactionAmount(amount int64) bool {
+	return amount%2 == 0
+}
+
+func renderError(w http.ResponseWriter, code int, message string) {
+	renderJSON(w, code, Error{Error: message})
+}
+
+func renderJSON(w http.ResponseWriter, code int, res interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(res)
+}
+
+// Main function to set up routes and start the server
+func main() {
+	if clientID == "" || clientSecret == "" {
+		log.Fatal("GATEWAY_C_CLIENT_ID and GATEWAY_C_CLIENT_SECRET are required")
+	}
+	if callbackSecret == "" {
+		log.Fatal("GATEWAY_C_CALLBACK_SECRET is required")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /oauth/token", token)
+	mux.HandleFunc("POST /v1/payments", authorized(payment))
+	mux.HandleFunc("POST /v1/payouts", authorized(payout))
+	mux.HandleFunc("POST /v1/refunds", authorized(refund))
+	mux.HandleFunc("GET /v1/transactions", authorized(transactionByKey))
+	mux.HandleFunc("GET /v1/transactions/{id}", authorized(transaction))
+
+	fmt.Println("Mock server Gateway C running on port 8092...")
+	log.Fatal(http.ListenAndServe(":8092", mux))
+}
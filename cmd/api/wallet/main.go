@@ -10,18 +10,26 @@ import (
 	"syscall"
 
 	"github.com/3bd-dev/wallet-service/config"
+	"github.com/3bd-dev/wallet-service/internal/handlers/adminapi"
 	"github.com/3bd-dev/wallet-service/internal/handlers/checkapi"
+	"github.com/3bd-dev/wallet-service/internal/handlers/gatewayapi"
 	"github.com/3bd-dev/wallet-service/internal/handlers/walletapi"
+	"github.com/3bd-dev/wallet-service/internal/handlers/webhookapi"
 	"github.com/3bd-dev/wallet-service/internal/models"
 	"github.com/3bd-dev/wallet-service/internal/payment"
 	"github.com/3bd-dev/wallet-service/internal/payment/gateways/gatewaya"
 	"github.com/3bd-dev/wallet-service/internal/payment/gateways/gatewayb"
+	"github.com/3bd-dev/wallet-service/internal/payment/gateways/gatewayc"
+	"github.com/3bd-dev/wallet-service/internal/payment/gateways/generic"
 	"github.com/3bd-dev/wallet-service/internal/repos/postgres"
 	"github.com/3bd-dev/wallet-service/internal/services/wallet"
 	"github.com/3bd-dev/wallet-service/internal/web/mid"
 	"github.com/3bd-dev/wallet-service/pkg/database"
 	"github.com/3bd-dev/wallet-service/pkg/database/psql"
 	"github.com/3bd-dev/wallet-service/pkg/logger"
+	"github.com/3bd-dev/wallet-service/pkg/queue"
+	"github.com/3bd-dev/wallet-service/pkg/secret"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
@@ -64,19 +72,81 @@ func run(ctx context.Context, log *logger.Logger) error {
 	// Repository setup
 	walletRepo := postgres.NewWalletRepo(db)
 	transactionRepo := postgres.NewTransactionRepo(db)
+	historyRepo := postgres.NewTransactionHistoryRepo(db)
+	ledgerRepo := postgres.NewLedgerRepo(db)
+	holdRepo := postgres.NewHoldRepo(db)
+	idempotencyRepo := postgres.NewIdempotencyRepo(db, cfg.Idempotency.KeyTTL)
+	callbackRepo := postgres.NewCallbackRepo(db)
+	transactor := postgres.NewTransactor(db)
+
+	// Queue setup
+	tranQueue := queue.NewPostgres[wallet.QueueItem](db, queue.PostgresConfig{
+		Name:         "transactions",
+		PollInterval: cfg.Queue.PollInterval,
+		LeaseTimeout: cfg.Queue.LeaseTimeout,
+		Pool: queue.PoolConfig{
+			Workers: cfg.Queue.Workers,
+			Limits:  cfg.Queue.GatewayLimits,
+		},
+		Retry: queue.RetryPolicy{
+			MaxAttempts: cfg.Queue.MaxAttempts,
+			BaseDelay:   cfg.Queue.RetryBaseDelay,
+			MaxDelay:    cfg.Queue.RetryMaxDelay,
+		},
+		// card jobs are processed by the replica holding their security code in memory.
+		Replica: uuid.NewString(),
+		Log:     log,
+	})
 
 	// Payment gateway setup
 	paymentGateways := map[models.PaymentGateway]payment.PaymentGateway{
 		models.PaymentGatewayA: gatewaya.New(cfg.PaymentGatewayConfig.GatewayA),
 		models.PaymentGatewayB: gatewayb.New(cfg.PaymentGatewayConfig.GatewayB),
+		models.PaymentGatewayC: gatewayc.New(cfg.PaymentGatewayConfig.GatewayC),
+	}
+	if cfg.PaymentGatewayConfig.GatewaysFile != "" {
+		gateways, err := generic.Load(cfg.PaymentGatewayConfig.GatewaysFile)
+		if err != nil {
+			return fmt.Errorf("failed to load payment gateways: %w", err)
+		}
+
+		for _, gatewayCfg := range gateways {
+			if _, ok := paymentGateways[gatewayCfg.Name]; ok {
+				return fmt.Errorf("payment gateway %s is already registered", gatewayCfg.Name)
+			}
+
+			gateway, err := generic.New(gatewayCfg)
+			if err != nil {
+				return fmt.Errorf("failed to initialize payment gateway: %w", err)
+			}
+			paymentGateways[gatewayCfg.Name] = gateway
+		}
 	}
 
 	// Payment handler setup
 	paymentHandler := payment.New(paymentGateways)
+	paymentRouter := payment.NewRouter(paymentHandler, payment.RouterConfig{
+		Weights: cfg.PaymentGatewayConfig.RoutingWeights,
+	})
+	if cfg.PaymentGatewayConfig.Failover {
+		paymentHandler.EnableFailover(paymentRouter)
+	}
+
+	// Payment details encryption setup
+	detailsBox, err := secret.NewBox(cfg.PaymentGatewayConfig.DetailsKey)
+	if err != nil {
+		return fmt.Errorf("failed to initialize payment details encryption: %w", err)
+	}
 
 	// Wallet service setup
-	walletService := wallet.NewService(log, walletRepo, transactionRepo, paymentHandler, cfg.PaymentGatewayConfig.CallbackPattern)
+	walletService := wallet.NewService(log, walletRepo, transactionRepo, historyRepo, ledgerRepo, holdRepo, idempotencyRepo, callbackRepo, transactor, tranQueue, paymentHandler, paymentRouter, detailsBox, cfg.PaymentGatewayConfig.SecurityCodeTTL, cfg.Idempotency.HashKey, cfg.PaymentGatewayConfig.CallbackPattern, cfg.PaymentGatewayConfig.WebhookPattern)
 	walletService.Start(ctx)
+	walletService.StartReconciler(ctx, wallet.ReconcilerConfig{
+		Interval:     cfg.Reconciler.Interval,
+		CreatedAfter: cfg.Reconciler.CreatedAfter,
+		PendingAfter: cfg.Reconciler.PendingAfter,
+		BatchSize:    cfg.Reconciler.BatchSize,
+	})
 
 	// HTTP server setup
 	httpmux := mux.NewRouter()
@@ -92,6 +162,19 @@ func run(ctx context.Context, log *logger.Logger) error {
 		Service: walletService,
 	})
 
+	adminapi.Routes(httpmux, adminapi.Config{
+		Service: walletService,
+		Token:   cfg.Admin.Token,
+	})
+
+	webhookapi.Routes(httpmux, webhookapi.Config{
+		Service: walletService,
+	})
+
+	gatewayapi.Routes(httpmux, gatewayapi.Config{
+		Service: walletService,
+	})
+
 	// swagger setup
 	if cfg.Service.Environment == "development" {
 		serveSwagger(httpmux)
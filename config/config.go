@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
+	"strings"
 	"time"
 
+	"github.com/3bd-dev/wallet-service/pkg/money"
 	"github.com/kelseyhightower/envconfig"
 )
 
@@ -40,11 +43,44 @@ type Database struct {
 	MaxIdleConns int    `envconfig:"DATABASE_MAX_IDLE_CONNECTIONS" default:"5"`
 }
 
+// Queue contains configuration for the Postgres backed transaction queue.
+type Queue struct {
+	PollInterval   time.Duration  `envconfig:"QUEUE_POLL_INTERVAL" default:"5s"`                                   // Delay between polls for jobs enqueued by other replicas
+	LeaseTimeout   time.Duration  `envconfig:"QUEUE_LEASE_TIMEOUT" default:"5m"`                                   // Time a claimed job stays invisible to other workers
+	Workers        int            `envconfig:"QUEUE_WORKERS" default:"4"`                                          // Number of transactions processed concurrently
+	GatewayLimits  map[string]int `envconfig:"QUEUE_GATEWAY_LIMITS" default:"gateway_a:2,gateway_b:2,gateway_c:2"` // Max transactions processed concurrently per payment gateway
+	MaxAttempts    int            `envconfig:"QUEUE_MAX_ATTEMPTS" default:"5"`                                     // Attempts before a transaction is dead-lettered
+	RetryBaseDelay time.Duration  `envconfig:"QUEUE_RETRY_BASE_DELAY" default:"10s"`                               // Delay before the first retry, doubled on every retry
+	RetryMaxDelay  time.Duration  `envconfig:"QUEUE_RETRY_MAX_DELAY" default:"10m"`                                // Max delay between retries
+}
+
+// Idempotency contains configuration for the idempotency keys of requests.
+type Idempotency struct {
+	KeyTTL  time.Duration `envconfig:"IDEMPOTENCY_KEY_TTL" default:"24h"`    // Time an idempotency key is kept before it can be used again
+	HashKey string        `envconfig:"IDEMPOTENCY_HASH_KEY" required:"true"` // Secret keying the hashes of the requests stored with their idempotency keys
+}
+
+// Admin contains configuration for the admin API.
+type Admin struct {
+	Token string `envconfig:"ADMIN_API_TOKEN" required:"true"` // Bearer token required by the admin endpoints
+}
+
+// Reconciler contains configuration for the recovery of stuck transactions.
+type Reconciler struct {
+	Interval     time.Duration `envconfig:"RECONCILER_INTERVAL" default:"1m"`       // Time between reconciliation runs
+	CreatedAfter time.Duration `envconfig:"RECONCILER_CREATED_AFTER" default:"15m"` // Age of a created transaction to be considered stuck
+	PendingAfter time.Duration `envconfig:"RECONCILER_PENDING_AFTER" default:"5m"`  // Time without callback before a pending transaction status is polled
+	BatchSize    int           `envconfig:"RECONCILER_BATCH_SIZE" default:"100"`    // Max transactions handled per status and run
+}
+
 type Service struct {
 	Version     string `envconfig:"SERVICE_VERSION" default:""`
 	Environment string `envconfig:"SERVICE_ENVIRONMENT" required:"true"`
 }
 
+// Amounts maps currency codes to amounts, e.g. USD:1,EUR:1.
+type Amounts map[string]money.Money
+
 type PaymentGatewayA struct {
 	BaseURL                  string        `envconfig:"GATEWAY_A_API_BASE_URL"`
 	RetryAttempt             int           `envconfig:"GATEWAY_A_RETRY_ATTEMPT" default:"3"`   // Number of retry attempts for failed requests
@@ -54,6 +90,10 @@ type PaymentGatewayA struct {
 	CBTimeout                time.Duration `envconfig:"GATEWAY_A_CB_TIMEOUT" default:"30s"`    // Time to stay open before testing recovery
 	CBMaxConsecutiveFailures uint32        `envconfig:"GATEWAY_A_CB_MAX_CONSECUTIVE_FAILURES" default:"3"`
 	CBMaxTotalFailures       uint32        `envconfig:"GATEWAY_A_CB_MAX_TOTAL_FAILURES" default:"5"`
+	CallbackSecret           string        `envconfig:"GATEWAY_A_CALLBACK_SECRET" required:"true"` // Shared secret signing the callbacks
+	CallbackTolerance        time.Duration `envconfig:"GATEWAY_A_CALLBACK_TOLERANCE" default:"5m"` // Max age of a callback signature timestamp
+	MinAmounts               Amounts       `envconfig:"GATEWAY_A_MIN_AMOUNTS"`                     // Min transaction amount per currency reported in the capabilities
+	MaxAmounts               Amounts       `envconfig:"GATEWAY_A_MAX_AMOUNTS"`                     // Max transaction amount per currency reported in the capabilities
 }
 
 type PaymentGatewayB struct {
@@ -65,14 +105,47 @@ type PaymentGatewayB struct {
 	Timeout                  time.Duration `envconfig:"GATEWAY_B_CB_TIMEOUT" default:"30s"`    // Time to stay open before testing recovery
 	CBMaxConsecutiveFailures uint32        `envconfig:"GATEWAY_B_CB_MAX_CONSECUTIVE_FAILURES" default:"3"`
 	CBMaxTotalFailures       uint32        `envconfig:"GATEWAY_B_CB_MAX_TOTAL_FAILURES" default:"5"`
+	CallbackSecret           string        `envconfig:"GATEWAY_B_CALLBACK_SECRET" required:"true"` // Shared secret signing the callbacks
+	CallbackTolerance        time.Duration `envconfig:"GATEWAY_B_CALLBACK_TOLERANCE" default:"5m"` // Max age of a callback signature timestamp
+	MinAmounts               Amounts       `envconfig:"GATEWAY_B_MIN_AMOUNTS"`                     // Min transaction amount per currency reported in the capabilities
+	MaxAmounts               Amounts       `envconfig:"GATEWAY_B_MAX_AMOUNTS"`                     // Max transaction amount per currency reported in the capabilities
+}
+
+type PaymentGatewayC struct {
+	BaseURL                  string        `envconfig:"GATEWAY_C_API_BASE_URL"`
+	TokenURL                 string        `envconfig:"GATEWAY_C_TOKEN_URL"`                   // OAuth2 token endpoint, BaseURL/oauth/token when empty
+	TokenTimeout             time.Duration `envconfig:"GATEWAY_C_TOKEN_TIMEOUT" default:"10s"` // Max duration of a token request, requests wait for it while a token is acquired
+	ClientID                 string        `envconfig:"GATEWAY_C_CLIENT_ID" required:"true"`   // OAuth2 client credentials
+	ClientSecret             string        `envconfig:"GATEWAY_C_CLIENT_SECRET" required:"true"`
+	RetryAttempt             int           `envconfig:"GATEWAY_C_RETRY_ATTEMPT" default:"3"`   // Number of retry attempts for failed requests
+	RetryDelay               time.Duration `envconfig:"GATEWAY_C_RETRY_DELAY" default:"1s"`    // Delay between retries
+	CBMaxRequests            uint32        `envconfig:"GATEWAY_C_CB_MAX_REQUESTS" default:"5"` // Max requests allowed in half-open state
+	CBInterval               time.Duration `envconfig:"GATEWAY_C_CB_INTERVAL" default:"60s"`   // Interval to reset the failure counter
+	CBTimeout                time.Duration `envconfig:"GATEWAY_C_CB_TIMEOUT" default:"30s"`    // Time to stay open before testing recovery
+	CBMaxConsecutiveFailures uint32        `envconfig:"GATEWAY_C_CB_MAX_CONSECUTIVE_FAILURES" default:"3"`
+	CBMaxTotalFailures       uint32        `envconfig:"GATEWAY_C_CB_MAX_TOTAL_FAILURES" default:"5"`
+	CallbackSecret           string        `envconfig:"GATEWAY_C_CALLBACK_SECRET" required:"true"` // Shared secret signing the webhook events
+	CallbackTolerance        time.Duration `envconfig:"GATEWAY_C_CALLBACK_TOLERANCE" default:"5m"` // Max age of a webhook signature timestamp
+	MinAmounts               Amounts       `envconfig:"GATEWAY_C_MIN_AMOUNTS"`                     // Min transaction amount per currency reported in the capabilities
+	MaxAmounts               Amounts       `envconfig:"GATEWAY_C_MAX_AMOUNTS"`                     // Max transaction amount per currency reported in the capabilities
 }
+
 type PaymentGatewayConfig struct {
 	// GatewayA configuration.
 	GatewayA PaymentGatewayA
 
 	// GatewayB configuration.
-	GatewayB        PaymentGatewayB
-	CallbackPattern string `envconfig:"PAYMENT_CALLBACK_PATTERN" required:"true"`
+	GatewayB PaymentGatewayB
+
+	// GatewayC configuration.
+	GatewayC        PaymentGatewayC
+	CallbackPattern string         `envconfig:"PAYMENT_CALLBACK_PATTERN" required:"true"` // Legacy per-transaction callback URL, formatted with the wallet and transaction IDs
+	WebhookPattern  string         `envconfig:"PAYMENT_WEBHOOK_PATTERN"`                  // Gateway webhook URL formatted with the gateway, replaces the callback URL when set
+	RoutingWeights  map[string]int `envconfig:"PAYMENT_ROUTING_WEIGHTS"`                  // Weights of the gateways when routing transactions without a gateway, 1 by default and 0 to disable a gateway
+	Failover        bool           `envconfig:"PAYMENT_FAILOVER" default:"false"`         // Send deposits and withdrawals their gateway could not receive to the next eligible gateway
+	GatewaysFile    string         `envconfig:"PAYMENT_GATEWAYS_FILE"`                    // JSON file describing the gateways integrated through the generic HTTP gateway
+	DetailsKey      string         `envconfig:"PAYMENT_DETAILS_KEY" required:"true"`      // Base64 encoded 32 byte key encrypting the payment details stored until a transaction is sent
+	SecurityCodeTTL time.Duration  `envconfig:"PAYMENT_SECURITY_CODE_TTL" default:"10m"`  // Time the card security code of a transaction is kept in memory, it is never stored
 }
 
 // Config holds all configuration in a struct to make the transition to the
@@ -81,12 +154,23 @@ type Config struct {
 	Service              Service
 	Server               HTTPServer
 	Database             Database
+	Queue                Queue
+	Reconciler           Reconciler
+	Idempotency          Idempotency
+	Admin                Admin
 	PaymentGatewayConfig PaymentGatewayConfig
 }
 
 // Load parses configuration from environment.
 func Load() (Config, error) {
 	cfg := Config{}
-	err := envconfig.Process(envPrefix, &cfg)
-	return cfg, err
+	if err := envconfig.Process(envPrefix, &cfg); err != nil {
+		return cfg, err
+	}
+
+	// required only checks the variable is set, and an empty token would match an empty bearer token.
+	if strings.TrimSpace(cfg.Admin.Token) == "" {
+		return cfg, errors.New("ADMIN_API_TOKEN must not be empty")
+	}
+	return cfg, nil
 }
@@ -0,0 +1,101 @@
+package docs
+
+import (
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/internal/services/wallet"
+	"github.com/3bd-dev/wallet-service/pkg/web"
+	"github.com/google/uuid"
+)
+
+// swagger:route GET /api/v1/admin/dead-letters Admin ListDeadLetters
+// List the queued transactions whose processing failed for good
+// responses:
+//   200: ListDeadLettersResponse
+
+// swagger:response ListDeadLettersResponse
+type ListDeadLettersResponseWrapper struct {
+	// in:body
+	Body struct {
+		web.Response
+		Data []wallet.DeadLetter `json:"data"`
+	}
+}
+
+// swagger:route GET /api/v1/admin/dead-letters/{id} Admin GetDeadLetter
+// Get a dead-lettered transaction
+// responses:
+//   200: GetDeadLetterResponse
+
+// swagger:parameters GetDeadLetter RequeueDeadLetter
+type DeadLetterParamsWrapper struct {
+	// in:path
+	// Required: true
+	ID uuid.UUID `json:"id"`
+}
+
+// swagger:response GetDeadLetterResponse
+type GetDeadLetterResponseWrapper struct {
+	// in:body
+	Body struct {
+		web.Response
+		Data wallet.DeadLetter `json:"data"`
+	}
+}
+
+// swagger:route POST /api/v1/admin/dead-letters/{id}/requeue Admin RequeueDeadLetter
+// Move a dead-lettered transaction back to the queue
+// responses:
+//   204: description: requeued
+
+// swagger:route GET /api/v1/admin/callbacks Admin ListCallbacks
+// List the latest callbacks received from the payment gateways, newest first
+// responses:
+//   200: ListCallbacksResponse
+
+// swagger:parameters ListCallbacks
+type ListCallbacksParamsWrapper struct {
+	// Only list callbacks with this processing result
+	// in:query
+	// enum: received,processed,failed,ignored,needs_review
+	Status string `json:"status"`
+	// Max number of callbacks to list, 100 by default
+	// in:query
+	// minimum: 1
+	// maximum: 1000
+	Limit int `json:"limit"`
+}
+
+// swagger:response ListCallbacksResponse
+type ListCallbacksResponseWrapper struct {
+	// in:body
+	Body struct {
+		web.Response
+		Data []models.GatewayCallback `json:"data"`
+	}
+}
+
+// swagger:route GET /api/v1/admin/callbacks/{id} Admin GetCallback
+// Get a callback received from a payment gateway
+// responses:
+//   200: CallbackResponse
+
+// swagger:route POST /api/v1/admin/callbacks/{id}/reprocess Admin ReprocessCallback
+// Process a stored callback again and return its outcome
+// responses:
+//   200: CallbackResponse
+
+// swagger:parameters GetCallback ReprocessCallback
+type CallbackParamsWrapper struct {
+	// in:path
+	// Required: true
+	ID uuid.UUID `json:"id"`
+}
+
+// swagger:response CallbackResponse
+type CallbackResponseWrapper struct {
+	// in:body
+	Body struct {
+		web.Response
+		Data models.GatewayCallback `json:"data"`
+	}
+}
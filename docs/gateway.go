@@ -0,0 +1,22 @@
+package docs
+
+import (
+	"github.com/3bd-dev/wallet-service/internal/payment"
+	"github.com/3bd-dev/wallet-service/pkg/web"
+)
+
+// swagger:route GET /api/v1/payment-gateways PaymentGateways ListPaymentGateways
+// List the payment gateways with their capabilities and circuit breaker state.
+// Deposits and withdrawals outside the amount limits of a currency are rejected,
+// and a currency without limits omits them.
+// responses:
+//   200: ListPaymentGatewaysResponse
+
+// swagger:response ListPaymentGatewaysResponse
+type ListPaymentGatewaysResponseWrapper struct {
+	// in:body
+	Body struct {
+		web.Response
+		Data []payment.GatewayInfo `json:"data"`
+	}
+}
@@ -30,6 +30,7 @@ type ListWalletsResponseWrapper struct {
 type CreateWalletParamsWrapper struct {
 	// in:body
 	Body struct {
+		request.CreateWallet
 	}
 }
 
@@ -73,6 +74,9 @@ type DepositParamsWrapper struct {
 	// in:path
 	// Required: true
 	ID uuid.UUID `json:"id"`
+	// Key making the request safe to retry, a retry with the same key returns the original transaction
+	// in:header
+	IdempotencyKey string `json:"Idempotency-Key"`
 	// in:body
 	Body struct {
 		request.Deposit
@@ -98,6 +102,9 @@ type WithdrawParamsWrapper struct {
 	// in:path
 	// Required: true
 	ID uuid.UUID `json:"id"`
+	// Key making the request safe to retry, a retry with the same key returns the original transaction
+	// in:header
+	IdempotencyKey string `json:"Idempotency-Key"`
 	// in:body
 	Body struct {
 		request.Withdraw
@@ -113,6 +120,31 @@ type WithdrawResponseWrapper struct {
 	}
 }
 
+// swagger:route Post /api/v1/wallets/{id}/transfers Wallets MakeTransfer
+// Transfer funds from a wallet to another wallet
+// responses:
+//   200: TransferResponse
+
+// swagger:parameters MakeTransfer
+type TransferParamsWrapper struct {
+	// in:path
+	// Required: true
+	ID uuid.UUID `json:"id"`
+	// in:body
+	Body struct {
+		request.Transfer
+	}
+}
+
+// swagger:response TransferResponse
+type TransferResponseWrapper struct {
+	// in:body
+	Body struct {
+		web.Response
+		Data models.Transaction `json:"data"`
+	}
+}
+
 // swagger:route Get /api/v1/wallets/{id}/transactions Transactions ListTransactions
 // List transactions of a wallet by id
 // responses:
@@ -157,3 +189,55 @@ type GetTransactionResponseWrapper struct {
 		Data models.Transaction `json:"data"`
 	}
 }
+
+// swagger:route Get /api/v1/wallets/{id}/transactions/{transaction_id}/history Transactions GetTransactionHistory
+// Get the status history of a transaction
+// responses:
+//   200: GetTransactionHistoryResponse
+
+// swagger:parameters GetTransactionHistory
+type GetTransactionHistoryParamsWrapper struct {
+	// in:path
+	// Required: true
+	ID uuid.UUID `json:"id"`
+	// in:path
+	// Required: true
+	TransactionID uuid.UUID `json:"transaction_id"`
+}
+
+// swagger:response GetTransactionHistoryResponse
+type GetTransactionHistoryResponseWrapper struct {
+	// in:body
+	Body struct {
+		web.Response
+		Data []models.TransactionStatusHistory `json:"data"`
+	}
+}
+
+// swagger:route Post /api/v1/wallets/{id}/transactions/{transaction_id}/refunds Transactions RefundTransaction
+// Refund a completed deposit, fully or partially
+// responses:
+//   200: RefundResponse
+
+// swagger:parameters RefundTransaction
+type RefundParamsWrapper struct {
+	// in:path
+	// Required: true
+	ID uuid.UUID `json:"id"`
+	// in:path
+	// Required: true
+	TransactionID uuid.UUID `json:"transaction_id"`
+	// in:body
+	Body struct {
+		request.Refund
+	}
+}
+
+// swagger:response RefundResponse
+type RefundResponseWrapper struct {
+	// in:body
+	Body struct {
+		web.Response
+		Data models.Transaction `json:"data"`
+	}
+}
@@ -0,0 +1,29 @@
+-- migrate:up
+CREATE TYPE posting_direction AS ENUM ('debit', 'credit');
+
+CREATE TABLE ledger_entries (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4() NOT NULL,  -- Primary key for ledger entry (UUID)
+    transaction_id uuid UNIQUE,  -- Transaction that produced the entry, one entry per transaction (optional)
+    description VARCHAR(255) NOT NULL,  -- Human readable description of the entry
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),  -- When the entry was posted
+    CONSTRAINT fk_transaction FOREIGN KEY (transaction_id) REFERENCES transactions(id)  -- Foreign key constraint
+);
+
+CREATE TABLE ledger_postings (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4() NOT NULL,  -- Primary key for posting (UUID)
+    entry_id uuid NOT NULL,  -- Foreign key to ledger_entries table (UUID)
+    account VARCHAR(255) NOT NULL,  -- Ledger account (e.g., wallet:<id>, gateway:<name>)
+    wallet_id uuid,  -- Wallet owning the account, null for system accounts
+    direction posting_direction NOT NULL,  -- Posting direction (debit/credit)
+    amount DECIMAL(18, 2) NOT NULL CHECK (amount > 0),  -- Posted amount with 2 decimal precision
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),  -- When the posting was created
+    CONSTRAINT fk_entry FOREIGN KEY (entry_id) REFERENCES ledger_entries(id),  -- Foreign key constraint
+    CONSTRAINT fk_wallet FOREIGN KEY (wallet_id) REFERENCES wallets(id)  -- Foreign key constraint
+);
+
+CREATE INDEX idx_ledger_postings_wallet_id ON ledger_postings (wallet_id);
+CREATE INDEX idx_ledger_postings_account ON ledger_postings (account);
+-- migrate:down
+DROP TABLE IF EXISTS ledger_postings;
+DROP TABLE IF EXISTS ledger_entries;
+DROP TYPE IF EXISTS posting_direction;
@@ -0,0 +1,19 @@
+-- migrate:up
+CREATE TYPE hold_status AS ENUM ('active', 'captured', 'released');
+
+CREATE TABLE holds (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4() NOT NULL,  -- Primary key for hold (UUID)
+    wallet_id uuid NOT NULL,  -- Foreign key to wallet table (UUID)
+    transaction_id uuid NOT NULL UNIQUE,  -- Transaction the funds are reserved for, one hold per transaction
+    amount DECIMAL(18, 2) NOT NULL CHECK (amount > 0),  -- Reserved amount with 2 decimal precision
+    status hold_status NOT NULL,  -- Hold status (active/captured/released)
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),  -- When the hold was placed
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),  -- When the hold was last updated
+    CONSTRAINT fk_wallet FOREIGN KEY (wallet_id) REFERENCES wallets(id),  -- Foreign key constraint
+    CONSTRAINT fk_transaction FOREIGN KEY (transaction_id) REFERENCES transactions(id)  -- Foreign key constraint
+);
+
+CREATE INDEX idx_holds_wallet_id_status ON holds (wallet_id, status);
+-- migrate:down
+DROP TABLE IF EXISTS holds;
+DROP TYPE IF EXISTS hold_status;
@@ -0,0 +1,18 @@
+-- migrate:up
+-- Existing rows were all created before currencies were introduced and are treated as USD.
+ALTER TABLE wallets ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';  -- ISO-4217 currency code of the wallet
+ALTER TABLE wallets ALTER COLUMN currency DROP DEFAULT;
+
+ALTER TABLE transactions ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';  -- ISO-4217 currency code of the transaction
+ALTER TABLE transactions ALTER COLUMN currency DROP DEFAULT;
+
+ALTER TABLE ledger_postings ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';  -- ISO-4217 currency code of the posting
+ALTER TABLE ledger_postings ALTER COLUMN currency DROP DEFAULT;
+
+-- Gateway clearing accounts are kept per currency.
+UPDATE ledger_postings SET account = account || ':USD' WHERE wallet_id IS NULL;
+-- migrate:down
+UPDATE ledger_postings SET account = left(account, length(account) - 4) WHERE wallet_id IS NULL;
+ALTER TABLE ledger_postings DROP COLUMN IF EXISTS currency;
+ALTER TABLE transactions DROP COLUMN IF EXISTS currency;
+ALTER TABLE wallets DROP COLUMN IF EXISTS currency;
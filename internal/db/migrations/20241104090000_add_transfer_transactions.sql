@@ -0,0 +1,26 @@
+-- migrate:up transaction:false
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'transfer';
+
+-- Transfers move funds between wallets without a payment gateway.
+ALTER TABLE transactions ALTER COLUMN payment_gateway DROP NOT NULL;
+ALTER TABLE transactions ALTER COLUMN payment_method DROP NOT NULL;
+ALTER TABLE transactions ALTER COLUMN payment_method_details DROP NOT NULL;
+
+-- Direction of the transaction from the wallet's point of view (credit adds funds, debit removes them).
+ALTER TABLE transactions ADD COLUMN direction posting_direction;
+UPDATE transactions SET direction = CASE type WHEN 'deposit' THEN 'credit'::posting_direction ELSE 'debit'::posting_direction END;
+ALTER TABLE transactions ALTER COLUMN direction SET NOT NULL;
+
+-- Linked transaction record, e.g. the other leg of a transfer.
+ALTER TABLE transactions ADD COLUMN related_transaction_id uuid;
+ALTER TABLE transactions ADD CONSTRAINT fk_related_transaction FOREIGN KEY (related_transaction_id) REFERENCES transactions(id) DEFERRABLE INITIALLY DEFERRED;
+-- migrate:down transaction:false
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS fk_related_transaction;
+ALTER TABLE transactions DROP COLUMN IF EXISTS related_transaction_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS direction;
+DELETE FROM ledger_postings WHERE entry_id IN (SELECT e.id FROM ledger_entries e JOIN transactions t ON t.id = e.transaction_id WHERE t.type = 'transfer');
+DELETE FROM ledger_entries WHERE transaction_id IN (SELECT id FROM transactions WHERE type = 'transfer');
+DELETE FROM transactions WHERE type = 'transfer';
+ALTER TABLE transactions ALTER COLUMN payment_method_details SET NOT NULL;
+ALTER TABLE transactions ALTER COLUMN payment_method SET NOT NULL;
+ALTER TABLE transactions ALTER COLUMN payment_gateway SET NOT NULL;
@@ -0,0 +1,6 @@
+-- migrate:up transaction:false
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'refund';
+
+CREATE INDEX IF NOT EXISTS idx_transactions_related_transaction_id ON transactions (related_transaction_id);
+-- migrate:down
+DROP INDEX IF EXISTS idx_transactions_related_transaction_id;
@@ -0,0 +1,21 @@
+-- migrate:up transaction:false
+ALTER TYPE transaction_status ADD VALUE IF NOT EXISTS 'refunded';
+ALTER TYPE transaction_status ADD VALUE IF NOT EXISTS 'reversed';
+
+CREATE TYPE transition_source AS ENUM ('api', 'worker', 'callback', 'admin');
+
+CREATE TABLE transaction_status_history (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4() NOT NULL,  -- Primary key for history record (UUID)
+    transaction_id uuid NOT NULL,  -- Foreign key to transactions table (UUID)
+    from_status transaction_status,  -- Previous status, null for the initial status
+    to_status transaction_status NOT NULL,  -- New status
+    source transition_source NOT NULL,  -- Who moved the transaction (api/worker/callback/admin)
+    reason TEXT NOT NULL,  -- Why the transaction moved
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),  -- When the transition happened
+    CONSTRAINT fk_transaction FOREIGN KEY (transaction_id) REFERENCES transactions(id)  -- Foreign key constraint
+);
+
+CREATE INDEX idx_transaction_status_history_transaction_id ON transaction_status_history (transaction_id, created_at);
+-- migrate:down
+DROP TABLE IF EXISTS transaction_status_history;
+DROP TYPE IF EXISTS transition_source;
@@ -0,0 +1,13 @@
+-- migrate:up
+CREATE TABLE queue_jobs (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4() NOT NULL,  -- Primary key for job (UUID)
+    queue VARCHAR(255) NOT NULL,  -- Name of the queue the job belongs to
+    payload JSONB NOT NULL,  -- Job payload
+    status VARCHAR(32) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'processing')),  -- Job status (queued/processing)
+    locked_until TIMESTAMP,  -- Lease of the worker processing the job, expired leases are claimed again
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()  -- When the job was enqueued
+);
+
+CREATE INDEX idx_queue_jobs_queue_status_created_at ON queue_jobs (queue, status, created_at);
+-- migrate:down
+DROP TABLE IF EXISTS queue_jobs;
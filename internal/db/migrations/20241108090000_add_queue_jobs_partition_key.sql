@@ -0,0 +1,5 @@
+-- migrate:up
+ALTER TABLE queue_jobs ADD COLUMN partition_key VARCHAR(255) NOT NULL DEFAULT '';  -- Partition of the job, used for per partition concurrency limits
+
+-- migrate:down
+ALTER TABLE queue_jobs DROP COLUMN IF EXISTS partition_key;
@@ -0,0 +1,31 @@
+-- migrate:up
+ALTER TABLE queue_jobs
+    ADD COLUMN attempts INT NOT NULL DEFAULT 0,  -- Number of times the job was claimed
+    ADD COLUMN run_at TIMESTAMP NOT NULL DEFAULT NOW(),  -- The job is not claimed before this time, used to back off retries
+    ADD COLUMN last_error TEXT;  -- Error of the last failed attempt
+
+DROP INDEX IF EXISTS idx_queue_jobs_queue_status_created_at;
+CREATE INDEX idx_queue_jobs_queue_status_run_at ON queue_jobs (queue, status, run_at);
+
+CREATE TABLE queue_dead_letters (
+    id uuid PRIMARY KEY NOT NULL,  -- ID of the dead job
+    queue VARCHAR(255) NOT NULL,  -- Name of the queue the job belongs to
+    partition_key VARCHAR(255) NOT NULL DEFAULT '',  -- Partition of the job
+    payload JSONB NOT NULL,  -- Job payload
+    attempts INT NOT NULL,  -- Number of times the job was processed
+    last_error TEXT NOT NULL,  -- Error of the last failed attempt
+    created_at TIMESTAMP NOT NULL,  -- When the job was first enqueued
+    failed_at TIMESTAMP NOT NULL DEFAULT NOW()  -- When the job was dead-lettered
+);
+
+CREATE INDEX idx_queue_dead_letters_queue_failed_at ON queue_dead_letters (queue, failed_at);
+-- migrate:down
+DROP TABLE IF EXISTS queue_dead_letters;
+
+DROP INDEX IF EXISTS idx_queue_jobs_queue_status_run_at;
+CREATE INDEX idx_queue_jobs_queue_status_created_at ON queue_jobs (queue, status, created_at);
+
+ALTER TABLE queue_jobs
+    DROP COLUMN IF EXISTS attempts,
+    DROP COLUMN IF EXISTS run_at,
+    DROP COLUMN IF EXISTS last_error;
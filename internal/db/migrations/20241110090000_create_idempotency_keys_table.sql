@@ -0,0 +1,18 @@
+-- migrate:up
+CREATE TABLE idempotency_keys (
+    key VARCHAR(255) NOT NULL,  -- Idempotency-Key header sent by the client
+    wallet_id uuid NOT NULL,  -- Wallet the key is scoped to
+    operation VARCHAR(32) NOT NULL,  -- Operation the key is scoped to (deposit/withdraw)
+    request_hash CHAR(64) NOT NULL,  -- HMAC-SHA256 of the request keyed with IDEMPOTENCY_HASH_KEY, to reject a reused key with a different request
+    response JSONB NOT NULL,  -- Response returned to the original request
+    transaction_id uuid NOT NULL,  -- Transaction created by the original request
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),  -- When the key was first used
+    expires_at TIMESTAMP NOT NULL,  -- When the key can be used again
+    PRIMARY KEY (wallet_id, operation, key),
+    CONSTRAINT fk_wallet FOREIGN KEY (wallet_id) REFERENCES wallets(id),  -- Foreign key constraint
+    CONSTRAINT fk_transaction FOREIGN KEY (transaction_id) REFERENCES transactions(id)  -- Foreign key constraint
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+-- migrate:down
+DROP TABLE IF EXISTS idempotency_keys;
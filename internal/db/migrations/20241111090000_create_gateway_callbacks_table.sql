@@ -0,0 +1,22 @@
+-- migrate:up
+CREATE TYPE callback_status AS ENUM ('received', 'processed', 'failed');
+
+CREATE TABLE gateway_callbacks (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4() NOT NULL,  -- Primary key for callback (UUID)
+    gateway payment_gateway,  -- Payment gateway that sent the callback, null until known
+    transaction_id uuid,  -- Transaction the callback matched, null when none matched
+    headers JSONB NOT NULL,  -- Request headers as received
+    body TEXT NOT NULL,  -- Raw request body as received
+    status callback_status NOT NULL,  -- Processing result (received/processed/failed)
+    error TEXT,  -- Why the last processing failed
+    attempts INT NOT NULL DEFAULT 0,  -- Number of times the callback was processed
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),  -- When the callback was received
+    processed_at TIMESTAMP,  -- When the callback was last processed
+    CONSTRAINT fk_transaction FOREIGN KEY (transaction_id) REFERENCES transactions(id)  -- Foreign key constraint
+);
+
+CREATE INDEX idx_gateway_callbacks_created_at ON gateway_callbacks (created_at);
+CREATE INDEX idx_gateway_callbacks_transaction_id ON gateway_callbacks (transaction_id);
+-- migrate:down
+DROP TABLE IF EXISTS gateway_callbacks;
+DROP TYPE IF EXISTS callback_status;
@@ -0,0 +1,4 @@
+-- migrate:up transaction:false
+ALTER TYPE callback_status ADD VALUE IF NOT EXISTS 'ignored';  -- Callback already applied, or pending after the final status
+ALTER TYPE callback_status ADD VALUE IF NOT EXISTS 'needs_review';  -- Callback conflicting with the final status of the transaction
+-- migrate:down
@@ -0,0 +1,4 @@
+-- migrate:up
+ALTER TABLE transactions ADD COLUMN version INT NOT NULL DEFAULT 0;  -- Bumped on every update, for optimistic locking
+-- migrate:down
+ALTER TABLE transactions DROP COLUMN IF EXISTS version;
@@ -0,0 +1,4 @@
+-- migrate:up
+ALTER TABLE transactions ADD COLUMN routing_reason TEXT NULL;  -- Why the payment router chose the gateway, NULL when the client chose it
+-- migrate:down
+ALTER TABLE transactions DROP COLUMN IF EXISTS routing_reason;
@@ -0,0 +1,3 @@
+-- migrate:up transaction:false
+ALTER TYPE payment_gateway ADD VALUE IF NOT EXISTS 'gateway_c';  -- OAuth2 authenticated gateway taking amounts in minor units
+-- migrate:down
@@ -0,0 +1,8 @@
+-- migrate:up
+ALTER TABLE transactions ALTER COLUMN amount TYPE DECIMAL(18, 3);  -- Transaction amount with 3 decimal precision, for currencies such as KWD
+ALTER TABLE ledger_postings ALTER COLUMN amount TYPE DECIMAL(18, 3);  -- Posted amount with 3 decimal precision
+ALTER TABLE holds ALTER COLUMN amount TYPE DECIMAL(18, 3);  -- Reserved amount with 3 decimal precision
+-- migrate:down
+ALTER TABLE holds ALTER COLUMN amount TYPE DECIMAL(18, 2);
+ALTER TABLE ledger_postings ALTER COLUMN amount TYPE DECIMAL(18, 2);
+ALTER TABLE transactions ALTER COLUMN amount TYPE DECIMAL(18, 2);
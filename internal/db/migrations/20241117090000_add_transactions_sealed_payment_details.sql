@@ -0,0 +1,8 @@
+-- migrate:up
+ALTER TABLE transactions ADD COLUMN sealed_payment_details BYTEA NULL;  -- Unmasked payment details without the card security code, encrypted with PAYMENT_DETAILS_KEY and cleared once the transaction leaves created
+
+-- queued jobs used to carry the payment details in plaintext, their transactions fail for lack of details when processed.
+UPDATE queue_jobs SET payload = payload - 'payment_details';
+UPDATE queue_dead_letters SET payload = payload - 'payment_details';
+-- migrate:down
+ALTER TABLE transactions DROP COLUMN IF EXISTS sealed_payment_details;
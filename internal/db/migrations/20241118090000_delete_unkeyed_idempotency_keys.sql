@@ -0,0 +1,6 @@
+-- migrate:up
+-- request hashes are now keyed with IDEMPOTENCY_HASH_KEY, so the keys stored with an unkeyed hash can no longer be checked against a retried request.
+-- They are deleted rather than kept unmatchable: a retry with one of them is processed as a new request.
+DELETE FROM idempotency_keys;
+-- migrate:down
+-- the deleted keys cannot be restored, their hashes were not keyed.
@@ -0,0 +1,4 @@
+-- migrate:up
+ALTER TABLE gateway_callbacks ALTER COLUMN body TYPE BYTEA USING convert_to(body, 'UTF8');  -- Raw request body as received, which need not be UTF-8
+-- migrate:down
+ALTER TABLE gateway_callbacks ALTER COLUMN body TYPE TEXT USING convert_from(body, 'UTF8');
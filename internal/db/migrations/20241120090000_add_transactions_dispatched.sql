@@ -0,0 +1,7 @@
+-- migrate:up
+ALTER TABLE transactions ADD COLUMN dispatched BOOLEAN NOT NULL DEFAULT false;  -- A request for the transaction might have reached its payment gateway, it never fails over once set
+
+-- created transactions might have been sent before the column existed.
+UPDATE transactions SET dispatched = true WHERE status = 'created';
+-- migrate:down
+ALTER TABLE transactions DROP COLUMN IF EXISTS dispatched;
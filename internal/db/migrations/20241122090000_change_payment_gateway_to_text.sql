@@ -0,0 +1,9 @@
+-- migrate:up
+-- gateways can be added with configuration alone, so their names are no longer an enum. The service only stores the gateways it has registered.
+ALTER TABLE transactions ALTER COLUMN payment_gateway TYPE TEXT USING payment_gateway::text;
+ALTER TABLE gateway_callbacks ALTER COLUMN gateway TYPE TEXT USING gateway::text;
+DROP TYPE IF EXISTS payment_gateway;
+-- migrate:down
+CREATE TYPE payment_gateway AS ENUM ('gateway_a', 'gateway_b', 'gateway_c');
+ALTER TABLE transactions ALTER COLUMN payment_gateway TYPE payment_gateway USING payment_gateway::payment_gateway;
+ALTER TABLE gateway_callbacks ALTER COLUMN gateway TYPE payment_gateway USING gateway::payment_gateway;
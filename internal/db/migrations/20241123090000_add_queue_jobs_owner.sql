@@ -0,0 +1,8 @@
+-- migrate:up
+ALTER TABLE queue_jobs
+    ADD COLUMN owner VARCHAR(255),  -- Replica that enqueued the job, it is the only one claiming it until owned_until
+    ADD COLUMN owned_until TIMESTAMP;  -- Other replicas claim the job after this time, e.g. once the data the owner kept for it expired
+-- migrate:down
+ALTER TABLE queue_jobs
+    DROP COLUMN IF EXISTS owner,
+    DROP COLUMN IF EXISTS owned_until;
@@ -4,20 +4,42 @@ import (
 	"encoding/json"
 
 	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/google/uuid"
 )
 
 type Payment struct {
-	Gateway       models.PaymentGateway `json:"gateway" validate:"required"`
+	// Gateway is chosen by the payment router when empty.
+	Gateway       models.PaymentGateway `json:"gateway"`
 	Method        models.PaymentMethod  `json:"method" validate:"required"`
 	MethodDetails json.RawMessage       `json:"method_details" validate:"required,json"`
 }
 
 type Deposit struct {
-	Amount  float64 `json:"amount" validate:"required,gt=0"`
-	Payment Payment `json:"payment"`
+	Amount   money.Money    `json:"amount" validate:"required,gt=0"`
+	Currency money.Currency `json:"currency" validate:"required,iso4217"`
+	Payment  Payment        `json:"payment"`
+
+	// IdempotencyKey is read from the Idempotency-Key header.
+	IdempotencyKey string `json:"-" validate:"max=255"`
 }
 
 type Withdraw struct {
-	Amount  float64 `json:"amount" validate:"required,gt=0"`
-	Payment Payment `json:"payment"`
+	Amount   money.Money    `json:"amount" validate:"required,gt=0"`
+	Currency money.Currency `json:"currency" validate:"required,iso4217"`
+	Payment  Payment        `json:"payment"`
+
+	// IdempotencyKey is read from the Idempotency-Key header.
+	IdempotencyKey string `json:"-" validate:"max=255"`
+}
+
+type Transfer struct {
+	ToWalletID uuid.UUID      `json:"to_wallet_id" validate:"required"`
+	Amount     money.Money    `json:"amount" validate:"required,gt=0"`
+	Currency   money.Currency `json:"currency" validate:"required,iso4217"`
+}
+
+// Refund refunds a completed deposit, a zero amount refunds the whole remaining amount.
+type Refund struct {
+	Amount money.Money `json:"amount" validate:"omitempty,gt=0"`
 }
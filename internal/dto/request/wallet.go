@@ -1,4 +1,7 @@
 package request
 
+import "github.com/3bd-dev/wallet-service/pkg/money"
+
 type CreateWallet struct {
+	Currency money.Currency `json:"currency" validate:"required,iso4217"`
 }
@@ -0,0 +1,133 @@
+package adminapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/internal/services/wallet"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/web"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultCallbacksLimit = 100
+	maxCallbacksLimit     = 1000
+)
+
+type api struct {
+	service *wallet.Service
+}
+
+func newapi(svc *wallet.Service) *api {
+	return &api{service: svc}
+}
+
+// listDeadLetters returns the queued transactions whose processing failed for good.
+func (a *api) listDeadLetters(w http.ResponseWriter, r *http.Request) {
+	letters, err := a.service.DeadLetters(r.Context())
+	if err != nil {
+		web.RenderErr(w, err)
+		return
+	}
+
+	web.RenderOk(w, letters)
+}
+
+// getDeadLetter returns a dead-lettered transaction.
+func (a *api) getDeadLetter(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		web.RenderErr(w, errs.New(errs.InvalidArgument, fmt.Errorf("invalid ID: %w", err)))
+		return
+	}
+
+	letter, er := a.service.DeadLetter(r.Context(), id)
+	if er != nil {
+		web.RenderErr(w, er)
+		return
+	}
+
+	web.RenderOk(w, letter)
+}
+
+// requeueDeadLetter moves a dead-lettered transaction back to the queue.
+func (a *api) requeueDeadLetter(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		web.RenderErr(w, errs.New(errs.InvalidArgument, fmt.Errorf("invalid ID: %w", err)))
+		return
+	}
+
+	if er := a.service.RequeueDeadLetter(r.Context(), id); er != nil {
+		web.RenderErr(w, er)
+		return
+	}
+
+	web.RenderNoContent(w)
+}
+
+// listCallbacks returns the latest gateway callbacks, optionally filtered by status.
+func (a *api) listCallbacks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	status := models.CallbackStatus(query.Get("status"))
+
+	limit := defaultCallbacksLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxCallbacksLimit {
+			web.RenderErr(w, errs.Newf(errs.InvalidArgument, "limit must be between 1 and %d", maxCallbacksLimit))
+			return
+		}
+		limit = n
+	}
+
+	callbacks, err := a.service.Callbacks(r.Context(), status, limit)
+	if err != nil {
+		web.RenderErr(w, err)
+		return
+	}
+
+	web.RenderOk(w, callbacks)
+}
+
+// getCallback returns a gateway callback.
+func (a *api) getCallback(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		web.RenderErr(w, errs.New(errs.InvalidArgument, fmt.Errorf("invalid ID: %w", err)))
+		return
+	}
+
+	callback, er := a.service.Callback(r.Context(), id)
+	if er != nil {
+		web.RenderErr(w, er)
+		return
+	}
+
+	web.RenderOk(w, callback)
+}
+
+// reprocessCallback processes a stored gateway callback again and returns its outcome.
+func (a *api) reprocessCallback(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		web.RenderErr(w, errs.New(errs.InvalidArgument, fmt.Errorf("invalid ID: %w", err)))
+		return
+	}
+
+	callback, er := a.service.ReprocessCallback(r.Context(), id)
+	if er != nil {
+		web.RenderErr(w, er)
+		return
+	}
+
+	web.RenderOk(w, callback)
+}
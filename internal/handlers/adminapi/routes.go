@@ -0,0 +1,27 @@
+package adminapi
+
+import (
+	"net/http"
+
+	"github.com/3bd-dev/wallet-service/internal/services/wallet"
+	"github.com/3bd-dev/wallet-service/internal/web/mid"
+	"github.com/gorilla/mux"
+)
+
+type Config struct {
+	Service *wallet.Service
+	Token   string // Bearer token required by every admin endpoint
+}
+
+// Routes adds specific routes for this group.
+func Routes(router *mux.Router, cfg Config) {
+	api := newapi(cfg.Service)
+	admin := router.PathPrefix("/api/v1/admin").Subrouter()
+	admin.Use(mid.BearerToken(cfg.Token))
+	admin.HandleFunc("/dead-letters", api.listDeadLetters).Methods(http.MethodGet)
+	admin.HandleFunc("/dead-letters/{id}", api.getDeadLetter).Methods(http.MethodGet)
+	admin.HandleFunc("/dead-letters/{id}/requeue", api.requeueDeadLetter).Methods(http.MethodPost)
+	admin.HandleFunc("/callbacks", api.listCallbacks).Methods(http.MethodGet)
+	admin.HandleFunc("/callbacks/{id}", api.getCallback).Methods(http.MethodGet)
+	admin.HandleFunc("/callbacks/{id}/reprocess", api.reprocessCallback).Methods(http.MethodPost)
+}
@@ -0,0 +1,21 @@
+package gatewayapi
+
+import (
+	"net/http"
+
+	"github.com/3bd-dev/wallet-service/internal/services/wallet"
+	"github.com/3bd-dev/wallet-service/pkg/web"
+)
+
+type api struct {
+	service *wallet.Service
+}
+
+func newapi(svc *wallet.Service) *api {
+	return &api{service: svc}
+}
+
+// list returns the payment gateways with their capabilities and circuit breaker state.
+func (a *api) list(w http.ResponseWriter, r *http.Request) {
+	web.RenderOk(w, a.service.PaymentGateways(r.Context()))
+}
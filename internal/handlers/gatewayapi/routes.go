@@ -0,0 +1,18 @@
+package gatewayapi
+
+import (
+	"net/http"
+
+	"github.com/3bd-dev/wallet-service/internal/services/wallet"
+	"github.com/gorilla/mux"
+)
+
+type Config struct {
+	Service *wallet.Service
+}
+
+// Routes adds specific routes for this group.
+func Routes(router *mux.Router, cfg Config) {
+	api := newapi(cfg.Service)
+	router.HandleFunc("/api/v1/payment-gateways", api.list).Methods(http.MethodGet)
+}
@@ -17,9 +17,13 @@ func Routes(router *mux.Router, cfg Config) {
 	wallets := router.PathPrefix("/api/v1/wallets").Subrouter()
 	wallets.HandleFunc("", api.create).Methods(http.MethodPost)
 	wallets.HandleFunc("", api.list).Methods(http.MethodGet)
+	wallets.HandleFunc("/{id}", api.get).Methods(http.MethodGet)
 	wallets.HandleFunc("/{id}/deposit", api.deposit).Methods(http.MethodPost)
 	wallets.HandleFunc("/{id}/withdraw", api.withdraw).Methods(http.MethodPost)
+	wallets.HandleFunc("/{id}/transfers", api.transfer).Methods(http.MethodPost)
 	wallets.HandleFunc("/{id}/transactions", api.getTransactions).Methods(http.MethodGet)
 	wallets.HandleFunc("/{id}/transactions/{transactionID}", api.getTransaction).Methods(http.MethodGet)
+	wallets.HandleFunc("/{id}/transactions/{transactionID}/history", api.getTransactionHistory).Methods(http.MethodGet)
 	wallets.HandleFunc("/{id}/transactions/{transactionID}/callback", api.callback).Methods(http.MethodPost)
+	wallets.HandleFunc("/{id}/transactions/{transactionID}/refunds", api.refund).Methods(http.MethodPost)
 }
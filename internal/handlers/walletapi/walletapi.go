@@ -14,6 +14,9 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// idempotencyKeyHeader carries the client key making a request safe to retry.
+const idempotencyKeyHeader = "Idempotency-Key"
+
 type api struct {
 	service *wallet.Service
 }
@@ -36,6 +39,7 @@ func (a *api) deposit(w http.ResponseWriter, r *http.Request) {
 		web.RenderErr(w, errs.New(errs.InvalidArgument, fmt.Errorf("failed to decode request body: %w", err)))
 		return
 	}
+	req.IdempotencyKey = r.Header.Get(idempotencyKeyHeader)
 
 	tran, er := a.service.Deposit(r.Context(), id, req)
 	if er != nil {
@@ -60,6 +64,7 @@ func (a *api) withdraw(w http.ResponseWriter, r *http.Request) {
 		web.RenderErr(w, errs.New(errs.InvalidArgument, fmt.Errorf("failed to decode request body: %w", err)))
 		return
 	}
+	req.IdempotencyKey = r.Header.Get(idempotencyKeyHeader)
 
 	tran, er := a.service.Withdraw(r.Context(), id, req)
 	if er != nil {
@@ -70,6 +75,60 @@ func (a *api) withdraw(w http.ResponseWriter, r *http.Request) {
 	web.RenderOk(w, tran)
 }
 
+// transfer moves funds from the wallet to another wallet.
+func (a *api) transfer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		web.RenderErr(w, errs.New(errs.InvalidArgument, fmt.Errorf("invalid ID: %w", err)))
+		return
+	}
+
+	var req request.Transfer
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		web.RenderErr(w, errs.New(errs.InvalidArgument, fmt.Errorf("failed to decode request body: %w", err)))
+		return
+	}
+
+	tran, er := a.service.Transfer(r.Context(), id, req)
+	if er != nil {
+		web.RenderErr(w, er)
+		return
+	}
+
+	web.RenderOk(w, tran)
+}
+
+// refund refunds a completed deposit of the wallet.
+func (a *api) refund(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		web.RenderErr(w, errs.New(errs.InvalidArgument, fmt.Errorf("invalid ID: %w", err)))
+		return
+	}
+
+	tranID, err := uuid.Parse(vars["transactionID"])
+	if err != nil {
+		web.RenderErr(w, errs.New(errs.InvalidArgument, fmt.Errorf("invalid transaction ID: %w", err)))
+		return
+	}
+
+	var req request.Refund
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		web.RenderErr(w, errs.New(errs.InvalidArgument, fmt.Errorf("failed to decode request body: %w", err)))
+		return
+	}
+
+	tran, er := a.service.Refund(r.Context(), id, tranID, req)
+	if er != nil {
+		web.RenderErr(w, er)
+		return
+	}
+
+	web.RenderOk(w, tran)
+}
+
 // callback processes the callback from the payment gateway.
 func (a *api) callback(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -85,13 +144,13 @@ func (a *api) callback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	body, err := io.ReadAll(r.Body)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, wallet.MaxCallbackBodySize))
 	if err != nil {
 		web.RenderErr(w, errs.New(errs.InvalidArgument, fmt.Errorf("failed to read request body: %w", err)))
 		return
 	}
 
-	if err := a.service.ProcessCallback(r.Context(), id, tranID, body); err != nil {
+	if err := a.service.ProcessCallback(r.Context(), id, tranID, r.Header, body); err != nil {
 		web.RenderErr(w, err)
 		return
 	}
@@ -123,6 +182,30 @@ func (a *api) getTransaction(w http.ResponseWriter, r *http.Request) {
 	web.RenderOk(w, tran)
 }
 
+// getTransactionHistory returns the status history of the transaction.
+func (a *api) getTransactionHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		web.RenderErr(w, errs.New(errs.InvalidArgument, fmt.Errorf("invalid ID: %w", err)))
+		return
+	}
+
+	tranID, err := uuid.Parse(vars["transactionID"])
+	if err != nil {
+		web.RenderErr(w, errs.New(errs.InvalidArgument, fmt.Errorf("invalid transaction ID: %w", err)))
+		return
+	}
+
+	history, er := a.service.TransactionHistory(r.Context(), tranID, id)
+	if er != nil {
+		web.RenderErr(w, er)
+		return
+	}
+
+	web.RenderOk(w, history)
+}
+
 // create creates a new wallet.
 func (a *api) create(w http.ResponseWriter, r *http.Request) {
 	var req request.CreateWallet
@@ -157,6 +240,24 @@ func (a *api) getTransactions(w http.ResponseWriter, r *http.Request) {
 	web.RenderOk(w, tran)
 }
 
+// get returns the wallet by ID with its balances.
+func (a *api) get(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		web.RenderErr(w, errs.New(errs.InvalidArgument, fmt.Errorf("invalid ID: %w", err)))
+		return
+	}
+
+	wallet, er := a.service.Get(r.Context(), id)
+	if er != nil {
+		web.RenderErr(w, er)
+		return
+	}
+
+	web.RenderOk(w, wallet)
+}
+
 func (a *api) list(w http.ResponseWriter, r *http.Request) {
 	wallets, err := a.service.List(r.Context())
 	if err != nil {
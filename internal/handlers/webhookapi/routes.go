@@ -0,0 +1,19 @@
+package webhookapi
+
+import (
+	"net/http"
+
+	"github.com/3bd-dev/wallet-service/internal/services/wallet"
+	"github.com/gorilla/mux"
+)
+
+type Config struct {
+	Service *wallet.Service
+}
+
+// Routes adds specific routes for this group.
+func Routes(router *mux.Router, cfg Config) {
+	api := newapi(cfg.Service)
+	webhooks := router.PathPrefix("/api/v1/webhooks").Subrouter()
+	webhooks.HandleFunc("/{gateway}", api.webhook).Methods(http.MethodPost)
+}
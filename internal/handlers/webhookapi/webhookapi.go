@@ -0,0 +1,39 @@
+package webhookapi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/internal/services/wallet"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/web"
+	"github.com/gorilla/mux"
+)
+
+type api struct {
+	service *wallet.Service
+}
+
+func newapi(svc *wallet.Service) *api {
+	return &api{service: svc}
+}
+
+// webhook processes a callback sent to the webhook of a payment gateway.
+func (a *api) webhook(w http.ResponseWriter, r *http.Request) {
+	gateway := models.PaymentGateway(mux.Vars(r)["gateway"])
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, wallet.MaxCallbackBodySize))
+	if err != nil {
+		web.RenderErr(w, errs.New(errs.InvalidArgument, fmt.Errorf("failed to read request body: %w", err)))
+		return
+	}
+
+	if err := a.service.ProcessWebhook(r.Context(), gateway, r.Header, body); err != nil {
+		web.RenderErr(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GatewayCallback is a callback received from a payment gateway. It is stored before it
+// is processed, so it can be inspected and reprocessed later.
+type GatewayCallback struct {
+	ID            uuid.UUID       `json:"id"`
+	Gateway       *PaymentGateway `json:"gateway"`
+	TransactionID *uuid.UUID      `json:"transaction_id"`
+	Headers       json.RawMessage `json:"headers"`
+	Body          []byte          `json:"body"`
+	Status        CallbackStatus  `json:"status"`
+	Error         *string         `json:"error"`
+	Attempts      int             `json:"attempts"`
+	CreatedAt     time.Time       `json:"created_at"`
+	ProcessedAt   *time.Time      `json:"processed_at"`
+}
+
+// CallbackStatus is the processing result of a gateway callback.
+type CallbackStatus string
+
+const (
+	CallbackStatusReceived  CallbackStatus = "received"
+	CallbackStatusProcessed CallbackStatus = "processed"
+	CallbackStatusFailed    CallbackStatus = "failed"
+	// CallbackStatusIgnored is a callback reporting a status the transaction already
+	// reached, or a pending status after the final one. It has no side effects.
+	CallbackStatusIgnored CallbackStatus = "ignored"
+	// CallbackStatusNeedsReview is a callback reporting a final status conflicting with
+	// the final status of the transaction, e.g. success after failed.
+	CallbackStatusNeedsReview CallbackStatus = "needs_review"
+)
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/google/uuid"
+)
+
+// Hold reserves part of a wallet's available balance for an in-flight transaction.
+type Hold struct {
+	ID            uuid.UUID   `json:"id"`
+	WalletID      uuid.UUID   `json:"wallet_id"`
+	TransactionID uuid.UUID   `json:"transaction_id"`
+	Amount        money.Money `json:"amount"`
+	Status        HoldStatus  `json:"status"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+// HoldStatus represents the status of a hold
+type HoldStatus string
+
+const (
+	// HoldStatusActive reserves the funds against the available balance.
+	HoldStatusActive HoldStatus = "active"
+	// HoldStatusCaptured means the held funds were debited from the wallet.
+	HoldStatusCaptured HoldStatus = "captured"
+	// HoldStatusReleased means the held funds were returned to the available balance.
+	HoldStatusReleased HoldStatus = "released"
+)
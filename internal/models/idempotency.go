@@ -0,0 +1,29 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyKey records the outcome of a request sent with an Idempotency-Key header,
+// so a retry of the same request returns the original response.
+type IdempotencyKey struct {
+	Key           string              `json:"key"`
+	WalletID      uuid.UUID           `json:"wallet_id"`
+	Operation     IdempotentOperation `json:"operation"`
+	RequestHash   string              `json:"request_hash"`
+	Response      json.RawMessage     `json:"response"`
+	TransactionID uuid.UUID           `json:"transaction_id"`
+	CreatedAt     time.Time           `json:"created_at"`
+	ExpiresAt     time.Time           `json:"expires_at"`
+}
+
+// IdempotentOperation is the operation an idempotency key is scoped to.
+type IdempotentOperation string
+
+const (
+	IdempotentOperationDeposit  IdempotentOperation = "deposit"
+	IdempotentOperationWithdraw IdempotentOperation = "withdraw"
+)
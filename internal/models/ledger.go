@@ -0,0 +1,90 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/google/uuid"
+)
+
+// LedgerEntry is a double-entry journal entry, the sum of its debit postings
+// always equals the sum of its credit postings.
+type LedgerEntry struct {
+	ID            uuid.UUID       `json:"id"`
+	TransactionID *uuid.UUID      `json:"transaction_id"`
+	Description   string          `json:"description"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Postings      []LedgerPosting `json:"postings" gorm:"foreignKey:EntryID"`
+}
+
+// IsBalanced reports whether the entry has postings and its debits equal its
+// credits in every currency.
+func (e *LedgerEntry) IsBalanced() bool {
+	if e == nil || len(e.Postings) < 2 {
+		return false
+	}
+
+	sums := make(map[money.Currency]money.Money)
+	for _, p := range e.Postings {
+		if p.Amount <= 0 {
+			return false
+		}
+		switch p.Direction {
+		case PostingDirectionDebit:
+			sums[p.Currency] += p.Amount
+		case PostingDirectionCredit:
+			sums[p.Currency] -= p.Amount
+		default:
+			return false
+		}
+	}
+
+	for _, sum := range sums {
+		if sum != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// LedgerPosting is a single debit or credit line of a ledger entry.
+type LedgerPosting struct {
+	ID        uuid.UUID        `json:"id"`
+	EntryID   uuid.UUID        `json:"entry_id"`
+	Account   string           `json:"account"`
+	WalletID  *uuid.UUID       `json:"wallet_id"`
+	Direction PostingDirection `json:"direction"`
+	Amount    money.Money      `json:"amount"`
+	Currency  money.Currency   `json:"currency"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// PostingDirection represents the side of the ledger a posting is on
+type PostingDirection string
+
+const (
+	PostingDirectionDebit  PostingDirection = "debit"
+	PostingDirectionCredit PostingDirection = "credit"
+)
+
+// WalletAccount returns the ledger account of a wallet.
+// Wallet accounts are credit-normal, credits increase the wallet balance.
+func WalletAccount(walletID uuid.UUID) string {
+	return fmt.Sprintf("wallet:%s", walletID)
+}
+
+// GatewayAccount returns the clearing account of a payment gateway in the given currency.
+func GatewayAccount(gateway PaymentGateway, currency money.Currency) string {
+	return fmt.Sprintf("gateway:%s:%s", gateway, currency)
+}
+
+// Balance represents the balances of a wallet.
+type Balance struct {
+	// Currency is the currency of the wallet.
+	Currency money.Currency `json:"currency"`
+	// Ledger is the sum of all posted entries.
+	Ledger money.Money `json:"ledger"`
+	// Available is the amount that can be spent right now.
+	Available money.Money `json:"available"`
+}
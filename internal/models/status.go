@@ -0,0 +1,88 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidTransition is returned when a transaction cannot move to the requested status.
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// transactionTransitions defines the legal transitions of the transaction state machine.
+//
+//	created -> pending -> completed -> refunded
+//	   |          |           |
+//	   +-> failed <+          +-> reversed
+var transactionTransitions = map[TransactionStatus][]TransactionStatus{
+	TransactionStatusCreated:   {TransactionStatusPending, TransactionStatusFailed},
+	TransactionStatusPending:   {TransactionStatusCompleted, TransactionStatusFailed},
+	TransactionStatusCompleted: {TransactionStatusRefunded, TransactionStatusReversed},
+}
+
+// CanTransitionTo reports whether the state machine allows moving from s to the given status.
+func (s TransactionStatus) CanTransitionTo(to TransactionStatus) bool {
+	for _, next := range transactionTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether the payment gateway outcome of the transaction is known,
+// i.e. it is no longer created or pending.
+func (s TransactionStatus) IsFinal() bool {
+	return s != TransactionStatusCreated && s != TransactionStatusPending
+}
+
+// HasReached reports whether a transaction with status s went through the given
+// status, e.g. a refunded transaction reached completed.
+func (s TransactionStatus) HasReached(status TransactionStatus) bool {
+	if s == status {
+		return true
+	}
+	for _, next := range transactionTransitions[status] {
+		if s.HasReached(next) {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionTo moves the transaction to the given status if the state machine allows it.
+func (t *Transaction) TransitionTo(to TransactionStatus) error {
+	if !t.Status.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, t.Status, to)
+	}
+	t.Status = to
+	return nil
+}
+
+// TransactionStatusHistory records a single status transition of a transaction.
+type TransactionStatusHistory struct {
+	ID            uuid.UUID          `json:"id"`
+	TransactionID uuid.UUID          `json:"transaction_id"`
+	FromStatus    *TransactionStatus `json:"from_status"`
+	ToStatus      TransactionStatus  `json:"to_status"`
+	Source        TransitionSource   `json:"source"`
+	Reason        string             `json:"reason"`
+	CreatedAt     time.Time          `json:"created_at"`
+}
+
+// TableName overrides the pluralized table name.
+func (TransactionStatusHistory) TableName() string {
+	return "transaction_status_history"
+}
+
+// TransitionSource represents who moved a transaction to a new status
+type TransitionSource string
+
+const (
+	TransitionSourceAPI      TransitionSource = "api"
+	TransitionSourceWorker   TransitionSource = "worker"
+	TransitionSourceCallback TransitionSource = "callback"
+	TransitionSourceAdmin    TransitionSource = "admin"
+)
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/3bd-dev/wallet-service/pkg/unitest"
+)
+
+func Test_StateMachine(t *testing.T) {
+	t.Parallel()
+
+	unitest.Run(t, transitionTo(), "transitionTo")
+	unitest.Run(t, hasReached(), "hasReached")
+}
+
+func transitionTo() []unitest.Table {
+	cmp := func(got any, exp any) string {
+		gotErr, _ := got.(error)
+		expErr, _ := exp.(error)
+		if !errors.Is(gotErr, expErr) {
+			return fmt.Sprintf("expected error %v, got %v", expErr, gotErr)
+		}
+		return ""
+	}
+
+	tests := []unitest.Table{}
+	for _, tc := range []struct {
+		from TransactionStatus
+		to   TransactionStatus
+		exp  error
+	}{
+		{from: TransactionStatusCreated, to: TransactionStatusPending},
+		{from: TransactionStatusCreated, to: TransactionStatusFailed},
+		{from: TransactionStatusPending, to: TransactionStatusCompleted},
+		{from: TransactionStatusPending, to: TransactionStatusFailed},
+		{from: TransactionStatusCompleted, to: TransactionStatusRefunded},
+		{from: TransactionStatusCompleted, to: TransactionStatusReversed},
+		{from: TransactionStatusCreated, to: TransactionStatusCompleted, exp: ErrInvalidTransition},
+		{from: TransactionStatusPending, to: TransactionStatusPending, exp: ErrInvalidTransition},
+		{from: TransactionStatusCompleted, to: TransactionStatusFailed, exp: ErrInvalidTransition},
+		{from: TransactionStatusFailed, to: TransactionStatusCompleted, exp: ErrInvalidTransition},
+		{from: TransactionStatusRefunded, to: TransactionStatusCompleted, exp: ErrInvalidTransition},
+	} {
+		tc := tc
+		tests = append(tests, unitest.Table{
+			Name:    fmt.Sprintf("%s to %s", tc.from, tc.to),
+			ExpResp: tc.exp,
+			ExcFunc: func(ctx context.Context) any {
+				tran := Transaction{Status: tc.from}
+				err := tran.TransitionTo(tc.to)
+				if err == nil && tran.Status != tc.to {
+					return fmt.Errorf("status not updated: %s", tran.Status)
+				}
+				if err != nil && tran.Status != tc.from {
+					return fmt.Errorf("status changed on error: %s", tran.Status)
+				}
+				return err
+			},
+			CmpFunc: cmp,
+		})
+	}
+
+	return tests
+}
+
+func hasReached() []unitest.Table {
+	cmp := func(got any, exp any) string {
+		if got != exp {
+			return fmt.Sprintf("expected %v, got %v", exp, got)
+		}
+		return ""
+	}
+
+	tests := []unitest.Table{}
+	for _, tc := range []struct {
+		status  TransactionStatus
+		reached TransactionStatus
+		exp     bool
+	}{
+		{status: TransactionStatusCompleted, reached: TransactionStatusCompleted, exp: true},
+		{status: TransactionStatusRefunded, reached: TransactionStatusCompleted, exp: true},
+		{status: TransactionStatusReversed, reached: TransactionStatusPending, exp: true},
+		{status: TransactionStatusFailed, reached: TransactionStatusFailed, exp: true},
+		{status: TransactionStatusFailed, reached: TransactionStatusCompleted, exp: false},
+		{status: TransactionStatusPending, reached: TransactionStatusCompleted, exp: false},
+		{status: TransactionStatusRefunded, reached: TransactionStatusFailed, exp: false},
+	} {
+		tc := tc
+		tests = append(tests, unitest.Table{
+			Name:    fmt.Sprintf("%s reached %s", tc.status, tc.reached),
+			ExpResp: tc.exp,
+			ExcFunc: func(ctx context.Context) any {
+				return tc.status.HasReached(tc.reached)
+			},
+			CmpFunc: cmp,
+		})
+	}
+
+	return tests
+}
@@ -1,9 +1,12 @@
 package models
 
 import (
+	"database/sql/driver"
 	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/3bd-dev/wallet-service/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -11,16 +14,29 @@ import (
 type Transaction struct {
 	ID                   uuid.UUID         `json:"id"`
 	WalletID             uuid.UUID         `json:"wallet_id"`
-	Amount               float64           `json:"amount"`
+	Amount               money.Money       `json:"amount"`
+	Currency             money.Currency    `json:"currency"`
 	Type                 TransactionType   `json:"type"`
+	Direction            PostingDirection  `json:"direction"`
 	Status               TransactionStatus `json:"status"`
 	PaymentGateway       PaymentGateway    `json:"payment_gateway"`
 	PaymentMethod        PaymentMethod     `json:"payment_method"`
 	PaymentMethodDetails json.RawMessage   `json:"payment_method_details"`
 	ReferenceID          *string           `json:"reference_id"`
-	CreatedAt            time.Time         `json:"created_at"`
-	UpdatedAt            time.Time         `json:"updated_at"`
-	Wallet               *Wallet           `json:"wallet,omitempty" `
+	RelatedTransactionID *uuid.UUID        `json:"related_transaction_id,omitempty"`
+	// RoutingReason explains why the payment router chose the gateway, nil when the client chose it.
+	RoutingReason *string `json:"routing_reason,omitempty"`
+	// SealedPaymentDetails are the unmasked payment details, encrypted, the queue worker
+	// sends to the gateway. They are cleared once the transaction leaves created.
+	SealedPaymentDetails []byte `json:"-"`
+	// Dispatched is set once a request for the transaction might have reached its payment
+	// gateway, from then on the transaction never fails over to another gateway.
+	Dispatched bool `json:"-"`
+	// Version is bumped on every update, updates of a stale transaction are rejected.
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Wallet    *Wallet   `json:"wallet,omitempty" `
 }
 
 func (t *Transaction) IsEmpty() bool {
@@ -35,6 +51,8 @@ type TransactionType string
 const (
 	TransactionTypeDeposit    TransactionType = "deposit"
 	TransactionTypeWithdrawal TransactionType = "withdrawal"
+	TransactionTypeTransfer   TransactionType = "transfer"
+	TransactionTypeRefund     TransactionType = "refund"
 )
 
 // TransactionStatus represents the status of a transaction
@@ -45,6 +63,8 @@ const (
 	TransactionStatusPending   TransactionStatus = "pending"
 	TransactionStatusCompleted TransactionStatus = "completed"
 	TransactionStatusFailed    TransactionStatus = "failed"
+	TransactionStatusRefunded  TransactionStatus = "refunded"
+	TransactionStatusReversed  TransactionStatus = "reversed"
 )
 
 // PaymentGateway represents the payment gateway used for a transaction
@@ -53,8 +73,25 @@ type PaymentGateway string
 const (
 	PaymentGatewayA PaymentGateway = "gateway_a"
 	PaymentGatewayB PaymentGateway = "gateway_b"
+	PaymentGatewayC PaymentGateway = "gateway_c"
 )
 
+// Value implements driver.Valuer, transactions that do not go through a
+// gateway (e.g. transfers) store NULL.
+func (g PaymentGateway) Value() (driver.Value, error) {
+	if g == "" {
+		return nil, nil
+	}
+	return string(g), nil
+}
+
+// Scan implements sql.Scanner.
+func (g *PaymentGateway) Scan(src any) error {
+	s, err := scanNullString(src)
+	*g = PaymentGateway(s)
+	return err
+}
+
 // PaymentMethod represents the payment method used for a transaction
 type PaymentMethod string
 
@@ -62,3 +99,32 @@ const (
 	PaymentMethodCreditCard   PaymentMethod = "credit_card"
 	PaymentMethodBankTransfer PaymentMethod = "bank_transfer"
 )
+
+// Value implements driver.Valuer, transactions without a payment method store NULL.
+func (m PaymentMethod) Value() (driver.Value, error) {
+	if m == "" {
+		return nil, nil
+	}
+	return string(m), nil
+}
+
+// Scan implements sql.Scanner.
+func (m *PaymentMethod) Scan(src any) error {
+	s, err := scanNullString(src)
+	*m = PaymentMethod(s)
+	return err
+}
+
+// scanNullString converts a nullable text column value to a string.
+func scanNullString(src any) (string, error) {
+	switch v := src.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	default:
+		return "", fmt.Errorf("cannot scan %T into string", src)
+	}
+}
@@ -3,14 +3,17 @@ package models
 import (
 	"time"
 
+	"github.com/3bd-dev/wallet-service/pkg/money"
 	"github.com/google/uuid"
 )
 
 type Wallet struct {
-	ID           uuid.UUID     `json:"id"`
-	CreatedAt    time.Time     `json:"created_at"`
-	UpdatedAt    time.Time     `json:"updated_at"`
-	Transactions []Transaction `json:"transactions,omitempty"`
+	ID           uuid.UUID      `json:"id"`
+	Currency     money.Currency `json:"currency"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	Balance      *Balance       `json:"balance,omitempty" gorm:"-"`
+	Transactions []Transaction  `json:"transactions,omitempty"`
 }
 
 func (t *Wallet) IsEmpty() bool {
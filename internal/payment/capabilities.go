@@ -0,0 +1,108 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+)
+
+// Capabilities describes the transactions a payment gateway supports.
+type Capabilities struct {
+	// Types are the supported transaction types.
+	Types []models.TransactionType `json:"transaction_types"`
+	// Methods are the supported payment methods per transaction type. Refunds go
+	// through the payment method of the refunded payment.
+	Methods map[models.TransactionType][]models.PaymentMethod `json:"methods"`
+	// Currencies are the supported currencies with their amount limits.
+	Currencies []CurrencyCapability `json:"currencies"`
+}
+
+// CurrencyCapability is a currency supported by a payment gateway, with the amount
+// limits of a transaction in it. Deposits and withdrawals outside the limits are
+// rejected, and routing skips the gateway for them. A zero limit is no limit.
+type CurrencyCapability struct {
+	Currency  money.Currency `json:"currency"`
+	MinAmount money.Money    `json:"min_amount,omitempty"`
+	MaxAmount money.Money    `json:"max_amount,omitempty"`
+}
+
+// WithAmountLimits returns a copy of the capabilities with the amount limits of the
+// currencies set from the maps keyed by currency code. Limits of currencies the
+// gateway does not support are ignored.
+func (c Capabilities) WithAmountLimits(min, max map[string]money.Money) Capabilities {
+	c.Currencies = slices.Clone(c.Currencies)
+	for i := range c.Currencies {
+		code := c.Currencies[i].Currency.String()
+		if amount, ok := min[code]; ok {
+			c.Currencies[i].MinAmount = amount
+		}
+		if amount, ok := max[code]; ok {
+			c.Currencies[i].MaxAmount = amount
+		}
+	}
+	return c
+}
+
+// VerifyMethod verifies the payment method is supported for the transaction type.
+func (c Capabilities) VerifyMethod(typ models.TransactionType, method models.PaymentMethod) error {
+	for _, m := range c.Methods[typ] {
+		if m == method {
+			return nil
+		}
+	}
+	return errs.New(errs.InvalidArgument, errors.New("unsupported payment method"))
+}
+
+// VerifyCurrency verifies the currency is supported.
+func (c Capabilities) VerifyCurrency(currency money.Currency) error {
+	_, err := c.currency(currency)
+	return err
+}
+
+// VerifyAmount verifies the currency is supported and the amount is within its limits.
+func (c Capabilities) VerifyAmount(amount money.Money, currency money.Currency) error {
+	cc, err := c.currency(currency)
+	if err != nil {
+		return err
+	}
+
+	if cc.MinAmount != 0 && amount < cc.MinAmount {
+		return errs.New(errs.InvalidArgument, fmt.Errorf("amount %s is below the minimum of %s %s", amount, cc.MinAmount, currency))
+	}
+	if cc.MaxAmount != 0 && amount > cc.MaxAmount {
+		return errs.New(errs.InvalidArgument, fmt.Errorf("amount %s is above the maximum of %s %s", amount, cc.MaxAmount, currency))
+	}
+	return nil
+}
+
+func (c Capabilities) currency(currency money.Currency) (*CurrencyCapability, error) {
+	for i := range c.Currencies {
+		if c.Currencies[i].Currency == currency {
+			return &c.Currencies[i], nil
+		}
+	}
+	return nil, errs.New(errs.InvalidArgument, fmt.Errorf("unsupported currency: %s", currency))
+}
+
+// BreakerState is the state of the circuit breaker of a payment gateway.
+type BreakerState string
+
+const (
+	// BreakerStateClosed lets requests through.
+	BreakerStateClosed BreakerState = "closed"
+	// BreakerStateHalfOpen lets a limited number of requests through to probe the gateway.
+	BreakerStateHalfOpen BreakerState = "half-open"
+	// BreakerStateOpen rejects requests until the breaker timeout elapses.
+	BreakerStateOpen BreakerState = "open"
+)
+
+// GatewayInfo describes a payment gateway registered in Payment.
+type GatewayInfo struct {
+	Name         models.PaymentGateway `json:"name"`
+	Capabilities Capabilities          `json:"capabilities"`
+	BreakerState BreakerState          `json:"breaker_state"`
+}
@@ -6,29 +6,51 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/3bd-dev/wallet-service/config"
 	"github.com/3bd-dev/wallet-service/internal/models"
 	"github.com/3bd-dev/wallet-service/internal/payment"
-	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
 	"github.com/3bd-dev/wallet-service/pkg/rest"
 	"github.com/sony/gobreaker/v2"
 )
 
-var supportedMethod = map[models.TransactionType][]models.PaymentMethod{
-	models.TransactionTypeDeposit: {
-		models.PaymentMethodCreditCard,
+// capabilities describes the transactions Gateway A supports.
+var capabilities = payment.Capabilities{
+	Types: []models.TransactionType{
+		models.TransactionTypeDeposit,
+		models.TransactionTypeWithdrawal,
+		models.TransactionTypeRefund,
 	},
-	models.TransactionTypeWithdrawal: {
-		models.PaymentMethodBankTransfer,
+	Methods: map[models.TransactionType][]models.PaymentMethod{
+		models.TransactionTypeDeposit: {
+			models.PaymentMethodCreditCard,
+		},
+		models.TransactionTypeWithdrawal: {
+			models.PaymentMethodBankTransfer,
+		},
+	},
+	Currencies: []payment.CurrencyCapability{
+		{Currency: money.USD},
+		{Currency: money.EUR},
+		{Currency: money.GBP},
 	},
 }
 
+// Headers of the callback signature, see payment.Signature.
+const (
+	signatureHeader = "X-Signature"
+	timestampHeader = "X-Signature-Timestamp"
+)
+
 // GatewayA represents the Gateway A payment gateway
 type GatewayA struct {
 	client *rest.Client
 	retier rest.Retrier
 	cb     *gobreaker.CircuitBreaker[[]byte]
+	sig    *payment.Signature
+	caps   payment.Capabilities
 }
 
 // NewGateway creates a new instance of Gateway A
@@ -46,22 +68,25 @@ func New(cfg config.PaymentGatewayA) payment.PaymentGateway {
 		client: rest.NewClient(cfg.BaseURL),
 		retier: rest.NewRetrier(cfg.RetryAttempt, cfg.RetryDelay),
 		cb:     gobreaker.NewCircuitBreaker[[]byte](cbSettings),
+		sig:    payment.NewSignature(cfg.CallbackSecret, cfg.CallbackTolerance),
+		caps:   capabilities.WithAmountLimits(cfg.MinAmounts, cfg.MaxAmounts),
 	}
 }
 
 // Deposit sends a deposit request to Gateway A
 func (g *GatewayA) Deposit(ctx context.Context, req *payment.Request) (*payment.Response, error) {
-	body, err := g.cb.Execute(func() ([]byte, error) {
-		requestBody := Request{
-			Amount:      req.Amount,
-			CallbackURL: req.CallbackURL,
-		}
-		resp, err := g.retry(ctx, "/deposit", requestBody, nil, g.client.Post)
+	requestBody, err := newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := g.execute(func() ([]byte, error) {
+		resp, err := g.retry(ctx, "/deposit", requestBody, idempotencyOptions(req.ID), g.client.Post)
 		if err != nil {
 			return nil, err
 		}
 
-		if resp.StatusCode != http.StatusOK {
+		if !accepted(resp) {
 			return nil, fmt.Errorf("failed to initiate deposit: %d - %s", resp.StatusCode, resp.Body)
 		}
 
@@ -72,27 +97,23 @@ func (g *GatewayA) Deposit(ctx context.Context, req *payment.Request) (*payment.
 		return nil, err
 	}
 
-	var res Response
-	if err := json.Unmarshal(body, &res); err != nil {
-		return nil, err
-	}
-	return &payment.Response{ID: res.ID, Status: toPaymentStatus(res.Status)}, nil
+	return toPaymentResponse(body)
 }
 
 // Withdrawal sends a withdrawal request to Gateway A
 func (g *GatewayA) Withdraw(ctx context.Context, req *payment.Request) (*payment.Response, error) {
-	body, err := g.cb.Execute(func() ([]byte, error) {
-		requestBody := Request{
-			Amount:      req.Amount,
-			CallbackURL: req.CallbackURL,
-		}
+	requestBody, err := newRequest(req)
+	if err != nil {
+		return nil, err
+	}
 
-		resp, err := g.retry(ctx, "/withdrawal", requestBody, nil, g.client.Post)
+	body, err := g.execute(func() ([]byte, error) {
+		resp, err := g.retry(ctx, "/withdrawal", requestBody, idempotencyOptions(req.ID), g.client.Post)
 		if err != nil {
 			return nil, err
 		}
 
-		if resp.StatusCode != http.StatusOK {
+		if !accepted(resp) {
 			return nil, fmt.Errorf("failed to initiate withdraw: : %d - %s", resp.StatusCode, string(resp.Body))
 		}
 
@@ -103,18 +124,47 @@ func (g *GatewayA) Withdraw(ctx context.Context, req *payment.Request) (*payment
 		return nil, err
 	}
 
-	var res Response
-	if err := json.Unmarshal(body, &res); err != nil {
+	return toPaymentResponse(body)
+}
+
+// Refund sends a refund request of a previous payment to Gateway A
+func (g *GatewayA) Refund(ctx context.Context, req *payment.Request) (*payment.Response, error) {
+	body, err := g.execute(func() ([]byte, error) {
+		requestBody := RefundRequest{
+			MerchantReference: req.ID,
+			ReferenceID:       req.ReferenceID,
+			Amount:            json.Number(req.Currency.Format(req.Amount)),
+			Currency:          req.Currency.String(),
+			CallbackURL:       req.CallbackURL,
+		}
+
+		resp, err := g.retry(ctx, "/refund", requestBody, idempotencyOptions(req.ID), g.client.Post)
+		if err != nil {
+			return nil, err
+		}
+
+		if !accepted(resp) {
+			return nil, fmt.Errorf("failed to initiate refund: %d - %s", resp.StatusCode, resp.Body)
+		}
+
+		return resp.Body, nil
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
-	return &payment.Response{ID: res.ID, Status: toPaymentStatus(res.Status)}, nil
+	return toPaymentResponse(body)
 }
 
-// VerifyCallback processes the callback from Gateway A
-func (g *GatewayA) VerifyCallback(ctx context.Context, refID string, data []byte) (*payment.Response, error) {
+// ParseCallback verifies the signature of a callback from Gateway A and decodes the payment it reports
+func (g *GatewayA) ParseCallback(ctx context.Context, cb *payment.Callback) (*payment.Response, error) {
+	if err := g.sig.Verify(cb.Header.Get(timestampHeader), cb.Header.Get(signatureHeader), cb.Body, cb.ReceivedAt); err != nil {
+		return nil, err
+	}
+
 	var res Response
-	if err := json.Unmarshal(data, &res); err != nil {
+	if err := json.Unmarshal(cb.Body, &res); err != nil {
 		return nil, err
 	}
 
@@ -122,31 +172,92 @@ func (g *GatewayA) VerifyCallback(ctx context.Context, refID string, data []byte
 		return nil, errors.New("failed to process transaction")
 	}
 
+	return &payment.Response{ID: res.ID, Status: toPaymentStatus(res.Status)}, nil
+}
+
+// GetStatus queries Gateway A for the status of a payment
+func (g *GatewayA) GetStatus(ctx context.Context, refID string) (*payment.Response, error) {
+	body, err := g.execute(func() ([]byte, error) {
+		resp, err := g.retry(ctx, "/status?id="+url.QueryEscape(refID), nil, nil, g.client.Get)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to get status: %d - %s", resp.StatusCode, resp.Body)
+		}
+
+		return resp.Body, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var res Response
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+
 	if refID != res.ID {
-		return nil, errs.New(errs.InvalidArgument, errors.New("invalid reference ID"))
+		return nil, errors.New("invalid reference ID")
 	}
 
 	return &payment.Response{ID: res.ID, Status: toPaymentStatus(res.Status)}, nil
 }
 
-// VerifyMethod verifies the payment method details
-func (g *GatewayA) VerifyMethod(typ models.TransactionType, method models.PaymentMethod) error {
-	if methods, ok := supportedMethod[typ]; ok {
-		for _, m := range methods {
-			if m == method {
-				return nil
-			}
+// GetStatusByReference queries Gateway A for the status of the payment requested with the merchant reference
+func (g *GatewayA) GetStatusByReference(ctx context.Context, reference string) (*payment.Response, error) {
+	body, err := g.execute(func() ([]byte, error) {
+		resp, err := g.retry(ctx, "/status?merchant_reference="+url.QueryEscape(reference), nil, nil, g.client.Get)
+		if err != nil {
+			return nil, err
+		}
+
+		// no request with the reference reached the gateway, which is not a gateway failure.
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to get status: %d - %s", resp.StatusCode, resp.Body)
 		}
+
+		return resp.Body, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if body == nil {
+		return nil, fmt.Errorf("%w: %s", payment.ErrPaymentNotFound, reference)
 	}
-	return errs.New(errs.InvalidArgument, errors.New("unsupported payment method"))
+
+	return toPaymentResponse(body)
+}
+
+// Capabilities returns the transactions Gateway A supports
+func (g *GatewayA) Capabilities() payment.Capabilities {
+	return g.caps
+}
+
+// BreakerState returns the current state of the circuit breaker of Gateway A
+func (g *GatewayA) BreakerState() payment.BreakerState {
+	return payment.BreakerState(g.cb.State().String())
 }
 
 // retry sends a request to the gateway and retries if it fails
 func (g *GatewayA) retry(ctx context.Context, url string, body any, options *rest.RequestOptions, fn func(ctx context.Context, reqURL string, body interface{}, options *rest.RequestOptions) (*rest.Response, error)) (*rest.Response, error) {
 	var resp *rest.Response
+	// the request might have reached the gateway unless every attempt failed to connect.
+	sent := false
 	err := g.retier.Do(ctx, func(ctx context.Context, i int) error {
 		var err error
 		resp, err = fn(ctx, url, body, options)
+		if !payment.IsDialError(err) {
+			sent = true
+		}
 		if err != nil {
 			return err
 		}
@@ -156,8 +267,98 @@ func (g *GatewayA) retry(ctx context.Context, url string, body any, options *res
 		}
 		return err
 	})
+	if err != nil && !sent {
+		return resp, fmt.Errorf("%w: %w: %w", payment.ErrUnavailable, payment.ErrNotSent, err)
+	}
+	if err != nil {
+		return resp, fmt.Errorf("%w: %w", payment.ErrUnavailable, err)
+	}
+
+	return resp, nil
+}
+
+// execute runs fn through the circuit breaker. Requests rejected by the breaker
+// report the gateway as unavailable.
+func (g *GatewayA) execute(fn func() ([]byte, error)) ([]byte, error) {
+	body, err := g.cb.Execute(fn)
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return nil, fmt.Errorf("%w: %w: %w", payment.ErrUnavailable, payment.ErrNotSent, err)
+	}
+	return body, err
+}
+
+// newRequest builds the Gateway A payment request, including the details of its payment method.
+func newRequest(req *payment.Request) (Request, error) {
+	requestBody := Request{
+		MerchantReference: req.ID,
+		Amount:            json.Number(req.Currency.Format(req.Amount)),
+		Currency:          req.Currency.String(),
+		CallbackURL:       req.CallbackURL,
+	}
+
+	switch req.PaymentMethod {
+	case models.PaymentMethodCreditCard:
+		card, err := req.CreditCard()
+		if err != nil {
+			return Request{}, err
+		}
+
+		month, year, err := card.ExpiryDate()
+		if err != nil {
+			return Request{}, err
+		}
+
+		requestBody.Card = &Card{
+			Number:   card.Number,
+			ExpMonth: month,
+			ExpYear:  year,
+			CVC:      card.CVV,
+		}
+	case models.PaymentMethodBankTransfer:
+		account, err := req.BankAccount()
+		if err != nil {
+			return Request{}, err
+		}
+
+		requestBody.BankAccount = &BankAccount{
+			AccountNumber:   account.AccountNumber,
+			RoutingCode:     account.BankCode,
+			RoutingCodeType: account.BankCodeType,
+		}
+	default:
+		return Request{}, fmt.Errorf("unsupported payment method: %s", req.PaymentMethod)
+	}
 
-	return resp, err
+	return requestBody, nil
+}
+
+// idempotencyOptions sets the idempotency header of a request to the merchant reference.
+func idempotencyOptions(reference string) *rest.RequestOptions {
+	return &rest.RequestOptions{
+		Headers: http.Header{
+			"Idempotency-Key": []string{reference},
+		},
+	}
+}
+
+// accepted reports whether Gateway A accepted a payment request. A conflict means the
+// merchant reference was already used, the body then describes the original payment.
+func accepted(resp *rest.Response) bool {
+	return resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusConflict
+}
+
+// toPaymentResponse decodes the payment described by a Gateway A response.
+func toPaymentResponse(body []byte) (*payment.Response, error) {
+	var res Response
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+
+	if res.ID == "" {
+		return nil, errors.New("missing payment reference ID")
+	}
+
+	return &payment.Response{ID: res.ID, Status: toPaymentStatus(res.Status)}, nil
 }
 
 func toPaymentStatus(status string) payment.PaymentStatus {
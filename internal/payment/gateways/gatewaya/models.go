@@ -1,8 +1,36 @@
 package gatewaya
 
+import "encoding/json"
+
 type Request struct {
-	Amount      float64 `json:"amount"`
-	CallbackURL string  `json:"callback_url"`
+	MerchantReference string      `json:"merchant_reference"`
+	Amount            json.Number `json:"amount"`
+	Currency          string      `json:"currency"`
+	CallbackURL       string      `json:"callback_url"`
+	// Card is set for credit card payments, BankAccount for bank transfers.
+	Card        *Card        `json:"card,omitempty"`
+	BankAccount *BankAccount `json:"bank_account,omitempty"`
+}
+
+type Card struct {
+	Number   string `json:"number"`
+	ExpMonth int    `json:"exp_month"`
+	ExpYear  int    `json:"exp_year"`
+	CVC      string `json:"cvc"`
+}
+
+type BankAccount struct {
+	AccountNumber   string `json:"account_number"`
+	RoutingCode     string `json:"routing_code"`
+	RoutingCodeType string `json:"routing_code_type"`
+}
+
+type RefundRequest struct {
+	MerchantReference string      `json:"merchant_reference"`
+	ReferenceID       string      `json:"reference_id"`
+	Amount            json.Number `json:"amount"`
+	Currency          string      `json:"currency"`
+	CallbackURL       string      `json:"callback_url"`
 }
 
 type Response struct {
@@ -10,25 +10,48 @@ import (
 	"github.com/3bd-dev/wallet-service/config"
 	"github.com/3bd-dev/wallet-service/internal/models"
 	"github.com/3bd-dev/wallet-service/internal/payment"
-	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
 	"github.com/3bd-dev/wallet-service/pkg/rest"
 	"github.com/sony/gobreaker/v2"
 )
 
-var supportedMethod = map[models.TransactionType][]models.PaymentMethod{
-	models.TransactionTypeDeposit: {
-		models.PaymentMethodCreditCard,
+// capabilities describes the transactions Gateway B supports.
+var capabilities = payment.Capabilities{
+	Types: []models.TransactionType{
+		models.TransactionTypeDeposit,
+		models.TransactionTypeWithdrawal,
+		models.TransactionTypeRefund,
 	},
-	models.TransactionTypeWithdrawal: {
-		models.PaymentMethodBankTransfer,
+	Methods: map[models.TransactionType][]models.PaymentMethod{
+		models.TransactionTypeDeposit: {
+			models.PaymentMethodCreditCard,
+		},
+		models.TransactionTypeWithdrawal: {
+			models.PaymentMethodBankTransfer,
+		},
+	},
+	Currencies: []payment.CurrencyCapability{
+		{Currency: money.USD},
+		{Currency: money.EUR},
+		{Currency: money.AED},
+		{Currency: money.SAR},
+		{Currency: money.JPY},
 	},
 }
 
+// Headers of the callback signature, see payment.Signature.
+const (
+	signatureHeader = "X-Signature"
+	timestampHeader = "X-Signature-Timestamp"
+)
+
 // GatewayB is a concrete implementation of the PaymentGateway To Gateway B
 type GatewayB struct {
 	client *rest.Client
 	retier rest.Retrier
 	cb     *gobreaker.CircuitBreaker[[]byte]
+	sig    *payment.Signature
+	caps   payment.Capabilities
 }
 
 // NewGatewayB creates a new instance of Gateway B
@@ -46,21 +69,56 @@ func New(cfg config.PaymentGatewayB) payment.PaymentGateway {
 		client: rest.NewClient(cfg.BaseURL),
 		retier: rest.NewRetrier(cfg.RetryAttempt, cfg.RetryDelay),
 		cb:     gobreaker.NewCircuitBreaker[[]byte](cbSettings),
+		sig:    payment.NewSignature(cfg.CallbackSecret, cfg.CallbackTolerance),
+		caps:   capabilities.WithAmountLimits(cfg.MinAmounts, cfg.MaxAmounts),
 	}
 }
 
 // Deposit sends a deposit request to Gateway B using SOAP/XML
 func (g *GatewayB) Deposit(ctx context.Context, req *payment.Request) (*payment.Response, error) {
+	requestBody, err := newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := g.execute(func() ([]byte, error) {
+		resp, err := g.retry(ctx, "/deposit", requestBody, &rest.RequestOptions{
+			Headers: http.Header{
+				"Content-Type":    []string{rest.XMLContentType},
+				"Idempotency-Key": []string{requestBody.MerchantReference},
+			},
+		}, g.client.Post)
 
-	body, err := g.cb.Execute(func() ([]byte, error) {
-		req := &Request{
-			Amount:      req.Amount,
-			CallbackURL: req.CallbackURL,
+		if err != nil {
+			return nil, err
 		}
 
-		resp, err := g.retry(ctx, "/deposit", req, &rest.RequestOptions{
+		if !accepted(resp) {
+			return nil, fmt.Errorf("failed to initiate deposit: %d - %s", resp.StatusCode, resp.Body)
+		}
+
+		return resp.Body, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return toPaymentResponse(body)
+}
+
+// Withdraw sends a withdrawal request to Gateway B using SOAP/XML
+func (g *GatewayB) Withdraw(ctx context.Context, req *payment.Request) (*payment.Response, error) {
+	requestBody, err := newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := g.execute(func() ([]byte, error) {
+		resp, err := g.retry(ctx, "/withdraw", requestBody, &rest.RequestOptions{
 			Headers: http.Header{
-				"Content-Type": []string{rest.XMLContentType},
+				"Content-Type":    []string{rest.XMLContentType},
+				"Idempotency-Key": []string{requestBody.MerchantReference},
 			},
 		}, g.client.Post)
 
@@ -68,8 +126,8 @@ func (g *GatewayB) Deposit(ctx context.Context, req *payment.Request) (*payment.
 			return nil, err
 		}
 
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("failed to initiate deposit: %d - %s", resp.StatusCode, resp.Body)
+		if !accepted(resp) {
+			return nil, fmt.Errorf("failed to initiate withdraw: %d - %s", resp.StatusCode, resp.Body)
 		}
 
 		return resp.Body, nil
@@ -79,24 +137,72 @@ func (g *GatewayB) Deposit(ctx context.Context, req *payment.Request) (*payment.
 		return nil, err
 	}
 
-	var result Response
-	if err := xml.Unmarshal(body, &result); err != nil {
+	return toPaymentResponse(body)
+}
+
+// Refund sends a refund request of a previous payment to Gateway B using SOAP/XML
+func (g *GatewayB) Refund(ctx context.Context, req *payment.Request) (*payment.Response, error) {
+
+	body, err := g.execute(func() ([]byte, error) {
+		req := &RefundRequest{
+			MerchantReference: req.ID,
+			ReferenceID:       req.ReferenceID,
+			Amount:            req.Currency.Format(req.Amount),
+			Currency:          req.Currency.String(),
+			CallbackURL:       req.CallbackURL,
+		}
+
+		resp, err := g.retry(ctx, "/refund", req, &rest.RequestOptions{
+			Headers: http.Header{
+				"Content-Type":    []string{rest.XMLContentType},
+				"Idempotency-Key": []string{req.MerchantReference},
+			},
+		}, g.client.Post)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !accepted(resp) {
+			return nil, fmt.Errorf("failed to initiate refund: %d - %s", resp.StatusCode, resp.Body)
+		}
+
+		return resp.Body, nil
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
-	return &payment.Response{ID: result.Body.ReferenceID, Status: toPaymentStatus(result.Body.Status)}, nil
+	return toPaymentResponse(body)
 }
 
-// Withdraw sends a withdrawal request to Gateway B using SOAP/XML
-func (g *GatewayB) Withdraw(ctx context.Context, req *payment.Request) (*payment.Response, error) {
+// ParseCallback verifies the signature of a callback from Gateway B and decodes the payment it reports
+func (g *GatewayB) ParseCallback(ctx context.Context, cb *payment.Callback) (*payment.Response, error) {
+	if err := g.sig.Verify(cb.Header.Get(timestampHeader), cb.Header.Get(signatureHeader), cb.Body, cb.ReceivedAt); err != nil {
+		return nil, err
+	}
+
+	var res Response
+	if err := xml.Unmarshal(cb.Body, &res); err != nil {
+		return nil, err
+	}
+
+	if res.Body.Status == "" {
+		return nil, errors.New("failed to process callback")
+	}
+
+	return &payment.Response{ID: res.Body.ReferenceID, Status: toPaymentStatus(res.Body.Status)}, nil
+}
 
-	body, err := g.cb.Execute(func() ([]byte, error) {
-		req := &Request{
-			Amount:      req.Amount,
-			CallbackURL: req.CallbackURL,
+// GetStatus queries Gateway B for the status of a payment using SOAP/XML
+func (g *GatewayB) GetStatus(ctx context.Context, refID string) (*payment.Response, error) {
+	body, err := g.execute(func() ([]byte, error) {
+		req := &StatusRequest{
+			ReferenceID: refID,
 		}
 
-		resp, err := g.retry(ctx, "/withdraw", req, &rest.RequestOptions{
+		resp, err := g.retry(ctx, "/status", req, &rest.RequestOptions{
 			Headers: http.Header{
 				"Content-Type": []string{rest.XMLContentType},
 			},
@@ -107,7 +213,7 @@ func (g *GatewayB) Withdraw(ctx context.Context, req *payment.Request) (*payment
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("failed to initiate withdraw: %d - %s", resp.StatusCode, resp.Body)
+			return nil, fmt.Errorf("failed to get status: %d - %s", resp.StatusCode, resp.Body)
 		}
 
 		return resp.Body, nil
@@ -122,45 +228,74 @@ func (g *GatewayB) Withdraw(ctx context.Context, req *payment.Request) (*payment
 		return nil, err
 	}
 
+	if refID != result.Body.ReferenceID {
+		return nil, errors.New("invalid reference ID")
+	}
+
 	return &payment.Response{ID: result.Body.ReferenceID, Status: toPaymentStatus(result.Body.Status)}, nil
 }
 
-// VerifyCallback verifies the callback from Gateway B
-func (g *GatewayB) VerifyCallback(ctx context.Context, refID string, data []byte) (*payment.Response, error) {
-	var res Response
-	if err := xml.Unmarshal(data, &res); err != nil {
+// GetStatusByReference queries Gateway B for the status of the payment requested with the merchant reference
+func (g *GatewayB) GetStatusByReference(ctx context.Context, reference string) (*payment.Response, error) {
+	body, err := g.execute(func() ([]byte, error) {
+		req := &StatusRequest{
+			MerchantReference: reference,
+		}
+
+		resp, err := g.retry(ctx, "/status", req, &rest.RequestOptions{
+			Headers: http.Header{
+				"Content-Type": []string{rest.XMLContentType},
+			},
+		}, g.client.Post)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// no request with the reference reached the gateway, which is not a gateway failure.
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to get status: %d - %s", resp.StatusCode, resp.Body)
+		}
+
+		return resp.Body, nil
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
-	if res.Body.Status == "" {
-		return nil, errors.New("failed to process callback")
+	if body == nil {
+		return nil, fmt.Errorf("%w: %s", payment.ErrPaymentNotFound, reference)
 	}
 
-	if refID != res.Body.ReferenceID {
-		return nil, errs.New(errs.InvalidArgument, errors.New("invalid reference ID"))
-	}
+	return toPaymentResponse(body)
+}
 
-	return &payment.Response{ID: res.Body.ReferenceID, Status: toPaymentStatus(res.Body.Status)}, nil
+// Capabilities returns the transactions Gateway B supports
+func (g *GatewayB) Capabilities() payment.Capabilities {
+	return g.caps
 }
 
-// VerifyMethod verifies the payment method details
-func (g *GatewayB) VerifyMethod(typ models.TransactionType, method models.PaymentMethod) error {
-	if methods, ok := supportedMethod[typ]; ok {
-		for _, m := range methods {
-			if m == method {
-				return nil
-			}
-		}
-	}
-	return errs.New(errs.InvalidArgument, errors.New("unsupported payment method"))
+// BreakerState returns the current state of the circuit breaker of Gateway B
+func (g *GatewayB) BreakerState() payment.BreakerState {
+	return payment.BreakerState(g.cb.State().String())
 }
 
 // retry retries the request if it fails
 func (g *GatewayB) retry(ctx context.Context, url string, body any, options *rest.RequestOptions, fn func(ctx context.Context, reqURL string, body interface{}, options *rest.RequestOptions) (*rest.Response, error)) (*rest.Response, error) {
 	var resp *rest.Response
+	// the request might have reached the gateway unless every attempt failed to connect.
+	sent := false
 	err := g.retier.Do(ctx, func(ctx context.Context, _ int) error {
 		var err error
 		resp, err = fn(ctx, url, body, options)
+		if !payment.IsDialError(err) {
+			sent = true
+		}
 		if err != nil {
 			return err
 		}
@@ -171,8 +306,88 @@ func (g *GatewayB) retry(ctx context.Context, url string, body any, options *res
 
 		return err
 	})
+	if err != nil && !sent {
+		return resp, fmt.Errorf("%w: %w: %w", payment.ErrUnavailable, payment.ErrNotSent, err)
+	}
+	if err != nil {
+		return resp, fmt.Errorf("%w: %w", payment.ErrUnavailable, err)
+	}
 
-	return resp, err
+	return resp, nil
+}
+
+// execute runs fn through the circuit breaker. Requests rejected by the breaker
+// report the gateway as unavailable.
+func (g *GatewayB) execute(fn func() ([]byte, error)) ([]byte, error) {
+	body, err := g.cb.Execute(fn)
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return nil, fmt.Errorf("%w: %w: %w", payment.ErrUnavailable, payment.ErrNotSent, err)
+	}
+	return body, err
+}
+
+// newRequest builds the Gateway B payment request, including the details of its payment method.
+func newRequest(req *payment.Request) (*Request, error) {
+	requestBody := &Request{
+		MerchantReference: req.ID,
+		Amount:            req.Currency.Format(req.Amount),
+		Currency:          req.Currency.String(),
+		CallbackURL:       req.CallbackURL,
+	}
+
+	switch req.PaymentMethod {
+	case models.PaymentMethodCreditCard:
+		card, err := req.CreditCard()
+		if err != nil {
+			return nil, err
+		}
+
+		month, year, err := card.ExpiryDate()
+		if err != nil {
+			return nil, err
+		}
+
+		requestBody.Card = &Card{
+			PAN:        card.Number,
+			ExpiryDate: fmt.Sprintf("%02d%02d", month, year%100),
+			CVV:        card.CVV,
+		}
+	case models.PaymentMethodBankTransfer:
+		account, err := req.BankAccount()
+		if err != nil {
+			return nil, err
+		}
+
+		requestBody.Beneficiary = &Beneficiary{
+			Account:      account.AccountNumber,
+			BankCode:     account.BankCode,
+			BankCodeType: account.BankCodeType,
+		}
+	default:
+		return nil, fmt.Errorf("unsupported payment method: %s", req.PaymentMethod)
+	}
+
+	return requestBody, nil
+}
+
+// accepted reports whether Gateway B accepted a payment request. A conflict means the
+// merchant reference was already used, the body then describes the original payment.
+func accepted(resp *rest.Response) bool {
+	return resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusConflict
+}
+
+// toPaymentResponse decodes the payment described by a Gateway B response.
+func toPaymentResponse(body []byte) (*payment.Response, error) {
+	var result Response
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	if result.Body.ReferenceID == "" {
+		return nil, errors.New("missing payment reference ID")
+	}
+
+	return &payment.Response{ID: result.Body.ReferenceID, Status: toPaymentStatus(result.Body.Status)}, nil
 }
 
 func toPaymentStatus(status string) payment.PaymentStatus {
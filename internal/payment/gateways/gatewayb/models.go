@@ -3,9 +3,43 @@ package gatewayb
 import "encoding/xml"
 
 type Request struct {
-	XMLName     xml.Name `xml:"SOAP-ENV:Envelope"`
-	Amount      float64  `xml:"SOAP-ENV:Body>amount"`
-	CallbackURL string   `xml:"SOAP-ENV:Body>callback_url"`
+	XMLName           xml.Name `xml:"SOAP-ENV:Envelope"`
+	MerchantReference string   `xml:"SOAP-ENV:Body>merchant_reference"`
+	Amount            string   `xml:"SOAP-ENV:Body>amount"`
+	Currency          string   `xml:"SOAP-ENV:Body>currency"`
+	CallbackURL       string   `xml:"SOAP-ENV:Body>callback_url"`
+	// Card is set for credit card payments, Beneficiary for bank transfers.
+	Card        *Card        `xml:"SOAP-ENV:Body>card,omitempty"`
+	Beneficiary *Beneficiary `xml:"SOAP-ENV:Body>beneficiary,omitempty"`
+}
+
+type Card struct {
+	PAN        string `xml:"pan"`
+	ExpiryDate string `xml:"expiry_date"` // MMYY
+	CVV        string `xml:"cvv"`
+}
+
+type Beneficiary struct {
+	Account      string `xml:"account"`
+	BankCode     string `xml:"bank_code"`
+	BankCodeType string `xml:"bank_code_type"`
+}
+
+type RefundRequest struct {
+	XMLName           xml.Name `xml:"SOAP-ENV:Envelope"`
+	MerchantReference string   `xml:"SOAP-ENV:Body>merchant_reference"`
+	ReferenceID       string   `xml:"SOAP-ENV:Body>reference_id"`
+	Amount            string   `xml:"SOAP-ENV:Body>amount"`
+	Currency          string   `xml:"SOAP-ENV:Body>currency"`
+	CallbackURL       string   `xml:"SOAP-ENV:Body>callback_url"`
+}
+
+// StatusRequest queries a payment by its reference ID, or by the merchant reference it
+// was requested with.
+type StatusRequest struct {
+	XMLName           xml.Name `xml:"SOAP-ENV:Envelope"`
+	ReferenceID       string   `xml:"SOAP-ENV:Body>reference_id"`
+	MerchantReference string   `xml:"SOAP-ENV:Body>merchant_reference,omitempty"`
 }
 
 type Response struct {
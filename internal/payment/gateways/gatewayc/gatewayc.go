@@ -0,0 +1,403 @@
+package gatewayc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/3bd-dev/wallet-service/config"
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/internal/payment"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/3bd-dev/wallet-service/pkg/rest"
+	"github.com/sony/gobreaker/v2"
+)
+
+// capabilities describes the transactions Gateway C supports.
+var capabilities = payment.Capabilities{
+	Types: []models.TransactionType{
+		models.TransactionTypeDeposit,
+		models.TransactionTypeWithdrawal,
+		models.TransactionTypeRefund,
+	},
+	Methods: map[models.TransactionType][]models.PaymentMethod{
+		models.TransactionTypeDeposit: {
+			models.PaymentMethodCreditCard,
+		},
+		models.TransactionTypeWithdrawal: {
+			models.PaymentMethodBankTransfer,
+		},
+	},
+	Currencies: []payment.CurrencyCapability{
+		{Currency: money.USD},
+		{Currency: money.EUR},
+		{Currency: money.GBP},
+		{Currency: money.JPY},
+	},
+}
+
+// signatureHeader holds the webhook signature as "t=<timestamp>,v1=<signature>", see payment.Signature.
+const signatureHeader = "Gateway-C-Signature"
+
+// GatewayC represents the Gateway C payment gateway, a REST API authenticated with
+// OAuth2 client credentials, taking amounts in minor units.
+type GatewayC struct {
+	client *rest.Client
+	retier rest.Retrier
+	cb     *gobreaker.CircuitBreaker[[]byte]
+	sig    *payment.Signature
+	caps   payment.Capabilities
+	tokens *tokenSource
+}
+
+// New creates a new instance of Gateway C
+func New(cfg config.PaymentGatewayC) payment.PaymentGateway {
+	cbSettings := gobreaker.Settings{
+		Name:        "GatewayC",
+		MaxRequests: cfg.CBMaxRequests,
+		Interval:    cfg.CBInterval,
+		Timeout:     cfg.CBTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures > cfg.CBMaxConsecutiveFailures || counts.TotalFailures > cfg.CBMaxTotalFailures
+		},
+	}
+
+	tokenURL := cfg.TokenURL
+	if tokenURL == "" {
+		tokenURL = cfg.BaseURL + "/oauth/token"
+	}
+
+	return &GatewayC{
+		client: rest.NewClient(cfg.BaseURL),
+		retier: rest.NewRetrier(cfg.RetryAttempt, cfg.RetryDelay),
+		cb:     gobreaker.NewCircuitBreaker[[]byte](cbSettings),
+		sig:    payment.NewSignature(cfg.CallbackSecret, cfg.CallbackTolerance),
+		caps:   capabilities.WithAmountLimits(cfg.MinAmounts, cfg.MaxAmounts),
+		tokens: newTokenSource(tokenURL, cfg.ClientID, cfg.ClientSecret, cfg.TokenTimeout),
+	}
+}
+
+// Deposit sends a payment request to Gateway C
+func (g *GatewayC) Deposit(ctx context.Context, req *payment.Request) (*payment.Response, error) {
+	return g.submit(ctx, "/v1/payments", req)
+}
+
+// Withdraw sends a payout request to Gateway C
+func (g *GatewayC) Withdraw(ctx context.Context, req *payment.Request) (*payment.Response, error) {
+	return g.submit(ctx, "/v1/payouts", req)
+}
+
+// Refund sends a refund request of a previous payment to Gateway C
+func (g *GatewayC) Refund(ctx context.Context, req *payment.Request) (*payment.Response, error) {
+	amount, err := req.Currency.ToMinorUnits(req.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	requestBody := RefundRequest{
+		Reference:  req.ID,
+		PaymentID:  req.ReferenceID,
+		Amount:     amount,
+		Currency:   req.Currency.String(),
+		WebhookURL: req.CallbackURL,
+	}
+
+	return g.post(ctx, "/v1/refunds", req.ID, requestBody)
+}
+
+// ParseCallback verifies the signature of a webhook event from Gateway C and decodes the transaction it reports
+func (g *GatewayC) ParseCallback(ctx context.Context, cb *payment.Callback) (*payment.Response, error) {
+	timestamp, signature := parseSignature(cb.Header.Get(signatureHeader))
+	if err := g.sig.Verify(timestamp, signature, cb.Body, cb.ReceivedAt); err != nil {
+		return nil, err
+	}
+
+	var event Event
+	if err := json.Unmarshal(cb.Body, &event); err != nil {
+		return nil, err
+	}
+
+	if event.Data.ID == "" || event.Data.Status == "" {
+		return nil, errors.New("failed to process transaction")
+	}
+
+	return &payment.Response{ID: event.Data.ID, Status: toPaymentStatus(event.Data.Status)}, nil
+}
+
+// GetStatus queries Gateway C for the status of a transaction
+func (g *GatewayC) GetStatus(ctx context.Context, refID string) (*payment.Response, error) {
+	body, err := g.execute(func() ([]byte, error) {
+		resp, err := g.retry(ctx, "/v1/transactions/"+url.PathEscape(refID), nil, nil, g.client.Get)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to get status: %d - %s", resp.StatusCode, resp.Body)
+		}
+
+		return resp.Body, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := toPaymentResponse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if refID != res.ID {
+		return nil, errors.New("invalid reference ID")
+	}
+
+	return res, nil
+}
+
+// GetStatusByReference queries Gateway C for the status of the transaction created with
+// the reference as idempotency key
+func (g *GatewayC) GetStatusByReference(ctx context.Context, reference string) (*payment.Response, error) {
+	body, err := g.execute(func() ([]byte, error) {
+		resp, err := g.retry(ctx, "/v1/transactions?reference="+url.QueryEscape(reference), nil, nil, g.client.Get)
+		if err != nil {
+			return nil, err
+		}
+
+		// no request with the reference reached the gateway, which is not a gateway failure.
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to get status: %d - %s", resp.StatusCode, resp.Body)
+		}
+
+		return resp.Body, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if body == nil {
+		return nil, fmt.Errorf("%w: %s", payment.ErrPaymentNotFound, reference)
+	}
+
+	return toPaymentResponse(body)
+}
+
+// Capabilities returns the transactions Gateway C supports
+func (g *GatewayC) Capabilities() payment.Capabilities {
+	return g.caps
+}
+
+// BreakerState returns the current state of the circuit breaker of Gateway C
+func (g *GatewayC) BreakerState() payment.BreakerState {
+	return payment.BreakerState(g.cb.State().String())
+}
+
+// submit sends a payment or payout request to Gateway C
+func (g *GatewayC) submit(ctx context.Context, path string, req *payment.Request) (*payment.Response, error) {
+	requestBody, err := newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.post(ctx, path, req.ID, requestBody)
+}
+
+// post sends a request creating a transaction to Gateway C
+func (g *GatewayC) post(ctx context.Context, path, reference string, requestBody any) (*payment.Response, error) {
+	body, err := g.execute(func() ([]byte, error) {
+		resp, err := g.retry(ctx, path, requestBody, idempotencyOptions(reference), g.client.Post)
+		if err != nil {
+			return nil, err
+		}
+
+		if !accepted(resp) {
+			return nil, fmt.Errorf("failed to create transaction: %d - %s", resp.StatusCode, resp.Body)
+		}
+
+		return resp.Body, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return toPaymentResponse(body)
+}
+
+// retry sends an authorized request to the gateway and retries if it fails. A request
+// rejected with 401 is retried with a new token, as the cached one might be revoked.
+func (g *GatewayC) retry(ctx context.Context, url string, body any, options *rest.RequestOptions, fn func(ctx context.Context, reqURL string, body interface{}, options *rest.RequestOptions) (*rest.Response, error)) (*rest.Response, error) {
+	var resp *rest.Response
+	// the request might have reached the gateway unless every attempt failed to connect,
+	// to acquire a token, or was rejected for its token.
+	sent := false
+	err := g.retier.Do(ctx, func(ctx context.Context, _ int) error {
+		token, err := g.tokens.Token(ctx)
+		if err != nil {
+			return err
+		}
+
+		resp, err = fn(ctx, url, body, authorize(options, token))
+		if err != nil {
+			if !payment.IsDialError(err) {
+				sent = true
+			}
+			return err
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized {
+			g.tokens.Invalidate(token)
+			return errors.New("access token rejected")
+		}
+
+		sent = true
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return errors.New("invalid response")
+		}
+		return nil
+	})
+	if err != nil && !sent {
+		return resp, fmt.Errorf("%w: %w: %w", payment.ErrUnavailable, payment.ErrNotSent, err)
+	}
+	if err != nil {
+		return resp, fmt.Errorf("%w: %w", payment.ErrUnavailable, err)
+	}
+
+	return resp, nil
+}
+
+// execute runs fn through the circuit breaker. Requests rejected by the breaker
+// report the gateway as unavailable.
+func (g *GatewayC) execute(fn func() ([]byte, error)) ([]byte, error) {
+	body, err := g.cb.Execute(fn)
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return nil, fmt.Errorf("%w: %w: %w", payment.ErrUnavailable, payment.ErrNotSent, err)
+	}
+	return body, err
+}
+
+// newRequest builds the Gateway C payment or payout request, including the details of its payment method.
+func newRequest(req *payment.Request) (*Request, error) {
+	amount, err := req.Currency.ToMinorUnits(req.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	requestBody := &Request{
+		Reference:  req.ID,
+		Amount:     amount,
+		Currency:   req.Currency.String(),
+		WebhookURL: req.CallbackURL,
+	}
+
+	switch req.PaymentMethod {
+	case models.PaymentMethodCreditCard:
+		card, err := req.CreditCard()
+		if err != nil {
+			return nil, err
+		}
+
+		month, year, err := card.ExpiryDate()
+		if err != nil {
+			return nil, err
+		}
+
+		requestBody.Card = &Card{
+			Number:      card.Number,
+			ExpiryMonth: month,
+			ExpiryYear:  year,
+			CVC:         card.CVV,
+		}
+	case models.PaymentMethodBankTransfer:
+		account, err := req.BankAccount()
+		if err != nil {
+			return nil, err
+		}
+
+		requestBody.Beneficiary = &Beneficiary{
+			AccountNumber: account.AccountNumber,
+			BankCode:      account.BankCode,
+			BankCodeType:  account.BankCodeType,
+		}
+	default:
+		return nil, fmt.Errorf("unsupported payment method: %s", req.PaymentMethod)
+	}
+
+	return requestBody, nil
+}
+
+// authorize returns the request options with the bearer token.
+func authorize(options *rest.RequestOptions, token string) *rest.RequestOptions {
+	res := rest.NewRequestOptions()
+	if options != nil {
+		res.Headers = options.Headers.Clone()
+		res.Timeout = options.Timeout
+	}
+	res.Headers.Set("Authorization", "Bearer "+token)
+	return &res
+}
+
+func idempotencyOptions(reference string) *rest.RequestOptions {
+	return &rest.RequestOptions{
+		Headers: http.Header{
+			"Idempotency-Key": []string{reference},
+		},
+	}
+}
+
+// accepted reports whether Gateway C accepted a request creating a transaction. A
+// request repeating an idempotency key gets the original transaction back with 200.
+func accepted(resp *rest.Response) bool {
+	return resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusOK
+}
+
+// parseSignature splits a "t=<timestamp>,v1=<signature>" signature header.
+func parseSignature(header string) (timestamp, signature string) {
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	return timestamp, signature
+}
+
+// toPaymentResponse decodes the transaction described by a Gateway C response.
+func toPaymentResponse(body []byte) (*payment.Response, error) {
+	var res Transaction
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+
+	if res.ID == "" {
+		return nil, errs.New(errs.Internal, errors.New("missing transaction ID"))
+	}
+
+	return &payment.Response{ID: res.ID, Status: toPaymentStatus(res.Status)}, nil
+}
+
+func toPaymentStatus(status string) payment.PaymentStatus {
+	switch status {
+	case "succeeded":
+		return payment.PaymentStatusSuccess
+	case "processing":
+		return payment.PaymentStatusPending
+	case "failed", "declined":
+		return payment.PaymentStatusFailed
+	default:
+		return payment.PaymentStatusUnknown
+	}
+}
@@ -0,0 +1,339 @@
+package gatewayc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/3bd-dev/wallet-service/config"
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/internal/payment"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/3bd-dev/wallet-service/pkg/unitest"
+)
+
+func Test_GatewayC(t *testing.T) {
+	t.Parallel()
+
+	unitest.Run(t, tokens(t), "tokens")
+	unitest.Run(t, minorUnits(), "minorUnits")
+	unitest.Run(t, signatures(), "parseSignature")
+}
+
+// mockServer is a Gateway C mock issuing numbered access tokens, "token-1" first.
+type mockServer struct {
+	*httptest.Server
+
+	// expiresIn is the lifetime of the issued tokens, in seconds.
+	expiresIn int
+	// rejected are the tokens payments are rejected for with 401.
+	rejected map[string]bool
+	// hang makes the token endpoint hang until the server is closed.
+	hang chan struct{}
+
+	mu     sync.Mutex
+	issued int
+	used   []string
+}
+
+func newMockServer(t *testing.T, expiresIn int, rejected ...string) *mockServer {
+	s := &mockServer{expiresIn: expiresIn, rejected: map[string]bool{}}
+	for _, token := range rejected {
+		s.rejected[token] = true
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth/token":
+			if s.hang != nil {
+				select {
+				case <-s.hang:
+				case <-r.Context().Done():
+				}
+				return
+			}
+
+			s.mu.Lock()
+			s.issued++
+			token := fmt.Sprintf("token-%d", s.issued)
+			s.mu.Unlock()
+
+			json.NewEncoder(w).Encode(Token{AccessToken: token, TokenType: "Bearer", ExpiresIn: s.expiresIn})
+		case "/v1/payments":
+			token := r.Header.Get("Authorization")[len("Bearer "):]
+
+			s.mu.Lock()
+			s.used = append(s.used, token)
+			s.mu.Unlock()
+
+			if s.rejected[token] {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "pay-1", "status": "processing"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(func() {
+		if s.hang != nil {
+			close(s.hang)
+		}
+		s.Close()
+	})
+	return s
+}
+
+// tokensUsed returns the tokens the payments were sent with, in order.
+func (s *mockServer) tokensUsed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.used...)
+}
+
+func newTestGateway(baseURL string) *GatewayC {
+	return New(config.PaymentGatewayC{
+		BaseURL:                  baseURL,
+		TokenTimeout:             100 * time.Millisecond,
+		ClientID:                 "client",
+		ClientSecret:             "secret",
+		RetryAttempt:             2,
+		RetryDelay:               time.Millisecond,
+		CBMaxRequests:            1,
+		CBInterval:               time.Minute,
+		CBTimeout:                time.Minute,
+		CBMaxConsecutiveFailures: 10,
+		CBMaxTotalFailures:       10,
+		CallbackSecret:           "secret",
+		CallbackTolerance:        time.Minute,
+	}).(*GatewayC)
+}
+
+func tokens(t *testing.T) []unitest.Table {
+	req := &payment.Request{
+		ID:                   "tran-1",
+		Amount:               money.MustParse("10.99"),
+		Currency:             money.USD,
+		PaymentMethod:        models.PaymentMethodCreditCard,
+		PaymentMethodDetails: json.RawMessage(`{"number": "4111111111111111", "expiry": "12/30", "cvv": "123"}`),
+	}
+
+	// deposit sends n deposits to a fresh gateway of the server, and returns the tokens
+	// the server got them with.
+	deposit := func(server *mockServer, n int) any {
+		gateway := newTestGateway(server.URL)
+		for i := 0; i < n; i++ {
+			if _, err := gateway.Deposit(context.Background(), req); err != nil {
+				return err
+			}
+		}
+		return server.tokensUsed()
+	}
+
+	cmp := func(got any, exp any) string {
+		if expErr, ok := exp.(error); ok {
+			if err, ok := got.(error); !ok || !errors.Is(err, expErr) {
+				return fmt.Sprintf("expected error %v, got %v", exp, got)
+			}
+			return ""
+		}
+		if fmt.Sprint(got) != fmt.Sprint(exp) {
+			return fmt.Sprintf("expected %v, got %v", exp, got)
+		}
+		return ""
+	}
+
+	return []unitest.Table{
+		{
+			Name:    "Cached Until Expiry",
+			ExpResp: []string{"token-1", "token-1"},
+			ExcFunc: func(ctx context.Context) any {
+				return deposit(newMockServer(t, 3600), 2)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Refreshed Before Expiry",
+			ExpResp: []string{"token-1", "token-2"},
+			ExcFunc: func(ctx context.Context) any {
+				server := newMockServer(t, 1)
+				gateway := newTestGateway(server.URL)
+				if _, err := gateway.Deposit(ctx, req); err != nil {
+					return err
+				}
+
+				// the token lives 1s, and is renewed half way.
+				time.Sleep(600 * time.Millisecond)
+				if _, err := gateway.Deposit(ctx, req); err != nil {
+					return err
+				}
+				return server.tokensUsed()
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Short Lived Token Reused",
+			ExpResp: []string{"token-1", "token-1"},
+			ExcFunc: func(ctx context.Context) any {
+				return deposit(newMockServer(t, int(tokenExpiryMargin/time.Second)), 2)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Token Without Expiry Reused",
+			ExpResp: []string{"token-1", "token-1"},
+			ExcFunc: func(ctx context.Context) any {
+				return deposit(newMockServer(t, 0), 2)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Retried With New Token On 401",
+			ExpResp: []string{"token-1", "token-2", "token-2"},
+			ExcFunc: func(ctx context.Context) any {
+				return deposit(newMockServer(t, 3600, "token-1"), 2)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Rejected Tokens Not Sent",
+			ExpResp: payment.ErrNotSent,
+			ExcFunc: func(ctx context.Context) any {
+				return deposit(newMockServer(t, 3600, "token-1", "token-2"), 1)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Hung Token Endpoint Times Out",
+			ExpResp: payment.ErrNotSent,
+			ExcFunc: func(ctx context.Context) any {
+				server := newMockServer(t, 3600)
+				server.hang = make(chan struct{})
+
+				done := make(chan any, 1)
+				go func() {
+					done <- deposit(server, 1)
+				}()
+
+				select {
+				case res := <-done:
+					return res
+				case <-time.After(5 * time.Second):
+					return errors.New("deposit still waiting for a token")
+				}
+			},
+			CmpFunc: cmp,
+		},
+	}
+}
+
+func minorUnits() []unitest.Table {
+	amount := func(value string, currency money.Currency) any {
+		res, err := newRequest(&payment.Request{
+			Amount:               money.MustParse(value),
+			Currency:             currency,
+			PaymentMethod:        models.PaymentMethodBankTransfer,
+			PaymentMethodDetails: json.RawMessage(`{"account_number": "123456789", "bank_code": "021000021", "bank_code_type": "aba"}`),
+		})
+		if err != nil {
+			return err
+		}
+		return res.Amount
+	}
+
+	cmp := func(got any, exp any) string {
+		if exp == nil {
+			if _, ok := got.(error); !ok {
+				return fmt.Sprintf("expected an error, got %v", got)
+			}
+			return ""
+		}
+		if got != exp {
+			return fmt.Sprintf("expected %v, got %v", exp, got)
+		}
+		return ""
+	}
+
+	return []unitest.Table{
+		{
+			Name:    "Cents",
+			ExpResp: int64(1099),
+			ExcFunc: func(ctx context.Context) any {
+				return amount("10.99", money.USD)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Zero Decimal Currency",
+			ExpResp: int64(1500),
+			ExcFunc: func(ctx context.Context) any {
+				return amount("1500", money.JPY)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Too Many Decimals",
+			ExpResp: nil,
+			ExcFunc: func(ctx context.Context) any {
+				return amount("10.5", money.JPY)
+			},
+			CmpFunc: cmp,
+		},
+	}
+}
+
+func signatures() []unitest.Table {
+	parse := func(header string) any {
+		timestamp, signature := parseSignature(header)
+		return [2]string{timestamp, signature}
+	}
+
+	cmp := func(got any, exp any) string {
+		if got != exp {
+			return fmt.Sprintf("expected %v, got %v", exp, got)
+		}
+		return ""
+	}
+
+	return []unitest.Table{
+		{
+			Name:    "Timestamp And Signature",
+			ExpResp: [2]string{"1700000000", "abc123"},
+			ExcFunc: func(ctx context.Context) any {
+				return parse("t=1700000000,v1=abc123")
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Any Order With Spaces",
+			ExpResp: [2]string{"1700000000", "abc123"},
+			ExcFunc: func(ctx context.Context) any {
+				return parse("v1=abc123, t=1700000000")
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Unknown Schemes Ignored",
+			ExpResp: [2]string{"1700000000", "abc123"},
+			ExcFunc: func(ctx context.Context) any {
+				return parse("t=1700000000,v0=old,v1=abc123")
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Missing Header",
+			ExpResp: [2]string{"", ""},
+			ExcFunc: func(ctx context.Context) any {
+				return parse("")
+			},
+			CmpFunc: cmp,
+		},
+	}
+}
@@ -0,0 +1,54 @@
+package gatewayc
+
+// Request is a payment or payout request. Amounts are integers in the minor units of
+// the currency, e.g. cents for USD and yen for JPY.
+type Request struct {
+	Reference  string `json:"reference"`
+	Amount     int64  `json:"amount"`
+	Currency   string `json:"currency"`
+	WebhookURL string `json:"webhook_url"`
+	// Card is set for payments, Beneficiary for payouts.
+	Card        *Card        `json:"card,omitempty"`
+	Beneficiary *Beneficiary `json:"beneficiary,omitempty"`
+}
+
+type Card struct {
+	Number      string `json:"number"`
+	ExpiryMonth int    `json:"expiry_month"`
+	ExpiryYear  int    `json:"expiry_year"`
+	CVC         string `json:"cvc"`
+}
+
+type Beneficiary struct {
+	AccountNumber string `json:"account_number"`
+	BankCode      string `json:"bank_code"`
+	BankCodeType  string `json:"bank_code_type"`
+}
+
+type RefundRequest struct {
+	Reference  string `json:"reference"`
+	PaymentID  string `json:"payment_id"`
+	Amount     int64  `json:"amount"`
+	Currency   string `json:"currency"`
+	WebhookURL string `json:"webhook_url"`
+}
+
+// Transaction is a payment, payout or refund as reported by Gateway C.
+type Transaction struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// Event is a webhook event sent by Gateway C when a transaction is updated.
+type Event struct {
+	ID   string      `json:"id"`
+	Type string      `json:"type"`
+	Data Transaction `json:"data"`
+}
+
+// Token is an OAuth2 access token response.
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
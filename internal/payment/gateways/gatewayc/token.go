@@ -0,0 +1,122 @@
+package gatewayc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// tokenExpiryMargin renews a token before it expires, so a request is never sent
+	// with a token expiring in flight.
+	tokenExpiryMargin = 30 * time.Second
+	// defaultTokenLifetime is assumed for a token response without expires_in. A token
+	// that expires sooner is rejected with 401, and renewed.
+	defaultTokenLifetime = 5 * time.Minute
+)
+
+// tokenSource acquires OAuth2 access tokens with the client credentials grant, and
+// caches them until shortly before they expire.
+type tokenSource struct {
+	client       *http.Client
+	url          string
+	clientID     string
+	clientSecret string
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// newTokenSource creates a token source. Token requests time out after timeout, as
+// every request to Gateway C waits for the token being acquired.
+func newTokenSource(tokenURL, clientID, clientSecret string, timeout time.Duration) *tokenSource {
+	return &tokenSource{
+		client:       &http.Client{Timeout: timeout},
+		url:          tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+	}
+}
+
+// Token returns the cached access token, or acquires a new one when there is none or
+// it is about to expire.
+func (s *tokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Before(s.expiry) {
+		return s.token, nil
+	}
+
+	token, err := s.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	s.token = token.AccessToken
+	s.expiry = time.Now().Add(tokenLifetime(token.ExpiresIn))
+	return s.token, nil
+}
+
+// tokenLifetime returns how long a token valid for expiresIn seconds is cached. The
+// expiry margin is at most half the lifetime, so a short-lived token is still reused
+// rather than acquired again for every request.
+func tokenLifetime(expiresIn int) time.Duration {
+	lifetime := time.Duration(expiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = defaultTokenLifetime
+	}
+	return lifetime - min(tokenExpiryMargin, lifetime/2)
+}
+
+// Invalidate drops the cached token if it is still the given one, after Gateway C
+// rejected it, so the next request acquires a new token.
+func (s *tokenSource) Invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+func (s *tokenSource) fetch(ctx context.Context) (*Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(s.clientSecret))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to acquire token: %d - %s", resp.StatusCode, body)
+	}
+
+	var token Token
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal token response: %w", err)
+	}
+	if token.AccessToken == "" || !strings.EqualFold(token.TokenType, "bearer") {
+		return nil, fmt.Errorf("invalid token response: %s token", token.TokenType)
+	}
+	return &token, nil
+}
@@ -0,0 +1,259 @@
+package generic
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/internal/payment"
+)
+
+// Format is the encoding of the requests and responses of a gateway.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatXML  Format = "xml"
+)
+
+// File is a gateways file, describing the gateways integrated without their own package.
+type File struct {
+	Gateways []Config `json:"gateways"`
+}
+
+// Config describes a gateway: how to build its requests, and where to find the reference
+// ID and the status in its responses and callbacks.
+type Config struct {
+	// Name is the payment gateway of the transactions sent to the gateway.
+	Name         models.PaymentGateway `json:"name"`
+	BaseURL      string                `json:"base_url"`
+	Format       Format                `json:"format"`
+	Headers      map[string]string     `json:"headers"`
+	Retry        RetryConfig           `json:"retry"`
+	Breaker      BreakerConfig         `json:"circuit_breaker"`
+	Capabilities payment.Capabilities  `json:"capabilities"`
+
+	// Endpoints by operation: deposit, withdrawal, refund and status.
+	Endpoints map[string]Endpoint `json:"endpoints"`
+	// Response locates the reference ID and the status in the responses.
+	Response Fields `json:"response"`
+	// Statuses map the statuses of the gateway to payment statuses, unmapped statuses
+	// are unknown.
+	Statuses map[string]payment.PaymentStatus `json:"statuses"`
+	Callback CallbackConfig                   `json:"callback"`
+}
+
+// Endpoint is a request sent to the gateway. Path and Body are text/template templates
+// executed with a TemplateData, see the README for the fields and functions available.
+type Endpoint struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Body   string `json:"body"`
+	// Accepted are the status codes of a successful response, 200 by default. A request
+	// repeating an idempotency key often gets the original transaction back with 409.
+	Accepted []int `json:"accepted"`
+}
+
+// Fields locates values in a response or callback body, with dot separated paths of
+// JSON object keys, array indexes or XML element names, e.g. "data.status" or
+// "Envelope.Body.status".
+type Fields struct {
+	ReferenceID string `json:"reference_id"`
+	Status      string `json:"status"`
+}
+
+// CallbackConfig describes the callbacks of the gateway, signed as verified by payment.Signature.
+type CallbackConfig struct {
+	SignatureHeader string   `json:"signature_header"`
+	TimestampHeader string   `json:"timestamp_header"`
+	Secret          string   `json:"secret"`
+	Tolerance       Duration `json:"tolerance"`
+	// Fields locates the reference ID and the status in the callbacks, the response
+	// fields by default.
+	Fields Fields `json:"fields"`
+}
+
+type RetryConfig struct {
+	Attempts int      `json:"attempts"`
+	Delay    Duration `json:"delay"`
+}
+
+type BreakerConfig struct {
+	MaxRequests            uint32   `json:"max_requests"` // Max requests allowed in half-open state
+	Interval               Duration `json:"interval"`     // Interval to reset the failure counter
+	Timeout                Duration `json:"timeout"`      // Time to stay open before testing recovery
+	MaxConsecutiveFailures uint32   `json:"max_consecutive_failures"`
+	MaxTotalFailures       uint32   `json:"max_total_failures"`
+}
+
+// Duration is a time.Duration decoded from a string such as "30s".
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %w", err)
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Operations of a gateway, the keys of Config.Endpoints.
+const (
+	opDeposit    = "deposit"
+	opWithdrawal = "withdrawal"
+	opRefund     = "refund"
+	opStatus     = "status"
+)
+
+// envVar matches the ${NAME} references to environment variables in a gateways file.
+var envVar = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Load reads the gateways file at path. ${NAME} references are replaced with the
+// environment variable NAME, so secrets stay out of the file.
+func Load(path string) ([]Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gateways file: %w", err)
+	}
+
+	data = envVar.ReplaceAllFunc(data, func(ref []byte) []byte {
+		value := os.Getenv(string(envVar.FindSubmatch(ref)[1]))
+		// the value is escaped as a JSON string, without its quotes
+		b, _ := json.Marshal(value)
+		return b[1 : len(b)-1]
+	})
+
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse gateways file: %w", err)
+	}
+
+	for i := range file.Gateways {
+		if err := file.Gateways[i].validate(); err != nil {
+			return nil, fmt.Errorf("gateway %q: %w", file.Gateways[i].Name, err)
+		}
+	}
+
+	return file.Gateways, nil
+}
+
+// validate checks the config and sets its defaults.
+func (c *Config) validate() error {
+	if c.Name == "" || c.BaseURL == "" {
+		return fmt.Errorf("name and base_url are required")
+	}
+
+	switch c.Format {
+	case FormatJSON, FormatXML:
+	case "":
+		c.Format = FormatJSON
+	default:
+		return fmt.Errorf("unsupported format %q", c.Format)
+	}
+
+	// the defaults of the other gateways, see config.PaymentGatewayA
+	if c.Retry.Attempts == 0 {
+		c.Retry.Attempts = 3
+	}
+	if c.Retry.Delay == 0 {
+		c.Retry.Delay = Duration(time.Second)
+	}
+	if c.Breaker.MaxRequests == 0 {
+		c.Breaker.MaxRequests = 5
+	}
+	if c.Breaker.Interval == 0 {
+		c.Breaker.Interval = Duration(60 * time.Second)
+	}
+	if c.Breaker.Timeout == 0 {
+		c.Breaker.Timeout = Duration(30 * time.Second)
+	}
+	if c.Breaker.MaxConsecutiveFailures == 0 {
+		c.Breaker.MaxConsecutiveFailures = 3
+	}
+	if c.Breaker.MaxTotalFailures == 0 {
+		c.Breaker.MaxTotalFailures = 5
+	}
+
+	for op, endpoint := range c.Endpoints {
+		switch op {
+		case opDeposit, opWithdrawal, opRefund, opStatus:
+		default:
+			return fmt.Errorf("unsupported endpoint %q", op)
+		}
+
+		if endpoint.Path == "" {
+			return fmt.Errorf("endpoint %s: path is required", op)
+		}
+		if endpoint.Method == "" {
+			endpoint.Method = http.MethodPost
+		}
+		if len(endpoint.Accepted) == 0 {
+			endpoint.Accepted = []int{http.StatusOK}
+		}
+		c.Endpoints[op] = endpoint
+	}
+
+	// the reconciler polls the status of the transactions whose callback was lost
+	if _, ok := c.Endpoints[opStatus]; !ok {
+		return fmt.Errorf("endpoint %s is required", opStatus)
+	}
+	for _, typ := range c.Capabilities.Types {
+		if _, ok := c.Endpoints[operation(typ)]; !ok {
+			return fmt.Errorf("endpoint %s is required by the %s capability", operation(typ), typ)
+		}
+	}
+
+	if c.Response.ReferenceID == "" || c.Response.Status == "" {
+		return fmt.Errorf("response reference_id and status are required")
+	}
+
+	for status, mapped := range c.Statuses {
+		switch mapped {
+		case payment.PaymentStatusPending, payment.PaymentStatusSuccess, payment.PaymentStatusFailed, payment.PaymentStatusUnknown:
+		default:
+			return fmt.Errorf("status %q maps to unsupported payment status %q", status, mapped)
+		}
+	}
+
+	if c.Callback.Secret == "" || c.Callback.SignatureHeader == "" || c.Callback.TimestampHeader == "" {
+		return fmt.Errorf("callback secret, signature_header and timestamp_header are required")
+	}
+	if c.Callback.Tolerance == 0 {
+		c.Callback.Tolerance = Duration(5 * time.Minute)
+	}
+	if c.Callback.Fields == (Fields{}) {
+		c.Callback.Fields = c.Response
+	}
+
+	return nil
+}
+
+// operation returns the endpoint sending transactions of the type.
+func operation(typ models.TransactionType) string {
+	switch typ {
+	case models.TransactionTypeDeposit:
+		return opDeposit
+	case models.TransactionTypeWithdrawal:
+		return opWithdrawal
+	case models.TransactionTypeRefund:
+		return opRefund
+	default:
+		return string(typ)
+	}
+}
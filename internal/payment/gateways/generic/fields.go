@@ -0,0 +1,134 @@
+package generic
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// funcs are the functions available in the templates, escaping values for the
+// context they are written in. text/template does not escape values on its own.
+var funcs = template.FuncMap{
+	// json writes a value as JSON, e.g. {"amount": {{json .Amount}}}, with strings quoted.
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	// xml writes a value as XML character data.
+	"xml": func(v any) (string, error) {
+		var b bytes.Buffer
+		err := xml.EscapeText(&b, []byte(fmt.Sprint(v)))
+		return b.String(), err
+	},
+	// query writes a value as a URL query parameter or path segment.
+	"query": func(v any) string {
+		return url.QueryEscape(fmt.Sprint(v))
+	},
+}
+
+// wellFormed checks the body is a single well-formed document in the format.
+func wellFormed(format Format, body []byte) error {
+	if format == FormatXML {
+		dec := xml.NewDecoder(bytes.NewReader(body))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !json.Valid(body) {
+		return errors.New("malformed JSON")
+	}
+	return nil
+}
+
+// lookup returns the value at the dot separated path in the body. JSON paths are
+// object keys and array indexes, XML paths are element names from the root element.
+func lookup(format Format, body []byte, path string) (string, error) {
+	keys := strings.Split(path, ".")
+	if format == FormatXML {
+		return lookupXML(body, keys)
+	}
+	return lookupJSON(body, keys)
+}
+
+func lookupJSON(body []byte, keys []string) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+
+	for i, key := range keys {
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[key]
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", fmt.Errorf("field %s not found", strings.Join(keys[:i+1], "."))
+			}
+			v = node[index]
+		default:
+			return "", fmt.Errorf("field %s not found", strings.Join(keys[:i+1], "."))
+		}
+	}
+
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case nil:
+		return "", fmt.Errorf("field %s not found", strings.Join(keys, "."))
+	default:
+		return "", fmt.Errorf("field %s is not a scalar", strings.Join(keys, "."))
+	}
+}
+
+func lookupXML(body []byte, keys []string) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+
+	var (
+		stack []string
+		value strings.Builder
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return "", fmt.Errorf("field %s not found", strings.Join(keys, "."))
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+		case xml.CharData:
+			if slices.Equal(stack, keys) {
+				value.Write(t)
+			}
+		case xml.EndElement:
+			if slices.Equal(stack, keys) {
+				return strings.TrimSpace(value.String()), nil
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package generic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"text/template"
+	"time"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/internal/payment"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/3bd-dev/wallet-service/pkg/rest"
+	"github.com/sony/gobreaker/v2"
+)
+
+// Gateway is a payment gateway described by a Config instead of its own package.
+type Gateway struct {
+	cfg       Config
+	client    *rest.Client
+	retier    rest.Retrier
+	cb        *gobreaker.CircuitBreaker[[]byte]
+	sig       *payment.Signature
+	endpoints map[string]endpoint
+}
+
+// endpoint is an Endpoint with its parsed templates.
+type endpoint struct {
+	Endpoint
+	path *template.Template
+	body *template.Template
+}
+
+// TemplateData is the data the path and body templates of the endpoints are executed with.
+type TemplateData struct {
+	// ID is our transaction ID, also sent as the Idempotency-Key header.
+	ID string
+	// Amount has the decimal places of the currency, e.g. 500 for JPY and 10.50 for USD.
+	Amount      json.Number
+	Currency    money.Currency
+	CallbackURL string
+	// ReferenceID is the gateway reference of the refunded payment for refunds, and of
+	// the queried transaction for the status endpoint.
+	ReferenceID string
+	// Card is set for credit card payments, BankAccount for bank transfers.
+	Card        *Card
+	BankAccount *payment.PaymentMethodBankDetails
+
+	amount money.Money
+}
+
+// Card is a credit card with its expiry date parsed.
+type Card struct {
+	payment.PaymentMethodCreditCardDetails
+	ExpiryMonth int
+	ExpiryYear  int
+}
+
+// AmountMinor returns the amount in the minor units of the currency, e.g. 1099 for 10.99 USD.
+func (d TemplateData) AmountMinor() (int64, error) {
+	return d.Currency.ToMinorUnits(d.amount)
+}
+
+// New creates a gateway from its config, failing when a template does not parse.
+func New(cfg Config) (payment.PaymentGateway, error) {
+	cbSettings := gobreaker.Settings{
+		Name:        string(cfg.Name),
+		MaxRequests: cfg.Breaker.MaxRequests,
+		Interval:    time.Duration(cfg.Breaker.Interval),
+		Timeout:     time.Duration(cfg.Breaker.Timeout),
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures > cfg.Breaker.MaxConsecutiveFailures || counts.TotalFailures > cfg.Breaker.MaxTotalFailures
+		},
+	}
+
+	endpoints := make(map[string]endpoint, len(cfg.Endpoints))
+	for op, e := range cfg.Endpoints {
+		path, err := template.New(op + " path").Funcs(funcs).Parse(e.Path)
+		if err != nil {
+			return nil, fmt.Errorf("gateway %s: %w", cfg.Name, err)
+		}
+
+		body, err := template.New(op + " body").Funcs(funcs).Parse(e.Body)
+		if err != nil {
+			return nil, fmt.Errorf("gateway %s: %w", cfg.Name, err)
+		}
+
+		endpoints[op] = endpoint{Endpoint: e, path: path, body: body}
+	}
+
+	return &Gateway{
+		cfg:       cfg,
+		client:    rest.NewClient(cfg.BaseURL),
+		retier:    rest.NewRetrier(cfg.Retry.Attempts, time.Duration(cfg.Retry.Delay)),
+		cb:        gobreaker.NewCircuitBreaker[[]byte](cbSettings),
+		sig:       payment.NewSignature(cfg.Callback.Secret, time.Duration(cfg.Callback.Tolerance)),
+		endpoints: endpoints,
+	}, nil
+}
+
+// Deposit sends a deposit request to the gateway
+func (g *Gateway) Deposit(ctx context.Context, req *payment.Request) (*payment.Response, error) {
+	return g.submit(ctx, opDeposit, req)
+}
+
+// Withdraw sends a withdrawal request to the gateway
+func (g *Gateway) Withdraw(ctx context.Context, req *payment.Request) (*payment.Response, error) {
+	return g.submit(ctx, opWithdrawal, req)
+}
+
+// Refund sends a refund request of a previous payment to the gateway
+func (g *Gateway) Refund(ctx context.Context, req *payment.Request) (*payment.Response, error) {
+	return g.submit(ctx, opRefund, req)
+}
+
+// ParseCallback verifies the signature of a callback from the gateway and decodes the transaction it reports
+func (g *Gateway) ParseCallback(ctx context.Context, cb *payment.Callback) (*payment.Response, error) {
+	timestamp := cb.Header.Get(g.cfg.Callback.TimestampHeader)
+	signature := cb.Header.Get(g.cfg.Callback.SignatureHeader)
+	if err := g.sig.Verify(timestamp, signature, cb.Body, cb.ReceivedAt); err != nil {
+		return nil, err
+	}
+
+	res, err := g.toPaymentResponse(cb.Body, g.cfg.Callback.Fields)
+	if err != nil {
+		return nil, errors.New("failed to process transaction")
+	}
+
+	return res, nil
+}
+
+// GetStatus queries the gateway for the status of a transaction
+func (g *Gateway) GetStatus(ctx context.Context, refID string) (*payment.Response, error) {
+	res, err := g.send(ctx, opStatus, TemplateData{ReferenceID: refID})
+	if err != nil {
+		return nil, err
+	}
+
+	if refID != res.ID {
+		return nil, errors.New("invalid reference ID")
+	}
+
+	return res, nil
+}
+
+// Capabilities returns the transactions the gateway supports
+func (g *Gateway) Capabilities() payment.Capabilities {
+	return g.cfg.Capabilities
+}
+
+// BreakerState returns the current state of the circuit breaker of the gateway
+func (g *Gateway) BreakerState() payment.BreakerState {
+	return payment.BreakerState(g.cb.State().String())
+}
+
+// submit sends a request creating a transaction to the gateway
+func (g *Gateway) submit(ctx context.Context, op string, req *payment.Request) (*payment.Response, error) {
+	data, err := newTemplateData(req)
+	if err != nil {
+		return nil, errs.New(errs.InvalidArgument, err)
+	}
+
+	return g.send(ctx, op, data)
+}
+
+// send sends the request of the endpoint, built from the data, and decodes the transaction of its response.
+func (g *Gateway) send(ctx context.Context, op string, data TemplateData) (*payment.Response, error) {
+	e, ok := g.endpoints[op]
+	if !ok {
+		return nil, errs.Newf(errs.InvalidArgument, "payment gateway %s does not support %s", g.cfg.Name, op)
+	}
+
+	path, body, err := g.render(e, data)
+	if err != nil {
+		return nil, err
+	}
+
+	options := g.requestOptions(data.ID)
+	respBody, err := g.execute(func() ([]byte, error) {
+		resp, err := g.retry(ctx, e.Method, path, body, options)
+		if err != nil {
+			return nil, err
+		}
+
+		if !slices.Contains(e.Accepted, resp.StatusCode) {
+			return nil, fmt.Errorf("failed to %s: %d - %s", op, resp.StatusCode, resp.Body)
+		}
+
+		return resp.Body, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return g.toPaymentResponse(respBody, g.cfg.Response)
+}
+
+// render executes the path and body templates of the endpoint, and checks the body is
+// well-formed so a broken template is caught before it reaches the gateway. Rendering
+// fails the same way on every attempt, e.g. for a template referring to card details
+// of a bank transfer, so its errors are not retried.
+func (g *Gateway) render(e endpoint, data TemplateData) (string, []byte, error) {
+	var path, body bytes.Buffer
+	if err := e.path.Execute(&path, data); err != nil {
+		return "", nil, errs.New(errs.InvalidArgument, fmt.Errorf("failed to render path: %w", err))
+	}
+	if err := e.body.Execute(&body, data); err != nil {
+		return "", nil, errs.New(errs.InvalidArgument, fmt.Errorf("failed to render body: %w", err))
+	}
+
+	if body.Len() > 0 {
+		if err := wellFormed(g.cfg.Format, body.Bytes()); err != nil {
+			return "", nil, errs.New(errs.InvalidArgument, fmt.Errorf("rendered body is not valid %s: %w", g.cfg.Format, err))
+		}
+	}
+
+	return path.String(), body.Bytes(), nil
+}
+
+// requestOptions returns the headers of every request to the gateway. Requests for a
+// transaction carry its ID as their idempotency key.
+func (g *Gateway) requestOptions(id string) *rest.RequestOptions {
+	options := rest.NewRequestOptions()
+	for key, value := range g.cfg.Headers {
+		options.Headers.Set(key, value)
+	}
+
+	contentType := rest.JSONContentType
+	if g.cfg.Format == FormatXML {
+		contentType = rest.XMLContentType
+	}
+	options.Headers.Set("Content-Type", contentType)
+
+	if id != "" {
+		options.Headers.Set("Idempotency-Key", id)
+	}
+	return &options
+}
+
+// retry sends the request to the gateway and retries if it fails
+func (g *Gateway) retry(ctx context.Context, method, path string, body []byte, options *rest.RequestOptions) (*rest.Response, error) {
+	var resp *rest.Response
+	// the request might have reached the gateway unless every attempt failed to connect.
+	sent := false
+	err := g.retier.Do(ctx, func(ctx context.Context, _ int) error {
+		req, err := http.NewRequest(method, g.client.BaseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+
+		resp, err = g.client.SendRequest(ctx, options.Headers.Get("Content-Type"), req, options)
+		if !payment.IsDialError(err) {
+			sent = true
+		}
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return errors.New("invalid response")
+		}
+
+		return nil
+	})
+	if err != nil && !sent {
+		return resp, fmt.Errorf("%w: %w: %w", payment.ErrUnavailable, payment.ErrNotSent, err)
+	}
+	if err != nil {
+		return resp, fmt.Errorf("%w: %w", payment.ErrUnavailable, err)
+	}
+
+	return resp, nil
+}
+
+// execute runs fn through the circuit breaker. Requests rejected by the breaker
+// report the gateway as unavailable.
+func (g *Gateway) execute(fn func() ([]byte, error)) ([]byte, error) {
+	body, err := g.cb.Execute(fn)
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return nil, fmt.Errorf("%w: %w: %w", payment.ErrUnavailable, payment.ErrNotSent, err)
+	}
+	return body, err
+}
+
+// toPaymentResponse decodes the transaction located by the fields in a response or callback body.
+func (g *Gateway) toPaymentResponse(body []byte, fields Fields) (*payment.Response, error) {
+	id, err := lookup(g.cfg.Format, body, fields.ReferenceID)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := lookup(g.cfg.Format, body, fields.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	if id == "" {
+		return nil, errs.New(errs.Internal, errors.New("missing transaction ID"))
+	}
+
+	return &payment.Response{ID: id, Status: g.toPaymentStatus(status)}, nil
+}
+
+func (g *Gateway) toPaymentStatus(status string) payment.PaymentStatus {
+	if mapped, ok := g.cfg.Statuses[status]; ok {
+		return mapped
+	}
+	return payment.PaymentStatusUnknown
+}
+
+// newTemplateData builds the template data of a request, including the details of its payment method.
+func newTemplateData(req *payment.Request) (TemplateData, error) {
+	data := TemplateData{
+		ID:          req.ID,
+		Amount:      json.Number(req.Currency.Format(req.Amount)),
+		Currency:    req.Currency,
+		CallbackURL: req.CallbackURL,
+		ReferenceID: req.ReferenceID,
+		amount:      req.Amount,
+	}
+
+	// refunds go through the payment method of the refunded payment, without its details
+	if len(req.PaymentMethodDetails) == 0 {
+		return data, nil
+	}
+
+	switch req.PaymentMethod {
+	case models.PaymentMethodCreditCard:
+		card, err := req.CreditCard()
+		if err != nil {
+			return data, err
+		}
+
+		month, year, err := card.ExpiryDate()
+		if err != nil {
+			return data, err
+		}
+
+		data.Card = &Card{PaymentMethodCreditCardDetails: *card, ExpiryMonth: month, ExpiryYear: year}
+	case models.PaymentMethodBankTransfer:
+		account, err := req.BankAccount()
+		if err != nil {
+			return data, err
+		}
+
+		data.BankAccount = account
+	default:
+		return data, fmt.Errorf("unsupported payment method: %s", req.PaymentMethod)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,401 @@
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/internal/payment"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/3bd-dev/wallet-service/pkg/unitest"
+)
+
+func Test_Generic(t *testing.T) {
+	t.Parallel()
+
+	unitest.Run(t, lookupFields(), "lookup")
+	unitest.Run(t, deposit(t), "deposit")
+	unitest.Run(t, parseCallback(), "parseCallback")
+	unitest.Run(t, load(t), "load")
+}
+
+// testConfig returns a valid config of a JSON gateway at baseURL.
+func testConfig(baseURL string) Config {
+	cfg := Config{
+		Name:    "generic",
+		BaseURL: baseURL,
+		Retry:   RetryConfig{Attempts: 1, Delay: Duration(time.Millisecond)},
+		Capabilities: payment.Capabilities{
+			Types: []models.TransactionType{models.TransactionTypeDeposit},
+			Methods: map[models.TransactionType][]models.PaymentMethod{
+				models.TransactionTypeDeposit: {models.PaymentMethodCreditCard},
+			},
+		},
+		Endpoints: map[string]Endpoint{
+			opDeposit: {
+				Path: "/payments",
+				Body: `{"reference": {{json .ID}}, "amount": {{.AmountMinor}}, "currency": {{json .Currency}}, "card": {"number": {{json .Card.Number}}, "month": {{.Card.ExpiryMonth}}}}`,
+			},
+			opStatus: {
+				Method: http.MethodGet,
+				Path:   "/payments/{{query .ReferenceID}}",
+			},
+		},
+		Response: Fields{ReferenceID: "data.id", Status: "data.state"},
+		Statuses: map[string]payment.PaymentStatus{
+			"processing": payment.PaymentStatusPending,
+			"done":       payment.PaymentStatusSuccess,
+		},
+		Callback: CallbackConfig{
+			SignatureHeader: "X-Signature",
+			TimestampHeader: "X-Timestamp",
+			Secret:          "secret",
+		},
+	}
+	if err := cfg.validate(); err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+func lookupFields() []unitest.Table {
+	jsonBody := []byte(`{"data": {"id": "ref-1", "amount": 1099, "items": [{"state": "done"}], "paid": true}}`)
+	xmlBody := []byte(`<Envelope><Body><id>ref-2</id><status> success </status></Body></Envelope>`)
+
+	lookupWith := func(format Format, body []byte, path string) any {
+		v, err := lookup(format, body, path)
+		if err != nil {
+			return err.Error()
+		}
+		return v
+	}
+
+	cmp := func(got any, exp any) string {
+		if got != exp {
+			return fmt.Sprintf("got %v, expected %v", got, exp)
+		}
+		return ""
+	}
+
+	return []unitest.Table{
+		{
+			Name:    "JSON String",
+			ExpResp: "ref-1",
+			ExcFunc: func(ctx context.Context) any { return lookupWith(FormatJSON, jsonBody, "data.id") },
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "JSON Number",
+			ExpResp: "1099",
+			ExcFunc: func(ctx context.Context) any { return lookupWith(FormatJSON, jsonBody, "data.amount") },
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "JSON Array Index",
+			ExpResp: "done",
+			ExcFunc: func(ctx context.Context) any { return lookupWith(FormatJSON, jsonBody, "data.items.0.state") },
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "JSON Bool",
+			ExpResp: "true",
+			ExcFunc: func(ctx context.Context) any { return lookupWith(FormatJSON, jsonBody, "data.paid") },
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "JSON Missing Field",
+			ExpResp: "field data.status not found",
+			ExcFunc: func(ctx context.Context) any { return lookupWith(FormatJSON, jsonBody, "data.status") },
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "JSON Object",
+			ExpResp: "field data is not a scalar",
+			ExcFunc: func(ctx context.Context) any { return lookupWith(FormatJSON, jsonBody, "data") },
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "XML Element",
+			ExpResp: "success",
+			ExcFunc: func(ctx context.Context) any { return lookupWith(FormatXML, xmlBody, "Envelope.Body.status") },
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "XML Missing Element",
+			ExpResp: "field Body.status not found",
+			ExcFunc: func(ctx context.Context) any { return lookupWith(FormatXML, xmlBody, "Body.status") },
+			CmpFunc: cmp,
+		},
+	}
+}
+
+func deposit(t *testing.T) []unitest.Table {
+	type request struct {
+		IdempotencyKey string
+		Body           map[string]any
+	}
+
+	var received request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/payments":
+			received = request{IdempotencyKey: r.Header.Get("Idempotency-Key")}
+			json.NewDecoder(r.Body).Decode(&received.Body)
+			w.Write([]byte(`{"data": {"id": "ref-1", "state": "processing"}}`))
+		case "/payments/ref-1":
+			w.Write([]byte(`{"data": {"id": "ref-1", "state": "done"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	gateway, err := New(testConfig(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &payment.Request{
+		ID:                   "tran-1",
+		Amount:               money.MustParse("10.99"),
+		Currency:             money.USD,
+		PaymentMethod:        models.PaymentMethodCreditCard,
+		PaymentMethodDetails: json.RawMessage(`{"number": "4111111111111111", "expiry": "12/30", "cvv": "123"}`),
+	}
+
+	cmpResponse := func(got any, exp any) string {
+		gotResp, ok := got.(*payment.Response)
+		if !ok {
+			return fmt.Sprintf("got %v", got)
+		}
+		expResp := exp.(*payment.Response)
+		if gotResp.ID != expResp.ID || gotResp.Status != expResp.Status {
+			return "response mismatch"
+		}
+		return ""
+	}
+
+	return []unitest.Table{
+		{
+			Name:    "Successful Deposit",
+			ExpResp: &payment.Response{ID: "ref-1", Status: payment.PaymentStatusPending},
+			ExcFunc: func(ctx context.Context) any {
+				res, err := gateway.Deposit(ctx, req)
+				if err != nil {
+					return err
+				}
+				return res
+			},
+			CmpFunc: cmpResponse,
+		},
+		{
+			Name: "Rendered Request",
+			ExpResp: request{
+				IdempotencyKey: "tran-1",
+				Body: map[string]any{
+					"reference": "tran-1",
+					"amount":    float64(1099),
+					"currency":  "USD",
+					"card":      map[string]any{"number": "4111111111111111", "month": float64(12)},
+				},
+			},
+			ExcFunc: func(ctx context.Context) any {
+				if _, err := gateway.Deposit(ctx, req); err != nil {
+					return err
+				}
+				return received
+			},
+			CmpFunc: func(got any, exp any) string {
+				gotJSON, _ := json.Marshal(got)
+				expJSON, _ := json.Marshal(exp)
+				if string(gotJSON) != string(expJSON) {
+					return fmt.Sprintf("got %s, expected %s", gotJSON, expJSON)
+				}
+				return ""
+			},
+		},
+		{
+			Name:    "Status",
+			ExpResp: &payment.Response{ID: "ref-1", Status: payment.PaymentStatusSuccess},
+			ExcFunc: func(ctx context.Context) any {
+				res, err := gateway.GetStatus(ctx, "ref-1")
+				if err != nil {
+					return err
+				}
+				return res
+			},
+			CmpFunc: cmpResponse,
+		},
+		{
+			Name:    "Missing Details",
+			ExpResp: errs.InvalidArgument,
+			ExcFunc: func(ctx context.Context) any {
+				// the deposit template refers to card details a bank transfer does not have.
+				_, err := gateway.Deposit(ctx, &payment.Request{
+					ID:                   "tran-3",
+					Amount:               money.MustParse("10"),
+					Currency:             money.USD,
+					PaymentMethod:        models.PaymentMethodBankTransfer,
+					PaymentMethodDetails: json.RawMessage(`{"account_number": "123456789", "bank_code": "021000021", "bank_code_type": "aba"}`),
+				})
+				return err
+			},
+			CmpFunc: func(got any, exp any) string {
+				if err, ok := got.(error); !ok || !errs.HasCode(err, exp.(errs.ErrCode)) {
+					return fmt.Sprintf("got %v", got)
+				}
+				return ""
+			},
+		},
+		{
+			Name:    "Unsupported Operation",
+			ExpResp: errs.InvalidArgument,
+			ExcFunc: func(ctx context.Context) any {
+				_, err := gateway.Withdraw(ctx, &payment.Request{ID: "tran-2"})
+				return err
+			},
+			CmpFunc: func(got any, exp any) string {
+				if !errs.HasCode(got.(error), exp.(errs.ErrCode)) {
+					return fmt.Sprintf("got %v", got)
+				}
+				return ""
+			},
+		},
+	}
+}
+
+func parseCallback() []unitest.Table {
+	cfg := testConfig("http://localhost")
+	gateway, err := New(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	now := time.Now()
+	body := []byte(`{"data": {"id": "ref-1", "state": "done"}}`)
+	sig := payment.NewSignature(cfg.Callback.Secret, time.Minute)
+
+	callback := func(signature string) *payment.Callback {
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		header := http.Header{}
+		header.Set("X-Timestamp", timestamp)
+		header.Set("X-Signature", signature)
+		return &payment.Callback{Header: header, Body: body, ReceivedAt: now}
+	}
+
+	parseWith := func(cb *payment.Callback) any {
+		res, err := gateway.ParseCallback(context.Background(), cb)
+		if err != nil {
+			return err
+		}
+		return res
+	}
+
+	return []unitest.Table{
+		{
+			Name:    "Valid Signature",
+			ExpResp: &payment.Response{ID: "ref-1", Status: payment.PaymentStatusSuccess},
+			ExcFunc: func(ctx context.Context) any {
+				return parseWith(callback(sig.Sign(strconv.FormatInt(now.Unix(), 10), body)))
+			},
+			CmpFunc: func(got any, exp any) string {
+				gotResp, ok := got.(*payment.Response)
+				if !ok {
+					return fmt.Sprintf("got %v", got)
+				}
+				expResp := exp.(*payment.Response)
+				if gotResp.ID != expResp.ID || gotResp.Status != expResp.Status {
+					return "response mismatch"
+				}
+				return ""
+			},
+		},
+		{
+			Name:    "Invalid Signature",
+			ExpResp: errs.Unauthenticated,
+			ExcFunc: func(ctx context.Context) any {
+				return parseWith(callback("00"))
+			},
+			CmpFunc: func(got any, exp any) string {
+				err, ok := got.(error)
+				if !ok || !errs.HasCode(err, exp.(errs.ErrCode)) {
+					return fmt.Sprintf("got %v", got)
+				}
+				return ""
+			},
+		},
+	}
+}
+
+func load(t *testing.T) []unitest.Table {
+	dir := t.TempDir()
+	os.Setenv("GENERIC_TEST_SECRET", `s3cr"t`)
+	t.Cleanup(func() { os.Unsetenv("GENERIC_TEST_SECRET") })
+
+	write := func(name string, cfg any) string {
+		path := filepath.Join(dir, name)
+		b, _ := json.Marshal(cfg)
+		if err := os.WriteFile(path, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	valid := testConfig("http://localhost")
+	valid.Callback.Secret = "${GENERIC_TEST_SECRET}"
+
+	missingStatus := testConfig("http://localhost")
+	delete(missingStatus.Endpoints, opStatus)
+
+	loadWith := func(path string) any {
+		gateways, err := Load(path)
+		if err != nil {
+			return err.Error()
+		}
+		return gateways[0].Callback.Secret
+	}
+
+	cmp := func(got any, exp any) string {
+		if got != exp {
+			return fmt.Sprintf("got %v, expected %v", got, exp)
+		}
+		return ""
+	}
+
+	return []unitest.Table{
+		{
+			Name:    "Environment Variables",
+			ExpResp: `s3cr"t`,
+			ExcFunc: func(ctx context.Context) any {
+				return loadWith(write("valid.json", File{Gateways: []Config{valid}}))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Missing Status Endpoint",
+			ExpResp: `gateway "generic": endpoint status is required`,
+			ExcFunc: func(ctx context.Context) any {
+				return loadWith(write("missing.json", File{Gateways: []Config{missingStatus}}))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Missing File",
+			ExpResp: true,
+			ExcFunc: func(ctx context.Context) any {
+				_, err := Load(filepath.Join(dir, "none.json"))
+				return err != nil
+			},
+			CmpFunc: cmp,
+		},
+	}
+}
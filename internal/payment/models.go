@@ -1,13 +1,17 @@
 package payment
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/3bd-dev/wallet-service/internal/models"
 	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -21,15 +25,76 @@ const (
 )
 
 type Request struct {
-	ID                   string          `json:"id"`
-	Amount               float64         `json:"amount"`
-	CallbackURL          string          `json:"callback_url"`
-	PaymentMethodDetails json.RawMessage `json:"payment_details"`
+	// ID is our transaction ID. It is sent to the gateways as the merchant reference and
+	// the idempotency key, so a repeated request never creates a second payment.
+	ID                   string               `json:"id"`
+	Amount               money.Money          `json:"amount"`
+	Currency             money.Currency       `json:"currency"`
+	CallbackURL          string               `json:"callback_url"`
+	PaymentMethod        models.PaymentMethod `json:"payment_method"`
+	PaymentMethodDetails json.RawMessage      `json:"payment_details"`
+	// ReferenceID is the gateway reference of the original payment, set for refunds.
+	ReferenceID string `json:"reference_id,omitempty"`
+	// CallbackURLFor returns the callback URL of the request when it fails over to
+	// another gateway, nil keeps CallbackURL.
+	CallbackURLFor func(gateway models.PaymentGateway) string `json:"-"`
+	// Dispatched reports an earlier attempt of the request might have reached its
+	// gateway. Such a request never fails over, as the gateway could still move money.
+	Dispatched bool `json:"-"`
+	// BeforeSend is called before the request is sent to a gateway, including every
+	// gateway it fails over to, so the caller can record where it might arrive. The
+	// request is not sent when it fails, nil sends it right away.
+	BeforeSend func(ctx context.Context, gateway models.PaymentGateway) error `json:"-"`
+}
+
+// CreditCard decodes the credit card details of a credit card payment.
+func (r *Request) CreditCard() (*PaymentMethodCreditCardDetails, error) {
+	if r.PaymentMethod != models.PaymentMethodCreditCard {
+		return nil, fmt.Errorf("payment method %s is not a credit card", r.PaymentMethod)
+	}
+
+	var details PaymentMethodCreditCardDetails
+	if err := json.Unmarshal(r.PaymentMethodDetails, &details); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal credit card details: %w", err)
+	}
+	return &details, nil
+}
+
+// BankAccount decodes the bank account details of a bank transfer.
+func (r *Request) BankAccount() (*PaymentMethodBankDetails, error) {
+	if r.PaymentMethod != models.PaymentMethodBankTransfer {
+		return nil, fmt.Errorf("payment method %s is not a bank transfer", r.PaymentMethod)
+	}
+
+	var details PaymentMethodBankDetails
+	if err := json.Unmarshal(r.PaymentMethodDetails, &details); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bank details: %w", err)
+	}
+	return &details, nil
+}
+
+// Callback is a callback received from a payment gateway.
+type Callback struct {
+	Header http.Header
+	Body   []byte
+	// ReceivedAt is when the callback reached us, its signature timestamp is checked against it.
+	ReceivedAt time.Time
 }
 
 type Response struct {
 	Status PaymentStatus `json:"status"`
 	ID     string        `json:"id"`
+	// Gateway is the gateway that handled the request, set by Payment. It differs from
+	// the requested gateway when the request failed over to another gateway.
+	Gateway models.PaymentGateway `json:"-"`
+	// Failovers are the gateways the request failed over from, in order.
+	Failovers []Failover `json:"-"`
+}
+
+// Failover is a gateway a request failed over from, because it could not be sent to it.
+type Failover struct {
+	Gateway models.PaymentGateway
+	Reason  string
 }
 
 type PaymentMethodCreditCardDetails struct {
@@ -64,22 +129,12 @@ func (p *PaymentMethodCreditCardDetails) validate() error {
 		return err
 	}
 
-	expirationParts := strings.Split(p.Expiry, "/")
-	if len(expirationParts) != 2 {
-		return fmt.Errorf("invalid expiration date format, expected MM/YY")
-	}
-
-	month, err := strconv.Atoi(expirationParts[0])
-	if err != nil || month < 1 || month > 12 {
-		return fmt.Errorf("invalid expiration month: %s", expirationParts[0])
-	}
-
-	year, err := strconv.Atoi(expirationParts[1])
+	month, year, err := p.ExpiryDate()
 	if err != nil {
-		return fmt.Errorf("invalid expiration year: %s", expirationParts[1])
+		return err
 	}
 
-	expirationDate := time.Date(2000+year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	expirationDate := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
 
 	currentTime := time.Now()
 	minValidExpiration := currentTime.AddDate(0, 6, 0) // 6 months from now
@@ -90,6 +145,26 @@ func (p *PaymentMethodCreditCardDetails) validate() error {
 	return nil
 }
 
+// ExpiryDate parses the MM/YY expiry of the card into its month and four digit year.
+func (p *PaymentMethodCreditCardDetails) ExpiryDate() (month, year int, err error) {
+	expirationParts := strings.Split(p.Expiry, "/")
+	if len(expirationParts) != 2 {
+		return 0, 0, fmt.Errorf("invalid expiration date format, expected MM/YY")
+	}
+
+	month, err = strconv.Atoi(expirationParts[0])
+	if err != nil || month < 1 || month > 12 {
+		return 0, 0, fmt.Errorf("invalid expiration month: %s", expirationParts[0])
+	}
+
+	year, err = strconv.Atoi(expirationParts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid expiration year: %s", expirationParts[1])
+	}
+
+	return month, 2000 + year, nil
+}
+
 type PaymentMethodBankDetails struct {
 	AccountNumber string `json:"account_number" validate:"required,numeric,min=10,max=34"` // Account number: 10-34 digits
 	BankCode      string `json:"bank_code" validate:"required,alphanum,min=6,max=34"`      // Bank code: varies depending on type (SWIFT, IBAN, etc.)
@@ -120,6 +195,6 @@ func (p *PaymentMethodBankDetails) MaskRaw() json.RawMessage {
 type PaymentRequest struct {
 	ID                   uuid.UUID
 	PaymentMethodDetails PaymentMethodDetails
-	Amount               float64
+	Amount               money.Money
 	CallbackURL          string
 }
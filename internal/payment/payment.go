@@ -5,37 +5,81 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"sort"
 
 	"github.com/3bd-dev/wallet-service/internal/models"
 	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
 )
 
 // PaymentGateway defines the common interface for all payment gateways
 type PaymentGateway interface {
 	Deposit(ctx context.Context, req *Request) (*Response, error)
 	Withdraw(ctx context.Context, req *Request) (*Response, error)
-	VerifyCallback(ctx context.Context, refID string, data []byte) (*Response, error)
-	VerifyMethod(typ models.TransactionType, method models.PaymentMethod) error
+	Refund(ctx context.Context, req *Request) (*Response, error)
+	ParseCallback(ctx context.Context, cb *Callback) (*Response, error)
+	GetStatus(ctx context.Context, refID string) (*Response, error)
+	Capabilities() Capabilities
+	BreakerState() BreakerState
+}
+
+// ReferenceLookup is implemented by the gateways that can find a payment by the merchant
+// reference it was requested with, our transaction ID. It finds the payment of a request
+// whose response was lost, when the request cannot be sent again.
+type ReferenceLookup interface {
+	GetStatusByReference(ctx context.Context, reference string) (*Response, error)
+}
+
+// ErrPaymentNotFound reports the gateway has no payment for the merchant reference.
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// ErrLookupUnsupported reports the gateway cannot find payments by merchant reference.
+var ErrLookupUnsupported = errors.New("payment gateway cannot look up payments by reference")
+
+// ErrUnavailable reports a transient gateway failure, e.g. a network error, a server
+// error or an open circuit breaker. Requests failing with it can be retried later.
+var ErrUnavailable = errors.New("payment gateway unavailable")
+
+// ErrNotSent reports a request that never reached the gateway, e.g. an open circuit
+// breaker or a refused connection, so no money moved and the request can be sent to
+// another gateway. Gateways wrap it together with ErrUnavailable.
+var ErrNotSent = errors.New("payment request not sent")
+
+// IsDialError reports whether err is a failure to connect to the gateway, meaning the
+// request was not sent.
+func IsDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
 }
 
 type Payment struct {
 	gateways map[models.PaymentGateway]PaymentGateway
+	stats    *gatewayStats
+	failover *Router
 }
 
 // NewPayment creates a new Payment struct with the provided gateways
 func New(gateways map[models.PaymentGateway]PaymentGateway) *Payment {
 	return &Payment{
 		gateways: gateways,
+		stats:    newGatewayStats(gateways),
 	}
 }
 
+// EnableFailover makes deposits and withdrawals that could not be sent to their gateway
+// fail over to the next gateway the router finds eligible. It is disabled by default.
+func (p *Payment) EnableFailover(router *Router) {
+	p.failover = router
+}
+
 // Deposit sends a deposit request to the appropriate gateway
 func (p *Payment) Deposit(ctx context.Context, gateway models.PaymentGateway, req *Request) (*Response, error) {
 	if err := p.validateGateway(gateway); err != nil {
 		return nil, err
 	}
 
-	res, err := p.gateways[gateway].Deposit(ctx, req)
+	res, err := p.submit(ctx, models.TransactionTypeDeposit, gateway, req, PaymentGateway.Deposit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to deposit: %w", err)
 	}
@@ -49,7 +93,7 @@ func (p *Payment) Withdraw(ctx context.Context, gateway models.PaymentGateway, r
 		return nil, err
 	}
 
-	res, err := p.gateways[gateway].Withdraw(ctx, req)
+	res, err := p.submit(ctx, models.TransactionTypeWithdrawal, gateway, req, PaymentGateway.Withdraw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to withdraw: %w", err)
 	}
@@ -57,13 +101,93 @@ func (p *Payment) Withdraw(ctx context.Context, gateway models.PaymentGateway, r
 	return res, nil
 }
 
-// VerifyCallback chooses the appropriate gateway to handle the callback
-func (p *Payment) VerifyCallback(ctx context.Context, gatewayName models.PaymentGateway, refID string, data []byte) (*Response, error) {
+// submit sends a request to the gateway. With failover enabled, a request failing with
+// ErrNotSent is sent to the next eligible gateway, until one handles it or none is left.
+// Requests that might have reached a gateway, in this call or an earlier attempt, never
+// fail over, as it could have moved money.
+func (p *Payment) submit(ctx context.Context, typ models.TransactionType, gateway models.PaymentGateway, req *Request, send func(PaymentGateway, context.Context, *Request) (*Response, error)) (*Response, error) {
+	var failovers []Failover
+	for {
+		if req.BeforeSend != nil {
+			if err := req.BeforeSend(ctx, gateway); err != nil {
+				return nil, err
+			}
+		}
+
+		res, err := send(p.gateways[gateway], ctx, req)
+		p.record(gateway, err)
+		if err == nil {
+			res.Gateway = gateway
+			res.Failovers = failovers
+			return res, nil
+		}
+
+		if p.failover == nil || req.Dispatched || !errors.Is(err, ErrNotSent) {
+			return nil, err
+		}
+
+		failovers = append(failovers, Failover{Gateway: gateway, Reason: err.Error()})
+		exclude := make([]models.PaymentGateway, 0, len(failovers))
+		for _, f := range failovers {
+			exclude = append(exclude, f.Gateway)
+		}
+
+		route, routeErr := p.failover.route(RouteRequest{
+			Type:     typ,
+			Method:   req.PaymentMethod,
+			Amount:   req.Amount,
+			Currency: req.Currency,
+		}, exclude)
+		if routeErr != nil {
+			return nil, err
+		}
+
+		next := *req
+		if req.CallbackURLFor != nil {
+			next.CallbackURL = req.CallbackURLFor(route.Gateway)
+		}
+		gateway, req = route.Gateway, &next
+	}
+}
+
+// Refund sends a refund request of a previous payment to the appropriate gateway
+func (p *Payment) Refund(ctx context.Context, gateway models.PaymentGateway, req *Request) (*Response, error) {
+	if err := p.validateGateway(gateway); err != nil {
+		return nil, err
+	}
+
+	res, err := p.gateways[gateway].Refund(ctx, req)
+	p.record(gateway, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refund: %w", err)
+	}
+
+	return res, nil
+}
+
+// VerifyCallback chooses the appropriate gateway to handle the callback, and checks
+// it reports the payment with the given reference ID
+func (p *Payment) VerifyCallback(ctx context.Context, gatewayName models.PaymentGateway, refID string, cb *Callback) (*Response, error) {
+	res, err := p.ParseCallback(ctx, gatewayName, cb)
+	if err != nil {
+		return nil, err
+	}
+
+	if refID != res.ID {
+		return nil, errs.New(errs.InvalidArgument, errors.New("invalid reference ID"))
+	}
+
+	return res, nil
+}
+
+// ParseCallback chooses the appropriate gateway to verify the callback and decode the
+// payment it reports
+func (p *Payment) ParseCallback(ctx context.Context, gatewayName models.PaymentGateway, cb *Callback) (*Response, error) {
 	if err := p.validateGateway(gatewayName); err != nil {
 		return nil, err
 	}
 
-	res, err := p.gateways[gatewayName].VerifyCallback(ctx, refID, data)
+	res, err := p.gateways[gatewayName].ParseCallback(ctx, cb)
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify callback: %w", err)
 	}
@@ -71,12 +195,56 @@ func (p *Payment) VerifyCallback(ctx context.Context, gatewayName models.Payment
 	return res, nil
 }
 
+// VerifyGateway verifies the gateway is configured
+func (p *Payment) VerifyGateway(gateway models.PaymentGateway) error {
+	if err := p.validateGateway(gateway); err != nil {
+		return errs.New(errs.NotFound, err)
+	}
+	return nil
+}
+
+// GetStatus queries the appropriate gateway for the status of a payment
+func (p *Payment) GetStatus(ctx context.Context, gateway models.PaymentGateway, refID string) (*Response, error) {
+	if err := p.validateGateway(gateway); err != nil {
+		return nil, err
+	}
+
+	res, err := p.gateways[gateway].GetStatus(ctx, refID)
+	p.record(gateway, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+
+	return res, nil
+}
+
+// GetStatusByReference queries the appropriate gateway for the status of the payment
+// requested with the merchant reference, see ReferenceLookup.
+func (p *Payment) GetStatusByReference(ctx context.Context, gateway models.PaymentGateway, reference string) (*Response, error) {
+	if err := p.validateGateway(gateway); err != nil {
+		return nil, err
+	}
+
+	lookup, ok := p.gateways[gateway].(ReferenceLookup)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrLookupUnsupported, gateway)
+	}
+
+	res, err := lookup.GetStatusByReference(ctx, reference)
+	p.record(gateway, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status by reference: %w", err)
+	}
+
+	return res, nil
+}
+
 // VerifyMethod verifies the payment method details
 func (p *Payment) VerifyMethod(gateway models.PaymentGateway, typ models.TransactionType, method models.PaymentMethod, paymMethDet json.RawMessage) (PaymentMethodDetails, error) {
 	if err := p.validateGateway(gateway); err != nil {
 		return nil, err
 	}
-	err := p.gateways[gateway].VerifyMethod(typ, method)
+	err := p.gateways[gateway].Capabilities().VerifyMethod(typ, method)
 	if err != nil {
 		return nil, errs.New(errs.InvalidArgument, fmt.Errorf("failed to verify method: %w", err))
 	}
@@ -92,6 +260,48 @@ func (p *Payment) VerifyMethod(gateway models.PaymentGateway, typ models.Transac
 	return paymMeth, nil
 }
 
+// VerifyCurrency verifies the gateway supports the currency
+func (p *Payment) VerifyCurrency(gateway models.PaymentGateway, currency money.Currency) error {
+	if err := p.validateGateway(gateway); err != nil {
+		return err
+	}
+
+	if err := p.gateways[gateway].Capabilities().VerifyCurrency(currency); err != nil {
+		return errs.New(errs.InvalidArgument, fmt.Errorf("failed to verify currency: %w", err))
+	}
+	return nil
+}
+
+// VerifyAmount verifies the gateway supports the currency and the amount is within its limits
+func (p *Payment) VerifyAmount(gateway models.PaymentGateway, amount money.Money, currency money.Currency) error {
+	if err := p.validateGateway(gateway); err != nil {
+		return err
+	}
+
+	if err := p.gateways[gateway].Capabilities().VerifyAmount(amount, currency); err != nil {
+		return errs.New(errs.InvalidArgument, fmt.Errorf("failed to verify amount: %w", err))
+	}
+	return nil
+}
+
+// Gateways lists the registered gateways, sorted by name, with their capabilities and
+// the current state of their circuit breaker
+func (p *Payment) Gateways() []GatewayInfo {
+	res := make([]GatewayInfo, 0, len(p.gateways))
+	for name, gateway := range p.gateways {
+		res = append(res, GatewayInfo{
+			Name:         name,
+			Capabilities: gateway.Capabilities(),
+			BreakerState: gateway.BreakerState(),
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
 // parsePaymentMethodDetails decouples the parsing logic to make the addition of new payment methods easier.
 func (p *Payment) parsePaymentMethodDetails(method models.PaymentMethod, data json.RawMessage) (PaymentMethodDetails, error) {
 	switch method {
@@ -112,6 +322,13 @@ func (p *Payment) parsePaymentMethodDetails(method models.PaymentMethod, data js
 	}
 }
 
+// record stores whether the gateway was available to handle a request, for the routing
+// of transactions. Requests the gateway rejected, e.g. for invalid payment details, do
+// not make it less available.
+func (p *Payment) record(gateway models.PaymentGateway, err error) {
+	p.stats.record(gateway, !errors.Is(err, ErrUnavailable))
+}
+
 func (p *Payment) validateGateway(gateway models.PaymentGateway) error {
 	if _, ok := p.gateways[gateway]; !ok {
 		return fmt.Errorf("unsupported gateway: %s", gateway)
@@ -4,18 +4,26 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/3bd-dev/wallet-service/internal/models"
 	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
 	"github.com/3bd-dev/wallet-service/pkg/unitest"
 )
 
 type mockGateway struct {
-	depositFunc        func(ctx context.Context, req *Request) (*Response, error)
-	withdrawFunc       func(ctx context.Context, req *Request) (*Response, error)
-	verifyCallbackFunc func(ctx context.Context, refID string, data []byte) (*Response, error)
-	verifyMethodFunc   func(typ models.TransactionType, method models.PaymentMethod) error
+	depositFunc       func(ctx context.Context, req *Request) (*Response, error)
+	withdrawFunc      func(ctx context.Context, req *Request) (*Response, error)
+	refundFunc        func(ctx context.Context, req *Request) (*Response, error)
+	parseCallbackFunc func(ctx context.Context, cb *Callback) (*Response, error)
+	getStatusFunc     func(ctx context.Context, refID string) (*Response, error)
+	capabilities      Capabilities
+	breakerState      BreakerState
 }
 
 func (m *mockGateway) Deposit(ctx context.Context, req *Request) (*Response, error) {
@@ -26,21 +34,43 @@ func (m *mockGateway) Withdraw(ctx context.Context, req *Request) (*Response, er
 	return m.withdrawFunc(ctx, req)
 }
 
-func (m *mockGateway) VerifyCallback(ctx context.Context, refID string, data []byte) (*Response, error) {
-	return m.verifyCallbackFunc(ctx, refID, data)
+func (m *mockGateway) Refund(ctx context.Context, req *Request) (*Response, error) {
+	return m.refundFunc(ctx, req)
 }
 
-func (m *mockGateway) VerifyMethod(typ models.TransactionType, method models.PaymentMethod) error {
-	return m.verifyMethodFunc(typ, method)
+func (m *mockGateway) ParseCallback(ctx context.Context, cb *Callback) (*Response, error) {
+	return m.parseCallbackFunc(ctx, cb)
 }
+
+func (m *mockGateway) GetStatus(ctx context.Context, refID string) (*Response, error) {
+	return m.getStatusFunc(ctx, refID)
+}
+
+func (m *mockGateway) Capabilities() Capabilities {
+	return m.capabilities
+}
+
+func (m *mockGateway) BreakerState() BreakerState {
+	return m.breakerState
+}
+
 func Test_Payment(t *testing.T) {
 	t.Parallel()
 
 	unitest.Run(t, deposit(), "deposit")
 	unitest.Run(t, withdrawal(), "withdrawal")
+	unitest.Run(t, refund(), "refund")
 	unitest.Run(t, VerifyCallback(), "verifyCallback")
+	unitest.Run(t, getStatus(), "getStatus")
 	unitest.Run(t, verifyMethodBankTransfer(), "verifyMethodBankTransfer")
 	unitest.Run(t, verifyMethodCreditCard(), "verifyMethodCreditCard")
+	unitest.Run(t, verifyCurrency(), "verifyCurrency")
+	unitest.Run(t, amountLimits(), "amountLimits")
+	unitest.Run(t, gateways(), "gateways")
+	unitest.Run(t, route(), "route")
+	unitest.Run(t, failover(), "failover")
+	unitest.Run(t, paymentMethodDetails(), "paymentMethodDetails")
+	unitest.Run(t, signature(), "signature")
 }
 
 func deposit() []unitest.Table {
@@ -145,10 +175,130 @@ func withdrawal() []unitest.Table {
 	return tests
 }
 
+func refund() []unitest.Table {
+	mockGateway := &mockGateway{
+		refundFunc: func(ctx context.Context, req *Request) (*Response, error) {
+			if req.ReferenceID == "" {
+				return nil, errors.New("missing reference ID")
+			}
+			return &Response{Status: "pending"}, nil
+		},
+	}
+
+	payment := New(map[models.PaymentGateway]PaymentGateway{
+		models.PaymentGateway("mock"): mockGateway,
+	})
+
+	tests := []unitest.Table{
+		{
+			Name: "Successful Refund",
+			ExpResp: &Response{
+				Status: "pending",
+			},
+			ExcFunc: func(ctx context.Context) any {
+				resp, _ := payment.Refund(ctx, models.PaymentGateway("mock"), &Request{ReferenceID: "ref123"})
+				return resp
+			},
+			CmpFunc: func(got any, exp any) string {
+				gotResp := got.(*Response)
+				expResp := exp.(*Response)
+				if gotResp.Status != expResp.Status {
+					return "status mismatch"
+				}
+				return ""
+			},
+		},
+		{
+			Name:    "Gateway Error",
+			ExpResp: errors.New("failed to refund: missing reference ID"),
+			ExcFunc: func(ctx context.Context) any {
+				_, err := payment.Refund(ctx, models.PaymentGateway("mock"), &Request{})
+				return err
+			},
+			CmpFunc: func(got any, exp any) string {
+				gotErr := got.(error)
+				expErr := exp.(error)
+				if gotErr.Error() != expErr.Error() {
+					return "error message mismatch"
+				}
+				return ""
+			},
+		},
+	}
+
+	return tests
+}
+
+func getStatus() []unitest.Table {
+	mockGateway := &mockGateway{
+		getStatusFunc: func(ctx context.Context, refID string) (*Response, error) {
+			if refID != "ref123" {
+				return nil, errors.New("payment not found")
+			}
+			return &Response{ID: refID, Status: PaymentStatusSuccess}, nil
+		},
+	}
+
+	payment := New(map[models.PaymentGateway]PaymentGateway{
+		models.PaymentGateway("mock"): mockGateway,
+	})
+
+	cmpErr := func(got any, exp any) string {
+		gotErr, _ := got.(error)
+		expErr := exp.(error)
+		if gotErr == nil || gotErr.Error() != expErr.Error() {
+			return "error message mismatch"
+		}
+		return ""
+	}
+
+	tests := []unitest.Table{
+		{
+			Name: "Successful GetStatus",
+			ExpResp: &Response{
+				ID:     "ref123",
+				Status: PaymentStatusSuccess,
+			},
+			ExcFunc: func(ctx context.Context) any {
+				resp, _ := payment.GetStatus(ctx, models.PaymentGateway("mock"), "ref123")
+				return resp
+			},
+			CmpFunc: func(got any, exp any) string {
+				gotResp := got.(*Response)
+				expResp := exp.(*Response)
+				if gotResp.ID != expResp.ID || gotResp.Status != expResp.Status {
+					return "response mismatch"
+				}
+				return ""
+			},
+		},
+		{
+			Name:    "Gateway Error",
+			ExpResp: errors.New("failed to get status: payment not found"),
+			ExcFunc: func(ctx context.Context) any {
+				_, err := payment.GetStatus(ctx, models.PaymentGateway("mock"), "unknown")
+				return err
+			},
+			CmpFunc: cmpErr,
+		},
+		{
+			Name:    "Unsupported Gateway",
+			ExpResp: errors.New("unsupported gateway: invalid"),
+			ExcFunc: func(ctx context.Context) any {
+				_, err := payment.GetStatus(ctx, models.PaymentGateway("invalid"), "ref123")
+				return err
+			},
+			CmpFunc: cmpErr,
+		},
+	}
+
+	return tests
+}
+
 func VerifyCallback() []unitest.Table {
 	mockGateway := &mockGateway{
-		verifyCallbackFunc: func(ctx context.Context, refID string, data []byte) (*Response, error) {
-			return &Response{Status: "success"}, nil
+		parseCallbackFunc: func(ctx context.Context, cb *Callback) (*Response, error) {
+			return &Response{ID: "ref123", Status: "success"}, nil
 		},
 	}
 
@@ -163,7 +313,7 @@ func VerifyCallback() []unitest.Table {
 				Status: "success",
 			},
 			ExcFunc: func(ctx context.Context) any {
-				resp, _ := payment.VerifyCallback(ctx, models.PaymentGateway("mock"), "ref123", []byte{})
+				resp, _ := payment.VerifyCallback(ctx, models.PaymentGateway("mock"), "ref123", &Callback{})
 				return resp
 			},
 			CmpFunc: func(got any, exp any) string {
@@ -175,11 +325,25 @@ func VerifyCallback() []unitest.Table {
 				return ""
 			},
 		},
+		{
+			Name:    "Reference ID Mismatch",
+			ExpResp: errs.InvalidArgument,
+			ExcFunc: func(ctx context.Context) any {
+				_, err := payment.VerifyCallback(ctx, models.PaymentGateway("mock"), "ref456", &Callback{})
+				return err
+			},
+			CmpFunc: func(got any, exp any) string {
+				if !errs.HasCode(got.(error), exp.(errs.ErrCode)) {
+					return fmt.Sprintf("expected error code %v, got %v", exp, got)
+				}
+				return ""
+			},
+		},
 		{
 			Name:    "Unsupported Gateway",
 			ExpResp: errors.New("unsupported gateway: invalid"),
 			ExcFunc: func(ctx context.Context) any {
-				_, err := payment.VerifyCallback(ctx, models.PaymentGateway("invalid"), "ref123", []byte{})
+				_, err := payment.VerifyCallback(ctx, models.PaymentGateway("invalid"), "ref123", &Callback{})
 				return err
 			},
 			CmpFunc: func(got any, exp any) string {
@@ -198,11 +362,10 @@ func VerifyCallback() []unitest.Table {
 
 func verifyMethodBankTransfer() []unitest.Table {
 	mockGateway := &mockGateway{
-		verifyMethodFunc: func(typ models.TransactionType, method models.PaymentMethod) error {
-			if typ == models.TransactionType("valid") && method == models.PaymentMethodBankTransfer {
-				return nil
-			}
-			return fmt.Errorf("invalid transaction type or payment method")
+		capabilities: Capabilities{
+			Methods: map[models.TransactionType][]models.PaymentMethod{
+				models.TransactionType("valid"): {models.PaymentMethodBankTransfer},
+			},
 		},
 	}
 
@@ -210,7 +373,7 @@ func verifyMethodBankTransfer() []unitest.Table {
 		models.PaymentGateway("mock"): mockGateway,
 	})
 
-	validCreditCard := []byte(`{"number": "4111111111111111", "expiry": "12/26", "cvv": "123"}`)
+	validCreditCard := []byte(`{"number": "4111111111111111", "expiry": "12/30", "cvv": "123"}`)
 	validBandTransfer := []byte(`{"account_number": "1234567890", "bank_code": "BOFAUS3NXXX","bank_code_type": "SWIFT"}`)
 
 	tests := []unitest.Table{
@@ -282,11 +445,10 @@ func verifyMethodBankTransfer() []unitest.Table {
 
 func verifyMethodCreditCard() []unitest.Table {
 	mockGateway := &mockGateway{
-		verifyMethodFunc: func(typ models.TransactionType, method models.PaymentMethod) error {
-			if typ == models.TransactionType("valid") && method == models.PaymentMethodCreditCard {
-				return nil
-			}
-			return fmt.Errorf("invalid transaction type or payment method")
+		capabilities: Capabilities{
+			Methods: map[models.TransactionType][]models.PaymentMethod{
+				models.TransactionType("valid"): {models.PaymentMethodCreditCard},
+			},
 		},
 	}
 
@@ -294,7 +456,7 @@ func verifyMethodCreditCard() []unitest.Table {
 		models.PaymentGateway("mock"): mockGateway,
 	})
 
-	validCreditCard := []byte(`{"number": "4111111111111111", "expiry": "12/26", "cvv": "123"}`)
+	validCreditCard := []byte(`{"number": "4111111111111111", "expiry": "12/30", "cvv": "123"}`)
 	validBandTransfer := []byte(`{"account_number": "1234567890", "bank_code": "BOFAUS3NXXX","bank_code_type": "SWIFT"}`)
 
 	tests := []unitest.Table{
@@ -349,3 +511,580 @@ func verifyMethodCreditCard() []unitest.Table {
 
 	return tests
 }
+
+func verifyCurrency() []unitest.Table {
+	mockGateway := &mockGateway{
+		capabilities: Capabilities{
+			Currencies: []CurrencyCapability{{Currency: money.USD}},
+		},
+	}
+
+	payment := New(map[models.PaymentGateway]PaymentGateway{
+		models.PaymentGateway("mock"): mockGateway,
+	})
+
+	tests := []unitest.Table{
+		{
+			Name:    "Supported Currency",
+			ExpResp: nil,
+			ExcFunc: func(ctx context.Context) any {
+				return payment.VerifyCurrency(models.PaymentGateway("mock"), money.USD)
+			},
+			CmpFunc: func(got any, exp any) string {
+				if got != nil {
+					return fmt.Sprintf("expected nil, got %v", got)
+				}
+				return ""
+			},
+		},
+		{
+			Name:    "Unsupported Currency",
+			ExpResp: errs.InvalidArgument,
+			ExcFunc: func(ctx context.Context) any {
+				return payment.VerifyCurrency(models.PaymentGateway("mock"), money.JPY)
+			},
+			CmpFunc: func(got any, exp any) string {
+				gotErr, ok := got.(*errs.Error)
+				if !ok {
+					return fmt.Sprintf("expected *errs.Error, got %v", got)
+				}
+				if gotErr.Code != exp.(errs.ErrCode) {
+					return fmt.Sprintf("expected error code %v, got %v", exp, gotErr.Code)
+				}
+				return ""
+			},
+		},
+	}
+
+	return tests
+}
+
+func paymentMethodDetails() []unitest.Table {
+	cardReq := &Request{
+		PaymentMethod:        models.PaymentMethodCreditCard,
+		PaymentMethodDetails: []byte(`{"number": "4111111111111111", "expiry": "07/30", "cvv": "123"}`),
+	}
+
+	tests := []unitest.Table{
+		{
+			Name:    "Credit Card Expiry Date",
+			ExpResp: [2]int{7, 2030},
+			ExcFunc: func(ctx context.Context) any {
+				card, err := cardReq.CreditCard()
+				if err != nil {
+					return err
+				}
+				month, year, err := card.ExpiryDate()
+				if err != nil {
+					return err
+				}
+				return [2]int{month, year}
+			},
+			CmpFunc: func(got any, exp any) string {
+				if got != exp {
+					return fmt.Sprintf("expected %v, got %v", exp, got)
+				}
+				return ""
+			},
+		}, {
+			Name:    "Bank Account Of Credit Card Payment",
+			ExpResp: nil,
+			ExcFunc: func(ctx context.Context) any {
+				_, err := cardReq.BankAccount()
+				return err
+			},
+			CmpFunc: func(got any, exp any) string {
+				if got == nil {
+					return "expected error, got nil"
+				}
+				return ""
+			},
+		},
+	}
+
+	return tests
+}
+
+func signature() []unitest.Table {
+	sig := NewSignature("secret", 5*time.Minute)
+	body := []byte(`{"id": "ref123", "status": "success"}`)
+	receivedAt := time.Now()
+	now := strconv.FormatInt(receivedAt.Unix(), 10)
+	expired := strconv.FormatInt(receivedAt.Add(-10*time.Minute).Unix(), 10)
+
+	cmpCode := func(got any, exp any) string {
+		if exp == nil {
+			if got != nil {
+				return fmt.Sprintf("expected nil, got %v", got)
+			}
+			return ""
+		}
+		if !errs.HasCode(got.(error), exp.(errs.ErrCode)) {
+			return fmt.Sprintf("expected error code %v, got %v", exp, got)
+		}
+		return ""
+	}
+
+	tests := []unitest.Table{
+		{
+			Name:    "Valid Signature",
+			ExpResp: nil,
+			ExcFunc: func(ctx context.Context) any {
+				if err := sig.Verify(now, sig.Sign(now, body), body, receivedAt); err != nil {
+					return err
+				}
+				return nil
+			},
+			CmpFunc: cmpCode,
+		}, {
+			Name:    "Tampered Body",
+			ExpResp: errs.Unauthenticated,
+			ExcFunc: func(ctx context.Context) any {
+				return sig.Verify(now, sig.Sign(now, body), []byte(`{"id": "ref123", "status": "failed"}`), receivedAt)
+			},
+			CmpFunc: cmpCode,
+		}, {
+			Name:    "Wrong Secret",
+			ExpResp: errs.Unauthenticated,
+			ExcFunc: func(ctx context.Context) any {
+				return sig.Verify(now, NewSignature("other", 5*time.Minute).Sign(now, body), body, receivedAt)
+			},
+			CmpFunc: cmpCode,
+		}, {
+			Name:    "Expired Timestamp",
+			ExpResp: errs.Unauthenticated,
+			ExcFunc: func(ctx context.Context) any {
+				return sig.Verify(expired, sig.Sign(expired, body), body, receivedAt)
+			},
+			CmpFunc: cmpCode,
+		}, {
+			Name:    "Replayed Callback Received In Time",
+			ExpResp: nil,
+			ExcFunc: func(ctx context.Context) any {
+				if err := sig.Verify(expired, sig.Sign(expired, body), body, receivedAt.Add(-9*time.Minute)); err != nil {
+					return err
+				}
+				return nil
+			},
+			CmpFunc: cmpCode,
+		}, {
+			Name:    "Missing Signature",
+			ExpResp: errs.Unauthenticated,
+			ExcFunc: func(ctx context.Context) any {
+				return sig.Verify(now, "", body, receivedAt)
+			},
+			CmpFunc: cmpCode,
+		},
+	}
+
+	return tests
+}
+
+func amountLimits() []unitest.Table {
+	cmpCode := func(got any, exp any) string {
+		if exp == nil {
+			if got != nil {
+				return fmt.Sprintf("expected nil, got %v", got)
+			}
+			return ""
+		}
+		if err, ok := got.(error); !ok || !errs.HasCode(err, exp.(errs.ErrCode)) {
+			return fmt.Sprintf("expected error code %v, got %v", exp, got)
+		}
+		return ""
+	}
+
+	caps := Capabilities{
+		Currencies: []CurrencyCapability{{Currency: money.USD}, {Currency: money.EUR}},
+	}
+
+	payment := New(map[models.PaymentGateway]PaymentGateway{
+		models.PaymentGateway("mock"): &mockGateway{capabilities: caps.WithAmountLimits(
+			map[string]money.Money{"USD": money.MustParse("1"), "GBP": money.MustParse("1")},
+			map[string]money.Money{"USD": money.MustParse("100")},
+		)},
+	})
+
+	tests := []unitest.Table{
+		{
+			Name: "Configured Limits",
+			ExpResp: []CurrencyCapability{
+				{Currency: money.USD, MinAmount: money.MustParse("1"), MaxAmount: money.MustParse("100")},
+				{Currency: money.EUR},
+			},
+			ExcFunc: func(ctx context.Context) any {
+				return payment.Gateways()[0].Capabilities.Currencies
+			},
+			CmpFunc: func(got any, exp any) string {
+				if !reflect.DeepEqual(got, exp) {
+					return fmt.Sprintf("expected %v, got %v", exp, got)
+				}
+				return ""
+			},
+		},
+		{
+			Name:    "Defaults Unchanged",
+			ExpResp: []CurrencyCapability{{Currency: money.USD}, {Currency: money.EUR}},
+			ExcFunc: func(ctx context.Context) any {
+				return caps.Currencies
+			},
+			CmpFunc: func(got any, exp any) string {
+				if !reflect.DeepEqual(got, exp) {
+					return fmt.Sprintf("expected %v, got %v", exp, got)
+				}
+				return ""
+			},
+		},
+		{
+			Name:    "Within Limits",
+			ExpResp: nil,
+			ExcFunc: func(ctx context.Context) any {
+				return payment.VerifyAmount(models.PaymentGateway("mock"), money.MustParse("100"), money.USD)
+			},
+			CmpFunc: cmpCode,
+		},
+		{
+			Name:    "Below Minimum",
+			ExpResp: errs.InvalidArgument,
+			ExcFunc: func(ctx context.Context) any {
+				return payment.VerifyAmount(models.PaymentGateway("mock"), money.MustParse("0.99"), money.USD)
+			},
+			CmpFunc: cmpCode,
+		},
+		{
+			Name:    "Above Maximum",
+			ExpResp: errs.InvalidArgument,
+			ExcFunc: func(ctx context.Context) any {
+				return payment.VerifyAmount(models.PaymentGateway("mock"), money.MustParse("100.01"), money.USD)
+			},
+			CmpFunc: cmpCode,
+		},
+		{
+			Name:    "No Limits Configured",
+			ExpResp: nil,
+			ExcFunc: func(ctx context.Context) any {
+				return payment.VerifyAmount(models.PaymentGateway("mock"), money.MustParse("1000000"), money.EUR)
+			},
+			CmpFunc: cmpCode,
+		},
+	}
+
+	return tests
+}
+
+func gateways() []unitest.Table {
+	payment := New(map[models.PaymentGateway]PaymentGateway{
+		models.PaymentGateway("b"): &mockGateway{breakerState: BreakerStateOpen},
+		models.PaymentGateway("a"): &mockGateway{breakerState: BreakerStateClosed},
+	})
+
+	tests := []unitest.Table{
+		{
+			Name: "Sorted With Breaker State",
+			ExpResp: []GatewayInfo{
+				{Name: "a", BreakerState: BreakerStateClosed},
+				{Name: "b", BreakerState: BreakerStateOpen},
+			},
+			ExcFunc: func(ctx context.Context) any {
+				return payment.Gateways()
+			},
+			CmpFunc: func(got any, exp any) string {
+				gotInfo, expInfo := got.([]GatewayInfo), exp.([]GatewayInfo)
+				if len(gotInfo) != len(expInfo) {
+					return fmt.Sprintf("expected %d gateways, got %d", len(expInfo), len(gotInfo))
+				}
+				for i := range expInfo {
+					if gotInfo[i].Name != expInfo[i].Name || gotInfo[i].BreakerState != expInfo[i].BreakerState {
+						return fmt.Sprintf("expected %v, got %v", expInfo[i], gotInfo[i])
+					}
+				}
+				return ""
+			},
+		},
+	}
+
+	return tests
+}
+
+func route() []unitest.Table {
+	caps := Capabilities{
+		Methods: map[models.TransactionType][]models.PaymentMethod{
+			models.TransactionTypeDeposit: {models.PaymentMethodCreditCard},
+		},
+		Currencies: []CurrencyCapability{{Currency: money.USD, MaxAmount: money.MustParse("100")}},
+	}
+	deposit := RouteRequest{
+		Type:     models.TransactionTypeDeposit,
+		Method:   models.PaymentMethodCreditCard,
+		Amount:   money.MustParse("10"),
+		Currency: money.USD,
+	}
+
+	routeWith := func(weights map[string]int, gateways map[models.PaymentGateway]PaymentGateway, req RouteRequest) any {
+		route, err := NewRouter(New(gateways), RouterConfig{Weights: weights}).Route(req)
+		if err != nil {
+			return err
+		}
+		return route
+	}
+
+	// gateway "a" failed its latest requests, so it loses to "b" despite sorting first.
+	unavailable := New(map[models.PaymentGateway]PaymentGateway{
+		"a": &mockGateway{capabilities: caps},
+		"b": &mockGateway{capabilities: caps},
+	})
+	for i := 0; i < 10; i++ {
+		unavailable.record("a", ErrUnavailable)
+	}
+
+	tests := []unitest.Table{
+		{
+			Name:    "Tie Goes To First Name",
+			ExpResp: models.PaymentGateway("a"),
+			ExcFunc: func(ctx context.Context) any {
+				return routeWith(nil, map[models.PaymentGateway]PaymentGateway{
+					"b": &mockGateway{capabilities: caps},
+					"a": &mockGateway{capabilities: caps},
+				}, deposit)
+			},
+		},
+		{
+			Name:    "Highest Weight",
+			ExpResp: models.PaymentGateway("b"),
+			ExcFunc: func(ctx context.Context) any {
+				return routeWith(map[string]int{"b": 2}, map[models.PaymentGateway]PaymentGateway{
+					"a": &mockGateway{capabilities: caps},
+					"b": &mockGateway{capabilities: caps},
+				}, deposit)
+			},
+		},
+		{
+			Name:    "Disabled Gateway",
+			ExpResp: models.PaymentGateway("b"),
+			ExcFunc: func(ctx context.Context) any {
+				return routeWith(map[string]int{"a": 0}, map[models.PaymentGateway]PaymentGateway{
+					"a": &mockGateway{capabilities: caps},
+					"b": &mockGateway{capabilities: caps},
+				}, deposit)
+			},
+		},
+		{
+			Name:    "Open Circuit Breaker",
+			ExpResp: models.PaymentGateway("b"),
+			ExcFunc: func(ctx context.Context) any {
+				return routeWith(nil, map[models.PaymentGateway]PaymentGateway{
+					"a": &mockGateway{capabilities: caps, breakerState: BreakerStateOpen},
+					"b": &mockGateway{capabilities: caps, breakerState: BreakerStateHalfOpen},
+				}, deposit)
+			},
+		},
+		{
+			Name:    "Unsupported Capabilities",
+			ExpResp: models.PaymentGateway("b"),
+			ExcFunc: func(ctx context.Context) any {
+				return routeWith(nil, map[models.PaymentGateway]PaymentGateway{
+					"a": &mockGateway{},
+					"b": &mockGateway{capabilities: caps},
+				}, deposit)
+			},
+		},
+		{
+			Name:    "Amount Outside Limits",
+			ExpResp: models.PaymentGateway("b"),
+			ExcFunc: func(ctx context.Context) any {
+				req := deposit
+				req.Amount = money.MustParse("150")
+				got := routeWith(nil, map[models.PaymentGateway]PaymentGateway{
+					"a": &mockGateway{capabilities: caps},
+					"b": &mockGateway{capabilities: Capabilities{Methods: caps.Methods, Currencies: []CurrencyCapability{{Currency: money.USD}}}},
+				}, req)
+				if route, ok := got.(*Route); ok && !strings.Contains(route.Reason, "a does not accept 150") {
+					return fmt.Errorf("expected a to be skipped for its limits, got reason %q", route.Reason)
+				}
+				return got
+			},
+		},
+		{
+			Name:    "Low Success Rate",
+			ExpResp: models.PaymentGateway("b"),
+			ExcFunc: func(ctx context.Context) any {
+				route, err := NewRouter(unavailable, RouterConfig{}).Route(deposit)
+				if err != nil {
+					return err
+				}
+				return route
+			},
+		},
+		{
+			Name:    "No Eligible Gateway",
+			ExpResp: errs.InvalidArgument,
+			ExcFunc: func(ctx context.Context) any {
+				req := deposit
+				req.Currency = money.GBP
+				return routeWith(nil, map[models.PaymentGateway]PaymentGateway{
+					"a": &mockGateway{capabilities: caps},
+				}, req)
+			},
+		},
+	}
+
+	for i := range tests {
+		tests[i].CmpFunc = func(got any, exp any) string {
+			if code, ok := exp.(errs.ErrCode); ok {
+				if err, ok := got.(error); !ok || !errs.HasCode(err, code) {
+					return fmt.Sprintf("expected error code %v, got %v", exp, got)
+				}
+				return ""
+			}
+			route, ok := got.(*Route)
+			if !ok || route.Gateway != exp {
+				return fmt.Sprintf("expected gateway %v, got %v", exp, got)
+			}
+			if route.Reason == "" {
+				return "expected a routing reason"
+			}
+			return ""
+		}
+	}
+
+	return tests
+}
+
+func failover() []unitest.Table {
+	caps := Capabilities{
+		Methods: map[models.TransactionType][]models.PaymentMethod{
+			models.TransactionTypeDeposit: {models.PaymentMethodCreditCard},
+		},
+		Currencies: []CurrencyCapability{{Currency: money.USD}},
+	}
+	notSent := fmt.Errorf("%w: %w: circuit breaker is open", ErrUnavailable, ErrNotSent)
+	timeout := fmt.Errorf("%w: timeout", ErrUnavailable)
+	errRecord := errors.New("failed to record")
+
+	deposit := func(failover bool, errA error, dispatched bool, sent *[]models.PaymentGateway) any {
+		payment := New(map[models.PaymentGateway]PaymentGateway{
+			"a": &mockGateway{capabilities: caps, depositFunc: func(ctx context.Context, req *Request) (*Response, error) {
+				return nil, errA
+			}},
+			"b": &mockGateway{capabilities: caps, depositFunc: func(ctx context.Context, req *Request) (*Response, error) {
+				return &Response{ID: req.CallbackURL, Status: PaymentStatusPending}, nil
+			}},
+		})
+		if failover {
+			payment.EnableFailover(NewRouter(payment, RouterConfig{}))
+		}
+
+		res, err := payment.Deposit(context.Background(), "a", &Request{
+			Amount:        money.MustParse("10"),
+			Currency:      money.USD,
+			PaymentMethod: models.PaymentMethodCreditCard,
+			CallbackURL:   "/webhooks/a",
+			CallbackURLFor: func(gateway models.PaymentGateway) string {
+				return "/webhooks/" + string(gateway)
+			},
+			Dispatched: dispatched,
+			BeforeSend: func(ctx context.Context, gateway models.PaymentGateway) error {
+				if sent == nil {
+					return nil
+				}
+				*sent = append(*sent, gateway)
+				return nil
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return res
+	}
+
+	tests := []unitest.Table{
+		{
+			Name:    "Not Sent",
+			ExpResp: &Response{ID: "/webhooks/b", Gateway: "b", Failovers: []Failover{{Gateway: "a"}}},
+			ExcFunc: func(ctx context.Context) any {
+				return deposit(true, notSent, false, nil)
+			},
+		},
+		{
+			Name:    "Disabled",
+			ExpResp: ErrNotSent,
+			ExcFunc: func(ctx context.Context) any {
+				return deposit(false, notSent, false, nil)
+			},
+		},
+		{
+			Name:    "Might Have Been Sent",
+			ExpResp: ErrUnavailable,
+			ExcFunc: func(ctx context.Context) any {
+				return deposit(true, timeout, false, nil)
+			},
+		},
+		{
+			Name:    "Earlier Attempt Might Have Been Sent",
+			ExpResp: ErrNotSent,
+			ExcFunc: func(ctx context.Context) any {
+				return deposit(true, notSent, true, nil)
+			},
+		},
+		{
+			Name:    "Records Every Gateway Before Sending",
+			ExpResp: []models.PaymentGateway{"a", "b"},
+			ExcFunc: func(ctx context.Context) any {
+				var sent []models.PaymentGateway
+				deposit(true, notSent, false, &sent)
+				return sent
+			},
+		},
+		{
+			Name:    "Not Sent When Recording Fails",
+			ExpResp: errRecord,
+			ExcFunc: func(ctx context.Context) any {
+				payment := New(map[models.PaymentGateway]PaymentGateway{
+					"a": &mockGateway{capabilities: caps, depositFunc: func(ctx context.Context, req *Request) (*Response, error) {
+						panic("request sent")
+					}},
+				})
+				_, err := payment.Deposit(context.Background(), "a", &Request{
+					BeforeSend: func(ctx context.Context, gateway models.PaymentGateway) error {
+						return errRecord
+					},
+				})
+				return err
+			},
+		},
+	}
+
+	for i := range tests {
+		tests[i].CmpFunc = func(got any, exp any) string {
+			if expErr, ok := exp.(error); ok {
+				gotErr, ok := got.(error)
+				if !ok || !errors.Is(gotErr, expErr) {
+					return fmt.Sprintf("expected error %v, got %v", exp, got)
+				}
+				return ""
+			}
+
+			if expSent, ok := exp.([]models.PaymentGateway); ok {
+				if !reflect.DeepEqual(got, expSent) {
+					return fmt.Sprintf("expected gateways %v, got %v", expSent, got)
+				}
+				return ""
+			}
+
+			gotRes, ok := got.(*Response)
+			if !ok {
+				return fmt.Sprintf("expected *Response, got %v", got)
+			}
+			expRes := exp.(*Response)
+			if gotRes.ID != expRes.ID || gotRes.Gateway != expRes.Gateway {
+				return fmt.Sprintf("expected %v, got %v", expRes, gotRes)
+			}
+			if len(gotRes.Failovers) != len(expRes.Failovers) || gotRes.Failovers[0].Gateway != expRes.Failovers[0].Gateway {
+				return fmt.Sprintf("expected failovers %v, got %v", expRes.Failovers, gotRes.Failovers)
+			}
+			return ""
+		}
+	}
+
+	return tests
+}
@@ -0,0 +1,141 @@
+package payment
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+)
+
+// RouterConfig holds the settings of the gateway routing.
+type RouterConfig struct {
+	// Weights scale the score of the gateways, e.g. to prefer a cheaper gateway. Gateways
+	// without a weight have a weight of 1, and a weight of 0 excludes a gateway from routing.
+	Weights map[string]int
+}
+
+// Router chooses the gateway of a transaction whose client did not choose one.
+type Router struct {
+	payment *Payment
+	weights map[string]int
+}
+
+// NewRouter creates a Router choosing among the gateways registered in the payment.
+func NewRouter(payment *Payment, cfg RouterConfig) *Router {
+	return &Router{
+		payment: payment,
+		weights: cfg.Weights,
+	}
+}
+
+// RouteRequest describes the transaction to route.
+type RouteRequest struct {
+	Type     models.TransactionType
+	Method   models.PaymentMethod
+	Amount   money.Money
+	Currency money.Currency
+}
+
+// Route is the gateway chosen for a transaction, with the reason it was chosen.
+type Route struct {
+	Gateway models.PaymentGateway
+	Reason  string
+}
+
+// candidate is a gateway eligible for a transaction.
+type candidate struct {
+	name        models.PaymentGateway
+	weight      int
+	successRate float64
+	requests    int
+}
+
+func (c candidate) score() float64 {
+	return float64(c.weight) * c.successRate
+}
+
+// Route chooses the gateway of a transaction. The gateways whose capabilities support the
+// transaction and whose circuit breaker is not open are eligible, and the one with the
+// highest score, its weight times its recent success rate, is chosen. Ties go to the
+// gateway whose name sorts first, so the routing is deterministic.
+func (r *Router) Route(req RouteRequest) (*Route, error) {
+	return r.route(req, nil)
+}
+
+// route chooses the gateway of a transaction among the gateways not excluded.
+func (r *Router) route(req RouteRequest, exclude []models.PaymentGateway) (*Route, error) {
+	candidates, skipped := r.candidates(req, exclude)
+	if len(candidates) == 0 {
+		return nil, errs.Newf(errs.InvalidArgument, "no payment gateway supports the transaction: %s", strings.Join(skipped, "; "))
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score() > candidates[j].score()
+	})
+
+	best := candidates[0]
+	reason := fmt.Sprintf("routed to the highest score %.2f (weight %d, success rate %.0f%% over %d requests)",
+		best.score(), best.weight, best.successRate*100, best.requests)
+	for _, c := range candidates[1:] {
+		skipped = append(skipped, fmt.Sprintf("%s scored %.2f", c.name, c.score()))
+	}
+	if len(skipped) > 0 {
+		reason += ", " + strings.Join(skipped, ", ")
+	}
+
+	return &Route{Gateway: best.name, Reason: reason}, nil
+}
+
+// candidates returns the gateways eligible for the transaction sorted by name, and why
+// the other gateways are not.
+func (r *Router) candidates(req RouteRequest, exclude []models.PaymentGateway) ([]candidate, []string) {
+	names := make([]models.PaymentGateway, 0, len(r.payment.gateways))
+	for name := range r.payment.gateways {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+
+	var (
+		candidates []candidate
+		skipped    []string
+	)
+	for _, name := range names {
+		gateway := r.payment.gateways[name]
+
+		weight, ok := r.weights[string(name)]
+		if !ok {
+			weight = 1
+		}
+
+		caps := gateway.Capabilities()
+		switch {
+		case slices.Contains(exclude, name):
+			skipped = append(skipped, fmt.Sprintf("%s is unavailable", name))
+		case weight <= 0:
+			skipped = append(skipped, fmt.Sprintf("%s is disabled", name))
+		case caps.VerifyMethod(req.Type, req.Method) != nil:
+			skipped = append(skipped, fmt.Sprintf("%s does not support %s %s", name, req.Method, req.Type))
+		case caps.VerifyCurrency(req.Currency) != nil:
+			skipped = append(skipped, fmt.Sprintf("%s does not support %s", name, req.Currency))
+		case caps.VerifyAmount(req.Amount, req.Currency) != nil:
+			skipped = append(skipped, fmt.Sprintf("%s does not accept %s %s", name, req.Amount, req.Currency))
+		case gateway.BreakerState() == BreakerStateOpen:
+			skipped = append(skipped, fmt.Sprintf("%s circuit breaker is open", name))
+		default:
+			rate, requests := r.payment.stats.successRate(name)
+			candidates = append(candidates, candidate{
+				name:        name,
+				weight:      weight,
+				successRate: rate,
+				requests:    requests,
+			})
+		}
+	}
+	return candidates, skipped
+}
@@ -0,0 +1,67 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+)
+
+// Signature signs and verifies gateway callbacks. A callback is signed with the
+// HMAC-SHA256 of "<timestamp>.<body>" using the secret shared with the gateway,
+// where the timestamp is in unix seconds and the signature is hex encoded.
+type Signature struct {
+	secret    []byte
+	tolerance time.Duration
+}
+
+// NewSignature creates a Signature accepting callbacks signed at most tolerance ago.
+func NewSignature(secret string, tolerance time.Duration) *Signature {
+	return &Signature{
+		secret:    []byte(secret),
+		tolerance: tolerance,
+	}
+}
+
+// Sign returns the signature of the body sent at the given timestamp.
+func (s *Signature) Sign(timestamp string, body []byte) string {
+	return hex.EncodeToString(s.sign(timestamp, body))
+}
+
+// Verify checks the signature of the body sent at the given timestamp and received at
+// receivedAt. The signature is compared in constant time, and timestamps outside the
+// tolerance are rejected so a captured callback cannot be replayed later.
+func (s *Signature) Verify(timestamp, signature string, body []byte, receivedAt time.Time) error {
+	if timestamp == "" || signature == "" {
+		return errs.New(errs.Unauthenticated, errors.New("missing callback signature"))
+	}
+
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errs.New(errs.Unauthenticated, errors.New("invalid callback signature timestamp"))
+	}
+
+	if age := receivedAt.Sub(time.Unix(sec, 0)); math.Abs(float64(age)) > float64(s.tolerance) {
+		return errs.New(errs.Unauthenticated, errors.New("callback signature timestamp is outside the tolerance"))
+	}
+
+	got, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(got, s.sign(timestamp, body)) {
+		return errs.New(errs.Unauthenticated, errors.New("invalid callback signature"))
+	}
+
+	return nil
+}
+
+func (s *Signature) sign(timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
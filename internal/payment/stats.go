@@ -0,0 +1,69 @@
+package payment
+
+import (
+	"sync"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+)
+
+// statsWindow is the number of latest requests the success rate of a gateway is computed over.
+const statsWindow = 100
+
+// gatewayStats keeps the outcome of the latest requests sent to each gateway. It is kept
+// in memory, so every replica routes on the requests it sent itself.
+type gatewayStats struct {
+	mu       sync.Mutex
+	outcomes map[models.PaymentGateway]*outcomes
+}
+
+// outcomes is a ring buffer of request outcomes, true for a request the gateway handled.
+type outcomes struct {
+	results [statsWindow]bool
+	next    int
+	count   int
+}
+
+func newGatewayStats(gateways map[models.PaymentGateway]PaymentGateway) *gatewayStats {
+	s := &gatewayStats{outcomes: make(map[models.PaymentGateway]*outcomes, len(gateways))}
+	for name := range gateways {
+		s.outcomes[name] = &outcomes{}
+	}
+	return s
+}
+
+// record stores the outcome of a request sent to the gateway.
+func (s *gatewayStats) record(gateway models.PaymentGateway, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, found := s.outcomes[gateway]
+	if !found {
+		return
+	}
+	o.results[o.next] = ok
+	o.next = (o.next + 1) % statsWindow
+	if o.count < statsWindow {
+		o.count++
+	}
+}
+
+// successRate returns the share of the latest requests the gateway handled and the number
+// of requests it is computed over. The rate is smoothed, so a gateway without requests
+// yet has a rate of 0.5 and a few failures do not exclude a gateway for good.
+func (s *gatewayStats) successRate(gateway models.PaymentGateway) (float64, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, found := s.outcomes[gateway]
+	if !found {
+		return 0, 0
+	}
+
+	var ok int
+	for i := 0; i < o.count; i++ {
+		if o.results[i] {
+			ok++
+		}
+	}
+	return float64(ok+1) / float64(o.count+2), o.count
+}
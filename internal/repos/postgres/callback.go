@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/database"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CallbackRepo stores the callbacks received from payment gateways.
+type CallbackRepo struct {
+	db database.IDatabase
+}
+
+// NewCallbackRepo creates a new instance of callbackRepo.
+func NewCallbackRepo(db database.IDatabase) *CallbackRepo {
+	return &CallbackRepo{db: db}
+}
+
+// Create creates a new callback record in the database.
+func (r *CallbackRepo) Create(ctx context.Context, callback *models.GatewayCallback) error {
+	return r.db.WithContext(ctx).Create(callback).Error
+}
+
+// Update updates a callback record in the database.
+func (r *CallbackRepo) Update(ctx context.Context, callback *models.GatewayCallback) error {
+	return r.db.WithContext(ctx).Save(callback).Error
+}
+
+// GetByID retrieves a callback record by its ID.
+func (r *CallbackRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.GatewayCallback, error) {
+	var callback models.GatewayCallback
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&callback).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.New(errs.NotFound, fmt.Errorf("callback with ID %s not found", id))
+		}
+		return nil, err
+	}
+	return &callback, nil
+}
+
+// List retrieves the latest callbacks, newest first, optionally filtered by status.
+func (r *CallbackRepo) List(ctx context.Context, status models.CallbackStatus, limit int) ([]models.GatewayCallback, error) {
+	query := r.db.WithContext(ctx).Order("created_at DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var callbacks []models.GatewayCallback
+	if err := query.Find(&callbacks).Error; err != nil {
+		return nil, err
+	}
+	return callbacks, nil
+}
@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/database"
+	"github.com/google/uuid"
+)
+
+// TransactionHistoryRepo stores the status transitions of transactions.
+type TransactionHistoryRepo struct {
+	db database.IDatabase
+}
+
+// NewTransactionHistoryRepo creates a new instance of transactionHistoryRepo.
+func NewTransactionHistoryRepo(db database.IDatabase) *TransactionHistoryRepo {
+	return &TransactionHistoryRepo{db: db}
+}
+
+// Create creates a new status history record in the database.
+func (r *TransactionHistoryRepo) Create(ctx context.Context, history *models.TransactionStatusHistory) error {
+	return r.db.WithContext(ctx).Create(history).Error
+}
+
+// GetByTransactionID retrieves the status history of a transaction, oldest first.
+func (r *TransactionHistoryRepo) GetByTransactionID(ctx context.Context, tranID uuid.UUID) ([]models.TransactionStatusHistory, error) {
+	var history []models.TransactionStatusHistory
+	err := r.db.WithContext(ctx).Where("transaction_id = ?", tranID).Order("created_at ASC").Find(&history).Error
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
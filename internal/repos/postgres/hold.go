@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/database"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// HoldRepo stores the funds reserved for in-flight transactions.
+type HoldRepo struct {
+	db database.IDatabase
+}
+
+// NewHoldRepo creates a new instance of holdRepo.
+func NewHoldRepo(db database.IDatabase) *HoldRepo {
+	return &HoldRepo{db: db}
+}
+
+// Create creates a new hold record in the database.
+func (r *HoldRepo) Create(ctx context.Context, hold *models.Hold) error {
+	return r.db.WithContext(ctx).Create(hold).Error
+}
+
+// GetByTransactionID retrieves the hold placed for a transaction.
+func (r *HoldRepo) GetByTransactionID(ctx context.Context, tranID uuid.UUID) (*models.Hold, error) {
+	var hold models.Hold
+	err := r.db.WithContext(ctx).Where("transaction_id = ?", tranID).First(&hold).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.New(errs.NotFound, fmt.Errorf("hold for transaction %s not found", tranID))
+		}
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// Update updates the hold record in the database.
+func (r *HoldRepo) Update(ctx context.Context, hold *models.Hold) error {
+	return r.db.WithContext(ctx).Save(hold).Error
+}
+
+// ActiveTotal returns the sum of the active holds of the given wallet.
+func (r *HoldRepo) ActiveTotal(ctx context.Context, walletID uuid.UUID) (money.Money, error) {
+	var total money.Money
+	err := r.db.WithContext(ctx).Model(&models.Hold{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("wallet_id = ? AND status = ?", walletID, models.HoldStatusActive).
+		Scan(&total).Error
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
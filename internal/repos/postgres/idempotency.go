@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/database"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyRepo stores the idempotency keys of requests, each kept for ttl.
+type IdempotencyRepo struct {
+	db  database.IDatabase
+	ttl time.Duration
+}
+
+// NewIdempotencyRepo creates a new instance of idempotencyRepo.
+func NewIdempotencyRepo(db database.IDatabase, ttl time.Duration) *IdempotencyRepo {
+	return &IdempotencyRepo{db: db, ttl: ttl}
+}
+
+// Get retrieves an unexpired idempotency key of a wallet operation.
+func (r *IdempotencyRepo) Get(ctx context.Context, walletID uuid.UUID, op models.IdempotentOperation, key string) (*models.IdempotencyKey, error) {
+	var ik models.IdempotencyKey
+	err := r.db.WithContext(ctx).
+		Where("wallet_id = ? AND operation = ? AND key = ? AND expires_at > NOW()", walletID, op, key).
+		First(&ik).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.New(errs.NotFound, fmt.Errorf("idempotency key %s not found", key))
+		}
+		return nil, err
+	}
+	return &ik, nil
+}
+
+// Create stores an idempotency key, replacing an expired one. It reports false
+// when an unexpired key already exists.
+func (r *IdempotencyRepo) Create(ctx context.Context, ik *models.IdempotencyKey) (bool, error) {
+	ik.CreatedAt = time.Now()
+	ik.ExpiresAt = ik.CreatedAt.Add(r.ttl)
+
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "wallet_id"}, {Name: "operation"}, {Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"request_hash", "response", "transaction_id", "created_at", "expires_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "idempotency_keys.expires_at <= NOW()"}}},
+	}).Create(ik)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// DeleteExpired deletes the expired idempotency keys.
+func (r *IdempotencyRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	res := r.db.WithContext(ctx).Where("expires_at <= NOW()").Delete(&models.IdempotencyKey{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/database"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/google/uuid"
+)
+
+// LedgerRepo stores double-entry ledger entries and their postings.
+type LedgerRepo struct {
+	db database.IDatabase
+}
+
+// NewLedgerRepo creates a new instance of ledgerRepo.
+func NewLedgerRepo(db database.IDatabase) *LedgerRepo {
+	return &LedgerRepo{db: db}
+}
+
+// Post records a balanced ledger entry together with its postings.
+func (r *LedgerRepo) Post(ctx context.Context, entry *models.LedgerEntry) error {
+	if !entry.IsBalanced() {
+		return errs.New(errs.Internal, errors.New("ledger entry is not balanced"))
+	}
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+// Balance returns the posted balance of the given wallet.
+func (r *LedgerRepo) Balance(ctx context.Context, walletID uuid.UUID) (money.Money, error) {
+	var balance money.Money
+	err := r.db.WithContext(ctx).Model(&models.LedgerPosting{}).
+		Select("COALESCE(SUM(CASE WHEN direction = ? THEN amount ELSE -amount END), 0)", models.PostingDirectionCredit).
+		Where("wallet_id = ?", walletID).
+		Scan(&balance).Error
+	if err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
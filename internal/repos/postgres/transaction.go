@@ -4,12 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/3bd-dev/wallet-service/internal/models"
 	"github.com/3bd-dev/wallet-service/pkg/database"
 	"github.com/3bd-dev/wallet-service/pkg/errs"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TransactionRepo struct {
@@ -50,8 +52,68 @@ func (r *TransactionRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Tr
 	return &transaction, err
 }
 
+// GetByReferenceID retrieves the transaction a payment gateway knows by the given reference ID.
+func (r *TransactionRepo) GetByReferenceID(ctx context.Context, gateway models.PaymentGateway, refID string) (*models.Transaction, error) {
+	var transaction models.Transaction
+	err := r.db.WithContext(ctx).Where("payment_gateway = ? AND reference_id = ?", gateway, refID).First(&transaction).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.New(errs.NotFound, fmt.Errorf("transaction with reference ID %s not found", refID))
+		}
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// Update updates a transaction record in the database if it was not updated since it
+// was read, and bumps its version. It fails with errs.Aborted otherwise.
 func (r *TransactionRepo) Update(ctx context.Context, transaction *models.Transaction) error {
-	return r.db.WithContext(ctx).Save(transaction).Error
+	version := transaction.Version
+	transaction.Version++
+
+	res := r.db.WithContext(ctx).Model(transaction).
+		Where("version = ?", version).
+		Select("*").Omit("id", "created_at", clause.Associations).
+		Updates(transaction)
+	if res.Error != nil || res.RowsAffected == 0 {
+		transaction.Version = version
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errs.New(errs.Aborted, fmt.Errorf("transaction with ID %s was updated concurrently", transaction.ID))
+	}
+	return nil
+}
+
+// GetByRelatedTransactionID retrieves the transactions linked to the given transaction.
+func (r *TransactionRepo) GetByRelatedTransactionID(ctx context.Context, relatedID uuid.UUID) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	err := r.db.WithContext(ctx).Where("related_transaction_id = ?", relatedID).Find(&transactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// GetStale retrieves up to limit transactions that have been in the given status since before the given time, oldest first.
+func (r *TransactionRepo) GetStale(ctx context.Context, status models.TransactionStatus, before time.Time, limit int) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND updated_at < ?", status, before).
+		Order("updated_at ASC").
+		Limit(limit).
+		Find(&transactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// Touch sets the updated_at of a transaction to the current time.
+func (r *TransactionRepo) Touch(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&models.Transaction{}).Where("id = ?", id).Update("updated_at", time.Now()).Error
 }
 
 func (r *TransactionRepo) GetByWalletID(ctx context.Context, walletID uuid.UUID) ([]models.Transaction, error) {
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/3bd-dev/wallet-service/pkg/database"
+)
+
+// Transactor runs a unit of work inside a single database transaction.
+type Transactor struct {
+	db database.IDatabase
+}
+
+// NewTransactor creates a new instance of transactor.
+func NewTransactor(db database.IDatabase) *Transactor {
+	return &Transactor{db: db}
+}
+
+// WithTx runs fn inside a database transaction. The transaction is carried on the
+// context so every repository call made with it joins the same transaction.
+// Nested calls reuse the outer transaction. Callbacks registered with
+// database.AfterCommit run once the transaction commits.
+func (t *Transactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if ctx.Value(database.ContextKeyDBTx) != nil {
+		return fn(ctx)
+	}
+
+	tx := t.db.Begin()
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	ctx, afterCommit := database.WithAfterCommit(context.WithValue(ctx, database.ContextKeyDBTx, tx))
+	if err := fn(ctx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w: rollback failed: %v", err, rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	afterCommit()
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/3bd-dev/wallet-service/pkg/errs"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // WalletRepo defines the interface for wallet repository.
@@ -36,6 +37,21 @@ func (r *WalletRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Wallet,
 	return &wallet, err
 }
 
+// GetByIDForUpdate retrieves a wallet record by its ID and locks the row until
+// the surrounding database transaction ends.
+func (r *WalletRepo) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&wallet).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.New(errs.NotFound, fmt.Errorf("wallet with ID %s not found", id))
+		}
+		return nil, err
+	}
+
+	return &wallet, nil
+}
+
 // Create creates a new wallet record in the database.
 func (r *WalletRepo) Create(ctx context.Context, wallet *models.Wallet) error {
 	return r.db.WithContext(ctx).Create(wallet).Error
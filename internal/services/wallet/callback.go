@@ -0,0 +1,288 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/internal/payment"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/google/uuid"
+)
+
+// MaxCallbackBodySize caps the body of a gateway callback, in bytes.
+const MaxCallbackBodySize = 64 << 10
+
+// ProcessCallback verifies the signature of a callback sent to the legacy callback URL of
+// a transaction, stores it and processes it. The outcome is recorded on the stored callback.
+func (s *Service) ProcessCallback(ctx context.Context, walletID, tranID uuid.UUID, header http.Header, body []byte) error {
+	receivedAt := time.Now()
+
+	// the legacy URL does not name the gateway, so the callback is authenticated against
+	// every gateway before the transaction is looked up. An unsigned caller cannot tell
+	// which transactions exist.
+	signers, err := s.callbackSigners(ctx, header, body, receivedAt)
+	if err != nil {
+		return err
+	}
+
+	transaction, err := s.transactionRepo.GetByIDAndWalletID(ctx, tranID, walletID)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(signers, transaction.PaymentGateway) {
+		s.log.Warn(ctx, "Rejecting callback signed by another gateway", "transaction_id", transaction.ID, "gateway", transaction.PaymentGateway, "signers", signers)
+		return errs.Newf(errs.Unauthenticated, "callback is not signed by payment gateway %s", transaction.PaymentGateway)
+	}
+
+	callback, err := s.receiveCallback(ctx, &transaction.PaymentGateway, header, body, receivedAt)
+	if err != nil {
+		return err
+	}
+
+	callback.TransactionID = &transaction.ID
+	status, err := s.applyCallback(ctx, callback)
+	return s.respondCallback(ctx, callback, status, err)
+}
+
+// ProcessWebhook verifies the signature of a callback sent to the webhook of a payment
+// gateway, stores it and processes it. The transaction is matched by its gateway reference ID.
+func (s *Service) ProcessWebhook(ctx context.Context, gateway models.PaymentGateway, header http.Header, body []byte) error {
+	receivedAt := time.Now()
+
+	if err := s.paymentHandler.VerifyGateway(gateway); err != nil {
+		return err
+	}
+
+	if err := s.authenticateCallback(ctx, gateway, header, body, receivedAt); err != nil {
+		return err
+	}
+
+	callback, err := s.receiveCallback(ctx, &gateway, header, body, receivedAt)
+	if err != nil {
+		return err
+	}
+
+	status, err := s.applyCallback(ctx, callback)
+	return s.respondCallback(ctx, callback, status, err)
+}
+
+// authenticateCallback rejects a callback whose signature does not verify against its
+// gateway, before it is stored, so unsigned requests never reach the database. A signed
+// callback the gateway fails to decode is accepted here, and stored as failed.
+func (s *Service) authenticateCallback(ctx context.Context, gateway models.PaymentGateway, header http.Header, body []byte, receivedAt time.Time) error {
+	if err := s.verifyCallbackSignature(ctx, gateway, header, body, receivedAt); err != nil {
+		s.log.Warn(ctx, "Rejecting unsigned callback", "gateway", gateway, "error", err)
+		return err
+	}
+	return nil
+}
+
+// callbackSigners returns the gateways the signature of a callback verifies against,
+// and rejects a callback signed by none of them.
+func (s *Service) callbackSigners(ctx context.Context, header http.Header, body []byte, receivedAt time.Time) ([]models.PaymentGateway, error) {
+	var signers []models.PaymentGateway
+	for _, gateway := range s.paymentHandler.Gateways() {
+		if err := s.verifyCallbackSignature(ctx, gateway.Name, header, body, receivedAt); err == nil {
+			signers = append(signers, gateway.Name)
+		}
+	}
+
+	if len(signers) == 0 {
+		s.log.Warn(ctx, "Rejecting unsigned callback")
+		return nil, errs.Newf(errs.Unauthenticated, "callback is not signed by any payment gateway")
+	}
+	return signers, nil
+}
+
+// verifyCallbackSignature returns the Unauthenticated error of a callback whose signature
+// does not verify against the gateway.
+func (s *Service) verifyCallbackSignature(ctx context.Context, gateway models.PaymentGateway, header http.Header, body []byte, receivedAt time.Time) error {
+	_, err := s.paymentHandler.ParseCallback(ctx, gateway, &payment.Callback{
+		Header:     header,
+		Body:       body,
+		ReceivedAt: receivedAt,
+	})
+	if errs.HasCode(err, errs.Unauthenticated) {
+		return err
+	}
+	return nil
+}
+
+// respondCallback records the outcome of a callback and returns the error answered to the
+// gateway. Only failed callbacks are answered with an error, so the gateway stops retrying
+// a callback that was ignored or flagged for review. The outcome is only logged when it
+// cannot be stored, as the gateway must get the processing error rather than the storage one.
+func (s *Service) respondCallback(ctx context.Context, callback *models.GatewayCallback, status models.CallbackStatus, err error) error {
+	if storeErr := s.completeCallback(ctx, callback, status, err); storeErr != nil {
+		s.log.Error(ctx, "Failed to store callback outcome", "callback_id", callback.ID, "status", status, "error", storeErr)
+	}
+	if status == models.CallbackStatusFailed {
+		return err
+	}
+	return nil
+}
+
+// Callbacks lists the latest stored gateway callbacks, optionally filtered by status.
+func (s *Service) Callbacks(ctx context.Context, status models.CallbackStatus, limit int) ([]models.GatewayCallback, error) {
+	callbacks, err := s.callbackRepo.List(ctx, status, limit)
+	if err != nil {
+		return nil, errs.New(errs.Internal, err)
+	}
+	return callbacks, nil
+}
+
+// Callback retrieves a stored gateway callback by its ID.
+func (s *Service) Callback(ctx context.Context, id uuid.UUID) (*models.GatewayCallback, error) {
+	return s.callbackRepo.GetByID(ctx, id)
+}
+
+// ReprocessCallback processes a stored gateway callback again, e.g. after the failure
+// that made it fail was fixed. Its signature is still verified, against the time it
+// was received. The returned callback holds the outcome of the new attempt.
+func (s *Service) ReprocessCallback(ctx context.Context, id uuid.UUID) (*models.GatewayCallback, error) {
+	callback, err := s.callbackRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	status, procErr := s.applyCallback(ctx, callback)
+	if err := s.completeCallback(ctx, callback, status, procErr); err != nil {
+		return nil, errs.New(errs.Internal, err)
+	}
+	return callback, nil
+}
+
+// receiveCallback stores an authenticated callback as received, before it is processed.
+func (s *Service) receiveCallback(ctx context.Context, gateway *models.PaymentGateway, header http.Header, body []byte, receivedAt time.Time) (*models.GatewayCallback, error) {
+	headers, err := json.Marshal(header)
+	if err != nil {
+		return nil, errs.New(errs.Internal, fmt.Errorf("failed to marshal callback headers: %w", err))
+	}
+
+	callback := &models.GatewayCallback{
+		ID:        uuid.New(),
+		Gateway:   gateway,
+		Headers:   headers,
+		Body:      body,
+		Status:    models.CallbackStatusReceived,
+		CreatedAt: receivedAt,
+	}
+
+	if err := s.callbackRepo.Create(ctx, callback); err != nil {
+		return nil, errs.New(errs.Internal, fmt.Errorf("failed to store callback: %w", err))
+	}
+	return callback, nil
+}
+
+// applyCallback verifies a stored callback and applies the status it reports to its
+// transaction. A callback without a transaction yet, received on a gateway webhook, is
+// matched to the transaction by the reference ID it reports. It returns the status of
+// the callback, with the error explaining why it failed or needs review.
+func (s *Service) applyCallback(ctx context.Context, callback *models.GatewayCallback) (models.CallbackStatus, error) {
+	if callback.Gateway == nil {
+		return models.CallbackStatusFailed, errs.New(errs.InvalidArgument, errors.New("callback did not match a payment gateway"))
+	}
+
+	var header http.Header
+	if err := json.Unmarshal(callback.Headers, &header); err != nil {
+		return models.CallbackStatusFailed, errs.New(errs.Internal, fmt.Errorf("failed to unmarshal callback headers: %w", err))
+	}
+
+	cb := &payment.Callback{
+		Header:     header,
+		Body:       callback.Body,
+		ReceivedAt: callback.CreatedAt,
+	}
+
+	var (
+		tran *models.Transaction
+		res  *payment.Response
+		err  error
+	)
+	if callback.TransactionID != nil {
+		tran, err = s.transactionRepo.GetByID(ctx, *callback.TransactionID)
+		if err != nil {
+			return models.CallbackStatusFailed, err
+		}
+
+		var tranRefID string
+		if tran.ReferenceID != nil {
+			tranRefID = *tran.ReferenceID
+		}
+
+		res, err = s.paymentHandler.VerifyCallback(ctx, tran.PaymentGateway, tranRefID, cb)
+		if err != nil {
+			return models.CallbackStatusFailed, errs.NewError(err)
+		}
+	} else {
+		res, err = s.paymentHandler.ParseCallback(ctx, *callback.Gateway, cb)
+		if err != nil {
+			return models.CallbackStatusFailed, errs.NewError(err)
+		}
+
+		tran, err = s.transactionRepo.GetByReferenceID(ctx, *callback.Gateway, res.ID)
+		if err != nil {
+			return models.CallbackStatusFailed, err
+		}
+		callback.TransactionID = &tran.ID
+	}
+
+	// the worker or another callback can update the transaction concurrently, in which
+	// case the status is applied again to the reloaded transaction.
+	status := models.CallbackStatusFailed
+	err = s.retryOnConflict(ctx, tran, func(tran *models.Transaction) error {
+		status, err = s.applyCallbackStatus(ctx, tran, res)
+		return err
+	})
+	return status, err
+}
+
+// applyCallbackStatus applies the payment status reported by a callback to its transaction.
+// Callbacks are idempotent, as gateways retry them: a status the transaction already
+// reached, or a pending status arriving after the final one, is ignored. A final status
+// conflicting with the final status of the transaction is flagged for manual review.
+func (s *Service) applyCallbackStatus(ctx context.Context, tran *models.Transaction, res *payment.Response) (models.CallbackStatus, error) {
+	reported, err := toTransactionStatus(res.Status)
+	if err != nil {
+		return models.CallbackStatusFailed, err
+	}
+
+	switch {
+	case !tran.Status.IsFinal():
+		if err := s.applyPaymentStatus(ctx, tran, res, models.TransitionSourceCallback, "payment gateway reported"); err != nil {
+			return models.CallbackStatusFailed, err
+		}
+		return models.CallbackStatusProcessed, nil
+	case reported == models.TransactionStatusPending || tran.Status.HasReached(reported):
+		s.log.Info(ctx, "Ignoring callback already applied", "transaction_id", tran.ID, "status", tran.Status, "reported", res.Status)
+		return models.CallbackStatusIgnored, nil
+	default:
+		s.log.Error(ctx, "Callback conflicts with the transaction status, needs review", "transaction_id", tran.ID, "status", tran.Status, "reported", res.Status)
+		return models.CallbackStatusNeedsReview, fmt.Errorf("payment gateway reported %s but the transaction is %s", res.Status, tran.Status)
+	}
+}
+
+// completeCallback records the outcome of processing a callback.
+func (s *Service) completeCallback(ctx context.Context, callback *models.GatewayCallback, status models.CallbackStatus, procErr error) error {
+	now := time.Now()
+	callback.Attempts++
+	callback.ProcessedAt = &now
+	callback.Status = status
+	callback.Error = nil
+	if procErr != nil {
+		msg := procErr.Error()
+		callback.Error = &msg
+	}
+
+	if err := s.callbackRepo.Update(ctx, callback); err != nil {
+		return fmt.Errorf("failed to store callback outcome: %w", err)
+	}
+	return nil
+}
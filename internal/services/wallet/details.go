@@ -0,0 +1,144 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/internal/payment"
+	"github.com/google/uuid"
+)
+
+var (
+	// errDetailsUnavailable is returned for a deposit or withdrawal whose payment details were not kept.
+	errDetailsUnavailable = errors.New("payment details are no longer available to submit the transaction")
+	// errSecurityCodeUnavailable is returned for a card payment whose security code this replica does not hold.
+	errSecurityCodeUnavailable = errors.New("card security code is no longer available to submit the transaction")
+)
+
+// sealPaymentDetails encrypts the unmasked payment details of the transaction for the
+// queue worker. The security code of a card must never be stored, even encrypted, so
+// it is left out and only kept in memory until the transaction is sent.
+func (s *Service) sealPaymentDetails(tran *models.Transaction, details payment.PaymentMethodDetails) error {
+	raw := details.GetRaw()
+	if card, ok := details.(*payment.PaymentMethodCreditCardDetails); ok {
+		stored := *card
+		stored.CVV = ""
+		raw = stored.GetRaw()
+		s.securityCodes.put(tran.ID, card.CVV)
+	}
+
+	sealed, err := s.sealer.Seal(raw, tran.ID[:])
+	if err != nil {
+		s.securityCodes.delete(tran.ID)
+		return fmt.Errorf("failed to seal payment details: %w", err)
+	}
+
+	tran.SealedPaymentDetails = sealed
+	return nil
+}
+
+// openPaymentDetails decrypts the payment details of the transaction, with the security
+// code of a card put back. Refunds have no payment details of their own.
+func (s *Service) openPaymentDetails(tran *models.Transaction) (json.RawMessage, error) {
+	if tran.Type == models.TransactionTypeRefund {
+		return nil, nil
+	}
+
+	if len(tran.SealedPaymentDetails) == 0 {
+		return nil, errDetailsUnavailable
+	}
+
+	raw, err := s.sealer.Open(tran.SealedPaymentDetails, tran.ID[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to open payment details: %w", err)
+	}
+
+	if tran.PaymentMethod != models.PaymentMethodCreditCard {
+		return raw, nil
+	}
+
+	var card payment.PaymentMethodCreditCardDetails
+	if err := json.Unmarshal(raw, &card); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal credit card details: %w", err)
+	}
+
+	code, ok := s.securityCodes.get(tran.ID)
+	if !ok {
+		return nil, errSecurityCodeUnavailable
+	}
+	card.CVV = code
+	return card.GetRaw(), nil
+}
+
+// securityCodes holds the card security codes of the transactions accepted by this
+// replica until they are sent to their gateway, or expire. The queue keeps their jobs on
+// this replica meanwhile, a transaction processed after a restart has no security code.
+type securityCodes struct {
+	ttl   time.Duration
+	mu    sync.Mutex
+	codes map[uuid.UUID]securityCode
+}
+
+type securityCode struct {
+	code      string
+	expiresAt time.Time
+}
+
+func newSecurityCodes(ttl time.Duration) *securityCodes {
+	return &securityCodes{
+		ttl:   ttl,
+		codes: make(map[uuid.UUID]securityCode),
+	}
+}
+
+// put keeps the security code of the transaction for the TTL, and drops the expired ones.
+func (c *securityCodes) put(id uuid.UUID, code string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for id, sc := range c.codes {
+		if now.After(sc.expiresAt) {
+			delete(c.codes, id)
+		}
+	}
+	c.codes[id] = securityCode{code: code, expiresAt: now.Add(c.ttl)}
+}
+
+// get returns the security code of the transaction unless it expired.
+func (c *securityCodes) get(id uuid.UUID) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sc, ok := c.codes[id]
+	if !ok || time.Now().After(sc.expiresAt) {
+		return "", false
+	}
+	return sc.code, true
+}
+
+// expiresIn returns how long the security code of the transaction is still held.
+func (c *securityCodes) expiresIn(id uuid.UUID) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sc, ok := c.codes[id]
+	if !ok {
+		return 0, false
+	}
+
+	ttl := time.Until(sc.expiresAt)
+	return ttl, ttl > 0
+}
+
+// delete forgets the security code of a transaction that no longer needs it.
+func (c *securityCodes) delete(id uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.codes, id)
+}
@@ -0,0 +1,77 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/google/uuid"
+)
+
+// placeHold reserves the transaction amount against the wallet's available balance.
+// It must run inside a database transaction, the wallet row is locked so concurrent
+// withdrawals cannot reserve the same funds twice.
+func (s *Service) placeHold(ctx context.Context, tran *models.Transaction) error {
+	wallet, err := s.walletRepo.GetByIDForUpdate(ctx, tran.WalletID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.ensureAvailable(ctx, wallet, tran.Amount); err != nil {
+		return err
+	}
+
+	return s.holdRepo.Create(ctx, &models.Hold{
+		ID:            uuid.New(),
+		WalletID:      tran.WalletID,
+		TransactionID: tran.ID,
+		Amount:        tran.Amount,
+		Status:        models.HoldStatusActive,
+	})
+}
+
+// holdsFunds reports whether funds are held while the transaction is in flight.
+func holdsFunds(tran *models.Transaction) bool {
+	return tran.Type == models.TransactionTypeWithdrawal || tran.Type == models.TransactionTypeRefund
+}
+
+// ensureAvailable checks the wallet's available balance covers the amount.
+func (s *Service) ensureAvailable(ctx context.Context, wallet *models.Wallet, amount money.Money) error {
+	balance, err := s.balance(ctx, wallet)
+	if err != nil {
+		return err
+	}
+
+	if balance.Available < amount {
+		return errs.New(errs.InsufficientFunds, errors.New("insufficient funds"))
+	}
+	return nil
+}
+
+// captureHold marks the hold of a transaction as debited from the wallet.
+func (s *Service) captureHold(ctx context.Context, tranID uuid.UUID) error {
+	return s.settleHold(ctx, tranID, models.HoldStatusCaptured)
+}
+
+// releaseHold returns the held funds of a transaction to the available balance.
+func (s *Service) releaseHold(ctx context.Context, tranID uuid.UUID) error {
+	return s.settleHold(ctx, tranID, models.HoldStatusReleased)
+}
+
+// settleHold moves an active hold to its final status.
+func (s *Service) settleHold(ctx context.Context, tranID uuid.UUID, status models.HoldStatus) error {
+	hold, err := s.holdRepo.GetByTransactionID(ctx, tranID)
+	if err != nil {
+		return err
+	}
+
+	if hold.Status != models.HoldStatusActive {
+		return fmt.Errorf("hold %s is already %s", hold.ID, hold.Status)
+	}
+
+	hold.Status = status
+	return s.holdRepo.Update(ctx, hold)
+}
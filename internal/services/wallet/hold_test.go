@@ -0,0 +1,156 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/3bd-dev/wallet-service/internal/dto/request"
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/3bd-dev/wallet-service/pkg/unitest"
+	"github.com/google/uuid"
+)
+
+func Test_Holds(t *testing.T) {
+	t.Parallel()
+
+	unitest.Run(t, holds(), "holds")
+}
+
+func holds() []unitest.Table {
+	// withdraw withdraws the amount from the wallet to a bank account.
+	withdraw := func(s *Service, walletID uuid.UUID, amount string) (*models.Transaction, error) {
+		return s.Withdraw(context.Background(), walletID, request.Withdraw{
+			Amount:   money.MustParse(amount),
+			Currency: money.USD,
+			Payment: request.Payment{
+				Gateway:       models.PaymentGatewayA,
+				Method:        models.PaymentMethodBankTransfer,
+				MethodDetails: json.RawMessage(`{"account_number": "1234567890", "bank_code": "BOFAUS3NXXX", "bank_code_type": "SWIFT"}`),
+			},
+		})
+	}
+
+	// holdOf returns the status of the hold of a transaction.
+	holdOf := func(f *fakes, tranID uuid.UUID) string {
+		hold, err := f.holds.GetByTransactionID(context.Background(), tranID)
+		if err != nil {
+			return err.Error()
+		}
+		return string(hold.Status)
+	}
+
+	cmp := func(got any, exp any) string {
+		if got != exp {
+			return fmt.Sprintf("expected %v, got %v", exp, got)
+		}
+		return ""
+	}
+
+	return []unitest.Table{
+		{
+			Name:    "Withdrawal Holds Funds",
+			ExpResp: "100.00/70.00 active",
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newTestService()
+				walletID := f.newWallet(s, "100")
+
+				tran, err := withdraw(s, walletID, "30")
+				if err != nil {
+					return err.Error()
+				}
+				return balanceOf(s, walletID) + " " + holdOf(f, tran.ID)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Available Balance Subtracts Active Holds Only",
+			ExpResp: "100.00/60.00",
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newTestService()
+				walletID := f.newWallet(s, "100")
+
+				for _, hold := range []models.Hold{
+					{Amount: money.MustParse("25"), Status: models.HoldStatusActive},
+					{Amount: money.MustParse("15"), Status: models.HoldStatusActive},
+					{Amount: money.MustParse("10"), Status: models.HoldStatusReleased},
+					{Amount: money.MustParse("20"), Status: models.HoldStatusCaptured},
+				} {
+					hold.ID, hold.TransactionID, hold.WalletID = uuid.New(), uuid.New(), walletID
+					f.holds.Create(ctx, &hold)
+				}
+				// holds of another wallet do not count.
+				f.holds.Create(ctx, &models.Hold{ID: uuid.New(), TransactionID: uuid.New(), WalletID: uuid.New(), Amount: money.MustParse("50"), Status: models.HoldStatusActive})
+
+				return balanceOf(s, walletID)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Insufficient Funds",
+			ExpResp: true,
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newTestService()
+				walletID := f.newWallet(s, "100")
+
+				_, err := withdraw(s, walletID, "100.01")
+				return errs.HasCode(err, errs.InsufficientFunds)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Held Funds Not Available",
+			ExpResp: "true 100.00/40.00",
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newTestService()
+				walletID := f.newWallet(s, "100")
+
+				if _, err := withdraw(s, walletID, "60"); err != nil {
+					return err.Error()
+				}
+				_, err := withdraw(s, walletID, "50")
+				return fmt.Sprint(errs.HasCode(err, errs.InsufficientFunds), " ", balanceOf(s, walletID))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Completed Withdrawal Captures Hold",
+			ExpResp: "70.00/70.00 captured",
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newTestService()
+				walletID := f.newWallet(s, "100")
+
+				tran, err := withdraw(s, walletID, "30")
+				if err != nil {
+					return err.Error()
+				}
+				if err := complete(s, tran.ID, models.TransactionStatusCompleted); err != nil {
+					return err.Error()
+				}
+				return balanceOf(s, walletID) + " " + holdOf(f, tran.ID)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Failed Withdrawal Releases Hold",
+			ExpResp: "100.00/100.00 released",
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newTestService()
+				walletID := f.newWallet(s, "100")
+
+				tran, err := withdraw(s, walletID, "30")
+				if err != nil {
+					return err.Error()
+				}
+				if err := complete(s, tran.ID, models.TransactionStatusFailed); err != nil {
+					return err.Error()
+				}
+				return balanceOf(s, walletID) + " " + holdOf(f, tran.ID)
+			},
+			CmpFunc: cmp,
+		},
+	}
+}
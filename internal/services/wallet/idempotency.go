@@ -0,0 +1,113 @@
+package wallet
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/google/uuid"
+)
+
+// errIdempotencyKeyTaken rolls back a request whose idempotency key was stored by a concurrent request.
+var errIdempotencyKeyTaken = errors.New("idempotency key taken")
+
+// idempotent runs create at most once per idempotency key of a wallet operation. The
+// key is stored with a hash of the request and the created transaction, in the database
+// transaction of create. A retry with the same key and request returns the original
+// transaction, while a different request with the same key is rejected with a conflict.
+// Without a key, create simply runs.
+func (s *Service) idempotent(ctx context.Context, walletID uuid.UUID, op models.IdempotentOperation, key string, req any, create func(ctx context.Context) (*models.Transaction, error)) (*models.Transaction, error) {
+	if key == "" {
+		return create(ctx)
+	}
+
+	hash, err := requestHash(s.hashKey, req)
+	if err != nil {
+		return nil, errs.New(errs.Internal, err)
+	}
+
+	tran, err := s.replay(ctx, walletID, op, key, hash)
+	if err != nil || tran != nil {
+		return tran, err
+	}
+
+	err = s.transactor.WithTx(ctx, func(ctx context.Context) error {
+		tran, err = create(ctx)
+		if err != nil {
+			return err
+		}
+
+		response, err := json.Marshal(tran)
+		if err != nil {
+			return errs.New(errs.Internal, err)
+		}
+
+		stored, err := s.idempotencyRepo.Create(ctx, &models.IdempotencyKey{
+			Key:           key,
+			WalletID:      walletID,
+			Operation:     op,
+			RequestHash:   hash,
+			Response:      response,
+			TransactionID: tran.ID,
+		})
+		if err != nil {
+			return errs.New(errs.Internal, err)
+		}
+
+		if !stored {
+			return errIdempotencyKeyTaken
+		}
+		return nil
+	})
+
+	// a concurrent request with the same key won, answer like a retry of it.
+	if errors.Is(err, errIdempotencyKeyTaken) {
+		tran, err = s.replay(ctx, walletID, op, key, hash)
+		if err == nil && tran == nil {
+			err = errs.Newf(errs.Conflict, "idempotency key %s is being used by another request", key)
+		}
+	}
+	return tran, err
+}
+
+// replay returns the transaction created by a previous request with the same idempotency
+// key, or nil when the key is unused.
+func (s *Service) replay(ctx context.Context, walletID uuid.UUID, op models.IdempotentOperation, key, hash string) (*models.Transaction, error) {
+	ik, err := s.idempotencyRepo.Get(ctx, walletID, op, key)
+	if err != nil {
+		if errs.HasCode(err, errs.NotFound) {
+			return nil, nil
+		}
+		return nil, errs.New(errs.Internal, err)
+	}
+
+	if ik.RequestHash != hash {
+		return nil, errs.Newf(errs.Conflict, "idempotency key %s was already used with a different request", key)
+	}
+
+	var tran models.Transaction
+	if err := json.Unmarshal(ik.Response, &tran); err != nil {
+		return nil, errs.New(errs.Internal, fmt.Errorf("failed to decode stored response: %w", err))
+	}
+	return &tran, nil
+}
+
+// requestHash returns the hex encoded HMAC-SHA256 of the JSON encoded request. The
+// request carries unmasked payment details, so a plain hash would let anyone reading
+// the database confirm a guessed card number or security code.
+func requestHash(key []byte, req any) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
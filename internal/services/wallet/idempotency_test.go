@@ -0,0 +1,279 @@
+package wallet
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/3bd-dev/wallet-service/internal/dto/request"
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/unitest"
+	"github.com/google/uuid"
+)
+
+// idempotencyRepo keeps the idempotency keys in memory.
+type idempotencyRepo struct {
+	mu   sync.Mutex
+	keys map[string]models.IdempotencyKey
+}
+
+func (r *idempotencyRepo) Get(ctx context.Context, walletID uuid.UUID, op models.IdempotentOperation, key string) (*models.IdempotencyKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ik, ok := r.keys[fmt.Sprint(walletID, op, key)]
+	if !ok {
+		return nil, errs.Newf(errs.NotFound, "idempotency key %s not found", key)
+	}
+	return &ik, nil
+}
+
+func (r *idempotencyRepo) Create(ctx context.Context, ik *models.IdempotencyKey) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := fmt.Sprint(ik.WalletID, ik.Operation, ik.Key)
+	if _, ok := r.keys[id]; ok {
+		return false, nil
+	}
+	r.keys[id] = *ik
+	return true, nil
+}
+
+func (r *idempotencyRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+// transactor runs the unit of work without a database, nothing is rolled back.
+type transactor struct{}
+
+func (transactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func Test_Idempotency(t *testing.T) {
+	t.Parallel()
+
+	unitest.Run(t, idempotent(), "idempotent")
+	unitest.Run(t, hashing(), "requestHash")
+}
+
+func idempotent() []unitest.Table {
+	type call struct {
+		key    string
+		amount string
+	}
+
+	type result struct {
+		// transactions is the number of distinct transactions returned.
+		transactions int
+		creates      int
+		conflicts    int
+		err          string
+	}
+
+	cmp := func(got any, exp any) string {
+		if got.(result) != exp.(result) {
+			return fmt.Sprintf("expected %+v, got %+v", exp, got)
+		}
+		return ""
+	}
+
+	walletID := uuid.New()
+
+	// run sends the calls to a fresh service, one after the other or all at once. The
+	// concurrent calls only create their transaction once all of them got past the
+	// lookup of their key, so they race to store it.
+	run := func(calls []call, concurrent bool) result {
+		s := &Service{
+			idempotencyRepo: &idempotencyRepo{keys: map[string]models.IdempotencyKey{}},
+			transactor:      transactor{},
+			hashKey:         []byte("test-key"),
+		}
+
+		var (
+			creates atomic.Int32
+			arrived sync.WaitGroup
+			mu      sync.Mutex
+			res     result
+			ids     = map[uuid.UUID]bool{}
+		)
+		if concurrent {
+			arrived.Add(len(calls))
+		}
+
+		send := func(c call) {
+			amount, _ := json.Marshal(c.amount)
+			req := request.Deposit{Currency: "USD", IdempotencyKey: c.key}
+			if err := json.Unmarshal(amount, &req.Amount); err != nil {
+				panic(err)
+			}
+
+			tran, err := s.idempotent(context.Background(), walletID, models.IdempotentOperationDeposit, c.key, req, func(ctx context.Context) (*models.Transaction, error) {
+				if concurrent {
+					arrived.Done()
+					waitTimeout(&arrived, time.Second)
+				}
+				creates.Add(1)
+				return &models.Transaction{ID: uuid.New(), WalletID: walletID, Amount: req.Amount}, nil
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errs.HasCode(err, errs.Conflict):
+				res.conflicts++
+			case err != nil:
+				res.err = err.Error()
+			default:
+				ids[tran.ID] = true
+			}
+		}
+
+		if !concurrent {
+			for _, c := range calls {
+				send(c)
+			}
+		} else {
+			var wg sync.WaitGroup
+			for _, c := range calls {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					send(c)
+				}()
+			}
+			wg.Wait()
+		}
+
+		res.transactions = len(ids)
+		res.creates = int(creates.Load())
+		return res
+	}
+
+	tests := []unitest.Table{}
+	for _, tc := range []struct {
+		name       string
+		calls      []call
+		concurrent bool
+		exp        result
+	}{
+		{
+			name:  "retry replays the original transaction",
+			calls: []call{{key: "k1", amount: "10.00"}, {key: "k1", amount: "10.00"}, {key: "k1", amount: "10"}},
+			exp:   result{transactions: 1, creates: 1},
+		},
+		{
+			name:  "same key with a different body conflicts",
+			calls: []call{{key: "k1", amount: "10.00"}, {key: "k1", amount: "11.00"}},
+			exp:   result{transactions: 1, creates: 1, conflicts: 1},
+		},
+		{
+			name:  "different keys create their own transaction",
+			calls: []call{{key: "k1", amount: "10.00"}, {key: "k2", amount: "10.00"}},
+			exp:   result{transactions: 2, creates: 2},
+		},
+		{
+			name:  "without a key every request creates",
+			calls: []call{{amount: "10.00"}, {amount: "10.00"}},
+			exp:   result{transactions: 2, creates: 2},
+		},
+		{
+			name:       "concurrent first use returns the winning transaction",
+			calls:      []call{{key: "k1", amount: "10.00"}, {key: "k1", amount: "10.00"}, {key: "k1", amount: "10.00"}},
+			concurrent: true,
+			exp:        result{transactions: 1, creates: 3},
+		},
+		{
+			name:       "concurrent first use with a different body conflicts",
+			calls:      []call{{key: "k1", amount: "10.00"}, {key: "k1", amount: "11.00"}},
+			concurrent: true,
+			exp:        result{transactions: 1, creates: 2, conflicts: 1},
+		},
+	} {
+		tests = append(tests, unitest.Table{
+			Name:    tc.name,
+			ExpResp: tc.exp,
+			ExcFunc: func(ctx context.Context) any {
+				return run(tc.calls, tc.concurrent)
+			},
+			CmpFunc: cmp,
+		})
+	}
+	return tests
+}
+
+func hashing() []unitest.Table {
+	cmp := func(got any, exp any) string {
+		if got != exp {
+			return fmt.Sprintf("expected %v, got %v", exp, got)
+		}
+		return ""
+	}
+
+	req := request.Deposit{
+		Currency: "USD",
+		Payment: request.Payment{
+			Method:        models.PaymentMethodCreditCard,
+			MethodDetails: json.RawMessage(`{"number":"4111111111111111","expiry":"12/30","cvv":"123"}`),
+		},
+	}
+
+	hash := func(key string) string {
+		h, err := requestHash([]byte(key), req)
+		if err != nil {
+			panic(err)
+		}
+		return h
+	}
+
+	return []unitest.Table{
+		{
+			Name:    "stable for a key",
+			ExpResp: true,
+			ExcFunc: func(ctx context.Context) any {
+				return hash("k1") == hash("k1")
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "differs between keys",
+			ExpResp: false,
+			ExcFunc: func(ctx context.Context) any {
+				return hash("k1") == hash("k2")
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "not the plain hash of the request",
+			ExpResp: false,
+			ExcFunc: func(ctx context.Context) any {
+				data, _ := json.Marshal(req)
+				sum := sha256.Sum256(data)
+				return hash("k1") == hex.EncodeToString(sum[:])
+			},
+			CmpFunc: cmp,
+		},
+	}
+}
+
+// waitTimeout waits for the wait group, or until the timeout elapses.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
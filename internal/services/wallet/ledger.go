@@ -0,0 +1,131 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/google/uuid"
+)
+
+// postTransaction posts the balanced ledger entry of a completed transaction.
+// Deposits move funds from the gateway clearing account into the wallet,
+// withdrawals and refunds move them back out.
+func (s *Service) postTransaction(ctx context.Context, tran *models.Transaction) error {
+	var walletDir, gatewayDir models.PostingDirection
+	switch tran.Type {
+	case models.TransactionTypeDeposit:
+		walletDir, gatewayDir = models.PostingDirectionCredit, models.PostingDirectionDebit
+	case models.TransactionTypeWithdrawal, models.TransactionTypeRefund:
+		walletDir, gatewayDir = models.PostingDirectionDebit, models.PostingDirectionCredit
+	default:
+		return fmt.Errorf("unsupported transaction type: %s", tran.Type)
+	}
+
+	entry := &models.LedgerEntry{
+		ID:            uuid.New(),
+		TransactionID: &tran.ID,
+		Description:   fmt.Sprintf("%s %s", tran.Type, tran.ID),
+	}
+	entry.Postings = []models.LedgerPosting{
+		{
+			ID:        uuid.New(),
+			EntryID:   entry.ID,
+			Account:   models.WalletAccount(tran.WalletID),
+			WalletID:  &tran.WalletID,
+			Direction: walletDir,
+			Amount:    tran.Amount,
+			Currency:  tran.Currency,
+		},
+		{
+			ID:        uuid.New(),
+			EntryID:   entry.ID,
+			Account:   models.GatewayAccount(tran.PaymentGateway, tran.Currency),
+			Direction: gatewayDir,
+			Amount:    tran.Amount,
+			Currency:  tran.Currency,
+		},
+	}
+
+	return s.ledgerRepo.Post(ctx, entry)
+}
+
+// postTransfer posts the ledger entry of a transfer, moving funds from the
+// wallet of the outgoing leg to the wallet of the incoming leg.
+func (s *Service) postTransfer(ctx context.Context, out, in *models.Transaction) error {
+	entry := &models.LedgerEntry{
+		ID:            uuid.New(),
+		TransactionID: &out.ID,
+		Description:   fmt.Sprintf("%s %s to wallet %s", out.Type, out.ID, in.WalletID),
+	}
+	entry.Postings = []models.LedgerPosting{
+		{
+			ID:        uuid.New(),
+			EntryID:   entry.ID,
+			Account:   models.WalletAccount(out.WalletID),
+			WalletID:  &out.WalletID,
+			Direction: models.PostingDirectionDebit,
+			Amount:    out.Amount,
+			Currency:  out.Currency,
+		},
+		{
+			ID:        uuid.New(),
+			EntryID:   entry.ID,
+			Account:   models.WalletAccount(in.WalletID),
+			WalletID:  &in.WalletID,
+			Direction: models.PostingDirectionCredit,
+			Amount:    in.Amount,
+			Currency:  in.Currency,
+		},
+	}
+
+	return s.ledgerRepo.Post(ctx, entry)
+}
+
+// settleTransaction applies the money movement of the status a transaction just moved to:
+// completed transactions are posted to the ledger and the hold of a withdrawal or refund
+// is captured on completion or released on failure. It runs inside the transition's
+// database transaction.
+func (s *Service) settleTransaction(ctx context.Context, tran *models.Transaction, source models.TransitionSource) error {
+	switch tran.Status {
+	case models.TransactionStatusCompleted:
+		if holdsFunds(tran) {
+			if err := s.captureHold(ctx, tran.ID); err != nil {
+				return err
+			}
+		}
+
+		if err := s.postTransaction(ctx, tran); err != nil {
+			return err
+		}
+
+		if tran.Type == models.TransactionTypeRefund {
+			return s.markRefunded(ctx, tran, source)
+		}
+	case models.TransactionStatusFailed:
+		if holdsFunds(tran) {
+			return s.releaseHold(ctx, tran.ID)
+		}
+	}
+	return nil
+}
+
+// balance returns the ledger and available balances of a wallet.
+// The available balance excludes the funds held for in-flight withdrawals.
+func (s *Service) balance(ctx context.Context, wallet *models.Wallet) (*models.Balance, error) {
+	ledger, err := s.ledgerRepo.Balance(ctx, wallet.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	held, err := s.holdRepo.ActiveTotal(ctx, wallet.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Balance{
+		Currency:  wallet.Currency,
+		Ledger:    ledger,
+		Available: ledger - held,
+	}, nil
+}
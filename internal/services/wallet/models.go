@@ -1,12 +1,58 @@
 package wallet
 
 import (
-	"encoding/json"
+	"time"
 
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/queue"
 	"github.com/google/uuid"
 )
 
+// QueueItem is a transaction queued for its payment gateway. The payment details are not
+// part of it, the worker reads them sealed from the transaction.
 type QueueItem struct {
-	ID             uuid.UUID       `json:"id"`
-	PaymentDetails json.RawMessage `json:"payment_details"`
+	ID      uuid.UUID             `json:"id"`
+	Gateway models.PaymentGateway `json:"gateway"`
+
+	// ownedFor is how long the replica enqueueing the item holds the security code of its card.
+	ownedFor time.Duration
+}
+
+// JobID keys the queue job by the transaction ID, so a transaction is queued at most once.
+func (i QueueItem) JobID() uuid.UUID {
+	return i.ID
+}
+
+// OwnedFor keeps the job of a card payment on the replica holding its security code,
+// until the code expires.
+func (i QueueItem) OwnedFor() time.Duration {
+	return i.ownedFor
+}
+
+// PartitionKey partitions the queue by payment gateway, so concurrency can be capped per gateway.
+func (i QueueItem) PartitionKey() string {
+	return string(i.Gateway)
+}
+
+// DeadLetter is a queued transaction whose processing failed for good.
+type DeadLetter struct {
+	ID            uuid.UUID             `json:"id"`
+	TransactionID uuid.UUID             `json:"transaction_id"`
+	Gateway       models.PaymentGateway `json:"gateway"`
+	Attempts      int                   `json:"attempts"`
+	LastError     string                `json:"last_error"`
+	EnqueuedAt    time.Time             `json:"enqueued_at"`
+	FailedAt      time.Time             `json:"failed_at"`
+}
+
+func toDeadLetter(l queue.DeadLetter[QueueItem]) DeadLetter {
+	return DeadLetter{
+		ID:            l.ID,
+		TransactionID: l.Item.ID,
+		Gateway:       l.Item.Gateway,
+		Attempts:      l.Attempts,
+		LastError:     l.LastError,
+		EnqueuedAt:    l.EnqueuedAt,
+		FailedAt:      l.FailedAt,
+	}
 }
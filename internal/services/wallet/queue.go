@@ -3,80 +3,320 @@ package wallet
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/3bd-dev/wallet-service/internal/models"
 	"github.com/3bd-dev/wallet-service/internal/payment"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/queue"
 	"github.com/google/uuid"
 )
 
-// processTransaction handles the processing of a single transaction, with retry logic and error handling.
-func (s *Service) processTransaction(ctx context.Context, item QueueItem) {
+// errDispatch is returned when the dispatch of a transaction could not be recorded, it
+// was not sent to the gateway and is retried.
+var errDispatch = errors.New("failed to record transaction dispatch")
+
+// processTransaction submits a queued transaction to its payment gateway. The returned
+// error makes the queue retry the transaction later, which happens when the gateway is
+// unavailable or the outcome could not be stored. Any other gateway error fails the transaction.
+func (s *Service) processTransaction(ctx context.Context, item QueueItem) error {
 	s.log.Debug(ctx, "Processing transaction", "transaction_id", item.ID)
-	var err error
-	var res *payment.Response
-	var tran *models.Transaction
 
-	defer func() {
-		if err != nil {
-			s.log.Error(ctx, "Failed to process transaction", "transaction_id", item.ID, "error", err)
-			if tran != nil {
-				tran.Status = models.TransactionStatusFailed
-			}
+	tran, err := s.transactionRepo.GetByID(ctx, item.ID)
+	if err != nil {
+		if errs.HasCode(err, errs.NotFound) {
+			return queue.Permanent(err)
 		}
+		return err
+	}
 
-		if tran.Status == models.TransactionStatusFailed {
-			if updateErr := s.transactionRepo.Update(ctx, tran); updateErr != nil {
-				s.log.Error(ctx, "Failed to update transaction status", "transaction_id", item.ID, "error", updateErr)
+	// a previous attempt already got an outcome for the transaction.
+	if tran.Status != models.TransactionStatusCreated {
+		s.log.Info(ctx, "Transaction already processed", "transaction_id", tran.ID, "status", tran.Status)
+		s.securityCodes.delete(tran.ID)
+		return nil
+	}
+
+	paymentDetails, err := s.openPaymentDetails(tran)
+	if errors.Is(err, errSecurityCodeUnavailable) && time.Since(tran.CreatedAt) < s.securityCodes.ttl {
+		// the replica that accepted the transaction owns its job and holds its security
+		// code until it expires, the job is retried until then.
+		s.log.Warn(ctx, "Card security code held by another replica, transaction will be retried", "transaction_id", tran.ID)
+		return err
+	}
+	if errors.Is(err, errDetailsUnavailable) || errors.Is(err, errSecurityCodeUnavailable) {
+		if tran.Dispatched {
+			return s.reconcileDispatched(ctx, tran, err)
+		}
+		s.log.Error(ctx, "Failed to process transaction", "transaction_id", tran.ID, "error", err)
+		return s.failTransaction(ctx, tran, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+
+	dispatched := tran.Dispatched
+	res, err := s.submitTransaction(ctx, tran, paymentDetails)
+	if err == nil && len(res.Failovers) > 0 {
+		s.log.Warn(ctx, "Transaction failed over to another payment gateway", "transaction_id", tran.ID, "from", res.Failovers[0].Gateway, "to", res.Gateway)
+	}
+	if errors.Is(err, errDispatch) {
+		s.log.Warn(ctx, "Failed to record transaction dispatch, transaction will be retried", "transaction_id", tran.ID, "error", err)
+		return err
+	}
+	if errors.Is(err, payment.ErrUnavailable) {
+		s.log.Warn(ctx, "Payment gateway unavailable, transaction will be retried", "transaction_id", tran.ID, "error", err)
+		if errors.Is(err, payment.ErrNotSent) && !dispatched {
+			// no attempt reached a gateway, so the next one can fail over again.
+			if err := s.recordDispatch(ctx, tran, tran.PaymentGateway, false); err != nil {
+				s.log.Error(ctx, "Failed to clear transaction dispatch", "transaction_id", tran.ID, "error", err)
 			}
 		}
-	}()
+		return err
+	}
+
+	// the gateway either got the transaction or rejected it, its security code is not needed anymore.
+	s.securityCodes.delete(tran.ID)
 
-	tran, err = s.transactionRepo.GetByID(ctx, item.ID)
 	if err != nil {
-		return
+		s.log.Error(ctx, "Failed to process transaction", "transaction_id", tran.ID, "error", err)
+		return s.failTransaction(ctx, tran, err.Error())
+	}
+
+	if err := s.acceptTransaction(ctx, tran, res, acceptedReason(res)); err != nil {
+		return err
 	}
 
+	s.log.Info(ctx, "Transaction processed successfully", "transaction_id", tran.ID)
+	return nil
+}
+
+// acceptTransaction records the payment the gateway created for a transaction, which
+// moves it to pending, or straight to the final status the payment already reached.
+func (s *Service) acceptTransaction(ctx context.Context, tran *models.Transaction, res *payment.Response, reason string) error {
+	return s.retryOnConflict(ctx, tran, func(tran *models.Transaction) error {
+		gateway := tran.PaymentGateway
+		tran.ReferenceID = &res.ID
+		if res.Gateway != "" {
+			tran.PaymentGateway = res.Gateway
+		}
+		if err := s.transition(ctx, tran, models.TransactionStatusPending, models.TransitionSourceWorker, reason); err != nil {
+			tran.ReferenceID, tran.PaymentGateway = nil, gateway
+			return err
+		}
+
+		// a retried request can get the original payment back from the gateway, which
+		// might have reached its final status already.
+		if res.Status != payment.PaymentStatusPending {
+			return s.applyPaymentStatus(ctx, tran, res, models.TransitionSourceWorker, "payment gateway reported")
+		}
+		return nil
+	})
+}
+
+// reconcileDispatched settles a dispatched transaction that cannot be sent again, as its
+// payment details are gone, e.g. the security code of a card after a restart. An earlier
+// attempt might have reached the gateway and moved the money, so the gateway is asked for
+// the payment of its merchant reference, and the transaction only fails when there is none.
+// A gateway that cannot look payments up leaves the transaction created, the reconciler
+// queues it again until it is reconciled by hand.
+func (s *Service) reconcileDispatched(ctx context.Context, tran *models.Transaction, cause error) error {
+	res, err := s.paymentHandler.GetStatusByReference(ctx, tran.PaymentGateway, tran.ID.String())
+	if errors.Is(err, payment.ErrPaymentNotFound) {
+		s.log.Error(ctx, "Failed to process transaction", "transaction_id", tran.ID, "error", cause)
+		return s.failTransaction(ctx, tran, fmt.Sprintf("%v, and the payment gateway has no payment for it", cause))
+	}
+	if errors.Is(err, payment.ErrLookupUnsupported) {
+		s.log.Error(ctx, "Dispatched transaction cannot be sent again, reconcile it with its payment gateway", "transaction_id", tran.ID, "gateway", tran.PaymentGateway, "error", cause)
+		return nil
+	}
+	if err != nil {
+		s.log.Warn(ctx, "Failed to look up dispatched transaction, transaction will be retried", "transaction_id", tran.ID, "error", err)
+		return err
+	}
+
+	s.securityCodes.delete(tran.ID)
+	return s.acceptTransaction(ctx, tran, res, "payment found at payment gateway by merchant reference")
+}
+
+// failTransaction fails a created transaction the worker could not send to its gateway.
+func (s *Service) failTransaction(ctx context.Context, tran *models.Transaction, reason string) error {
+	return s.retryOnConflict(ctx, tran, func(tran *models.Transaction) error {
+		if tran.Status != models.TransactionStatusCreated {
+			return nil
+		}
+		return s.transition(ctx, tran, models.TransactionStatusFailed, models.TransitionSourceWorker, reason)
+	})
+}
+
+// submitTransaction sends the transaction to its payment gateway based on its type.
+func (s *Service) submitTransaction(ctx context.Context, tran *models.Transaction, paymentDetails json.RawMessage) (*payment.Response, error) {
 	paymentReq := &payment.Request{
-		ID:                   tran.ID.String(),
-		Amount:               tran.Amount,
-		CallbackURL:          fmt.Sprintf(s.cbformat, tran.WalletID, tran.ID),
-		PaymentMethodDetails: item.PaymentDetails,
+		ID:          tran.ID.String(),
+		Amount:      tran.Amount,
+		Currency:    tran.Currency,
+		CallbackURL: s.callbackURL(tran, tran.PaymentGateway),
+		CallbackURLFor: func(gateway models.PaymentGateway) string {
+			return s.callbackURL(tran, gateway)
+		},
+		PaymentMethod:        tran.PaymentMethod,
+		PaymentMethodDetails: paymentDetails,
+		Dispatched:           tran.Dispatched,
+		BeforeSend: func(ctx context.Context, gateway models.PaymentGateway) error {
+			return s.recordDispatch(ctx, tran, gateway, true)
+		},
 	}
 
-	// Process the transaction based on its type
 	switch tran.Type {
 	case models.TransactionTypeDeposit:
-		res, err = s.paymentHandler.Deposit(ctx, tran.PaymentGateway, paymentReq)
+		return s.paymentHandler.Deposit(ctx, tran.PaymentGateway, paymentReq)
 	case models.TransactionTypeWithdrawal:
-		res, err = s.paymentHandler.Withdraw(ctx, tran.PaymentGateway, paymentReq)
+		return s.paymentHandler.Withdraw(ctx, tran.PaymentGateway, paymentReq)
+	case models.TransactionTypeRefund:
+		var err error
+		paymentReq.ReferenceID, err = s.refundReference(ctx, tran)
+		if err != nil {
+			return nil, err
+		}
+		return s.paymentHandler.Refund(ctx, tran.PaymentGateway, paymentReq)
 	default:
-		err = fmt.Errorf("unsupported transaction type: %s", tran.Type)
+		return nil, fmt.Errorf("unsupported transaction type: %s", tran.Type)
+	}
+}
+
+// recordDispatch records whether a request for the transaction might have reached the
+// gateway, before it is sent to it. The queue retries a dispatched transaction on that
+// gateway only, where its merchant reference prevents a second payment, as failing it
+// over to another gateway could move the money twice.
+func (s *Service) recordDispatch(ctx context.Context, tran *models.Transaction, gateway models.PaymentGateway, dispatched bool) error {
+	if tran.Dispatched == dispatched && tran.PaymentGateway == gateway {
+		return nil
+	}
+
+	prevGateway, prevDispatched := tran.PaymentGateway, tran.Dispatched
+	tran.PaymentGateway, tran.Dispatched = gateway, dispatched
+	if err := s.transactionRepo.Update(ctx, tran); err != nil {
+		tran.PaymentGateway, tran.Dispatched = prevGateway, prevDispatched
+		return fmt.Errorf("%w: %w", errDispatch, err)
+	}
+	return nil
+}
+
+// acceptedReason is the history reason of a transaction accepted by its payment gateway,
+// recording the gateways the transaction failed over from.
+func acceptedReason(res *payment.Response) string {
+	if len(res.Failovers) == 0 {
+		return "accepted by payment gateway"
+	}
+
+	from := make([]string, 0, len(res.Failovers))
+	for _, f := range res.Failovers {
+		from = append(from, fmt.Sprintf("%s (%s)", f.Gateway, f.Reason))
+	}
+	return fmt.Sprintf("accepted by payment gateway %s after failing over from %s", res.Gateway, strings.Join(from, ", "))
+}
+
+// callbackURL returns the URL the payment gateway reports the status of the transaction
+// to: the webhook of the gateway when configured, else the legacy callback URL of the
+// transaction, which exposes the wallet and transaction IDs to the gateway.
+func (s *Service) callbackURL(tran *models.Transaction, gateway models.PaymentGateway) string {
+	if s.whformat != "" {
+		return fmt.Sprintf(s.whformat, gateway)
+	}
+	return fmt.Sprintf(s.cbformat, tran.WalletID, tran.ID)
+}
+
+// enqueueTransaction adds a transaction to the queue for processing. It should be
+// called in the database transaction that creates the transaction, so a transaction
+// is never created without being queued.
+func (s *Service) enqueueTransaction(ctx context.Context, tran *models.Transaction) error {
+	item := QueueItem{
+		ID:      tran.ID,
+		Gateway: tran.PaymentGateway,
+	}
+	// only this replica can send a card payment, it holds the security code.
+	if ttl, ok := s.securityCodes.expiresIn(tran.ID); ok {
+		item.ownedFor = ttl
+	}
+	return s.tranQueue.Enqueue(ctx, item)
+}
+
+// DeadLetters lists the queued transactions whose processing failed for good.
+func (s *Service) DeadLetters(ctx context.Context) ([]DeadLetter, error) {
+	letters, err := s.tranQueue.DeadLetters(ctx)
+	if err != nil {
+		return nil, errs.New(errs.Internal, err)
 	}
 
+	res := make([]DeadLetter, 0, len(letters))
+	for _, l := range letters {
+		res = append(res, toDeadLetter(l))
+	}
+	return res, nil
+}
+
+// DeadLetter retrieves a dead-lettered transaction by its dead letter ID.
+func (s *Service) DeadLetter(ctx context.Context, id uuid.UUID) (*DeadLetter, error) {
+	letter, err := s.tranQueue.DeadLetter(ctx, id)
 	if err != nil {
-		return
+		if errors.Is(err, queue.ErrNotFound) {
+			return nil, errs.New(errs.NotFound, fmt.Errorf("dead letter %s not found", id))
+		}
+		return nil, errs.New(errs.Internal, err)
 	}
 
-	tran.ReferenceID = &res.ID
-	tran.Status = models.TransactionStatusPending
+	res := toDeadLetter(*letter)
+	return &res, nil
+}
+
+// deadLetterTransaction fails a transaction whose job is dead-lettered, which releases
+// its hold and clears its payment details, so it does not stay created forever.
+func (s *Service) deadLetterTransaction(ctx context.Context, item QueueItem, cause error) error {
+	s.securityCodes.delete(item.ID)
 
-	if err = s.transactionRepo.Update(ctx, tran); err != nil {
-		return
+	tran, err := s.transactionRepo.GetByID(ctx, item.ID)
+	if err != nil {
+		if errs.HasCode(err, errs.NotFound) {
+			return nil
+		}
+		return err
 	}
 
-	s.log.Info(ctx, "Transaction processed successfully", "transaction_id", tran.ID)
+	s.log.Error(ctx, "Transaction dead-lettered", "transaction_id", tran.ID, "error", cause)
+	return s.failTransaction(ctx, tran, fmt.Sprintf("processing failed for good: %v", cause))
 }
 
-// enqueueTransaction adds a transaction to the queue for processing.
-func (s *Service) enqueueTransaction(tranID uuid.UUID, paymentDetails json.RawMessage) {
-	s.tranQueue.Enqueue(QueueItem{
-		ID:             tranID,
-		PaymentDetails: paymentDetails,
-	})
+// RequeueDeadLetter moves a dead-lettered transaction back to the queue. Only dead
+// letters whose transaction is still created can be requeued, as dead-lettering
+// fails the transaction.
+func (s *Service) RequeueDeadLetter(ctx context.Context, id uuid.UUID) error {
+	letter, err := s.DeadLetter(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	tran, err := s.transactionRepo.GetByID(ctx, letter.TransactionID)
+	if err != nil && !errs.HasCode(err, errs.NotFound) {
+		return err
+	}
+	if tran != nil && tran.Status != models.TransactionStatusCreated {
+		return errs.Newf(errs.InvalidArgument, "transaction %s of dead letter %s is already %s", tran.ID, id, tran.Status)
+	}
+
+	if err := s.tranQueue.Requeue(ctx, id); err != nil {
+		if errors.Is(err, queue.ErrNotFound) {
+			return errs.New(errs.NotFound, fmt.Errorf("dead letter %s not found", id))
+		}
+		return errs.New(errs.Internal, err)
+	}
+	return nil
 }
 
 // Start starts the transaction queue worker.
 func (s *Service) Start(ctx context.Context) {
+	s.tranQueue.OnDeadLetter(s.deadLetterTransaction)
 	s.tranQueue.StartWorker(ctx, s.processTransaction)
 }
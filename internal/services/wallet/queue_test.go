@@ -0,0 +1,229 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/3bd-dev/wallet-service/internal/dto/request"
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/internal/payment"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/3bd-dev/wallet-service/pkg/unitest"
+)
+
+func Test_ProcessTransaction(t *testing.T) {
+	t.Parallel()
+
+	unitest.Run(t, securityCodeReplicas(), "securityCodeReplicas")
+}
+
+func securityCodeReplicas() []unitest.Table {
+	card := request.Payment{
+		Gateway:       models.PaymentGatewayA,
+		Method:        models.PaymentMethodCreditCard,
+		MethodDetails: json.RawMessage(`{"number": "4111111111111111", "expiry": "12/30", "cvv": "123"}`),
+	}
+
+	// deposit deposits 50 by card to a new wallet.
+	deposit := func(s *Service, f *fakes) *models.Transaction {
+		tran, err := s.Deposit(context.Background(), f.newWallet(s, ""), request.Deposit{
+			Amount:   money.MustParse("50"),
+			Currency: money.USD,
+			Payment:  card,
+		})
+		if err != nil {
+			panic(err)
+		}
+		return tran
+	}
+
+	process := func(s *Service, tran *models.Transaction) error {
+		return s.processTransaction(context.Background(), QueueItem{ID: tran.ID, Gateway: tran.PaymentGateway})
+	}
+
+	// restart moves the creation of the transaction back past the security code TTL, and
+	// returns a replica started since.
+	restart := func(f *fakes, tran *models.Transaction) *Service {
+		f.transactions.mu.Lock()
+		defer f.transactions.mu.Unlock()
+
+		stored := f.transactions.transactions[tran.ID]
+		stored.CreatedAt = stored.CreatedAt.Add(-2 * securityCodeTTL)
+		f.transactions.transactions[tran.ID] = stored
+		return f.newReplica()
+	}
+
+	// dispatch sends the deposit to the gateway, which creates the payment but whose
+	// response is lost, so the transaction stays created.
+	dispatch := func(s *Service, f *fakes) *models.Transaction {
+		tran := deposit(s, f)
+
+		f.gateway.lost = true
+		defer func() { f.gateway.lost = false }()
+		if err := process(s, tran); !errors.Is(err, payment.ErrUnavailable) {
+			panic(fmt.Sprintf("expected the gateway to be unavailable, got %v", err))
+		}
+		return tran
+	}
+
+	// statusOf returns the status of the transaction, and whether it is dispatched.
+	statusOf := func(f *fakes, tran *models.Transaction) string {
+		stored, err := f.transactions.GetByID(context.Background(), tran.ID)
+		if err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("%s dispatched=%t", stored.Status, stored.Dispatched)
+	}
+
+	cmp := func(got any, exp any) string {
+		if fmt.Sprint(got) != fmt.Sprint(exp) {
+			return fmt.Sprintf("expected %v, got %v", exp, got)
+		}
+		return ""
+	}
+
+	return []unitest.Table{
+		{
+			Name:    "Card Job Owned By Accepting Replica",
+			ExpResp: "true false",
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newTestService()
+				deposit(s, f)
+
+				bank := card
+				bank.Method = models.PaymentMethodBankTransfer
+				bank.MethodDetails = json.RawMessage(`{"account_number": "1234567890", "bank_code": "BOFAUS3NXXX", "bank_code_type": "SWIFT"}`)
+				if _, err := s.Deposit(ctx, f.newWallet(s, ""), request.Deposit{Amount: money.MustParse("50"), Currency: money.USD, Payment: bank}); err != nil {
+					return err
+				}
+
+				owned := f.queue.items[0].OwnedFor()
+				return fmt.Sprint(owned > 0 && owned <= securityCodeTTL, " ", f.queue.items[1].OwnedFor() > 0)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Accepting Replica Sends Security Code",
+			ExpResp: "pending dispatched=true 123",
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newTestService()
+				tran := deposit(s, f)
+				if err := process(s, tran); err != nil {
+					return err
+				}
+
+				sent, err := f.gateway.sent[0].CreditCard()
+				if err != nil {
+					return err
+				}
+				return statusOf(f, tran) + " " + sent.CVV
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Other Replica Retries Until Expiry",
+			ExpResp: "true created dispatched=false 0",
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newTestService()
+				tran := deposit(s, f)
+
+				err := process(f.newReplica(), tran)
+				return fmt.Sprint(errors.Is(err, errSecurityCodeUnavailable), " ", statusOf(f, tran), " ", len(f.gateway.sent))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Other Replica Retries Dispatched Until Expiry",
+			ExpResp: "true created dispatched=true",
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newTestService()
+				tran := dispatch(s, f)
+
+				err := process(f.newReplica(), tran)
+				return fmt.Sprint(errors.Is(err, errSecurityCodeUnavailable), " ", statusOf(f, tran))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Restart Before Dispatch Fails Transaction",
+			ExpResp: "failed dispatched=false 0",
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newTestService()
+				tran := deposit(s, f)
+
+				if err := process(restart(f, tran), tran); err != nil {
+					return err
+				}
+				return fmt.Sprint(statusOf(f, tran), " ", len(f.gateway.sent))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Restart After Dispatch Finds Payment",
+			ExpResp: "pending dispatched=true true 1",
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newTestService()
+				tran := dispatch(s, f)
+
+				if err := process(restart(f, tran), tran); err != nil {
+					return err
+				}
+
+				stored, err := f.transactions.GetByID(ctx, tran.ID)
+				if err != nil {
+					return err
+				}
+				found := stored.ReferenceID != nil && *stored.ReferenceID == "ref-"+tran.ID.String()
+				return fmt.Sprint(statusOf(f, tran), " ", found, " ", len(f.gateway.sent))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Restart After Dispatch Credits Completed Payment",
+			ExpResp: "completed dispatched=true 50.00/50.00",
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newTestService()
+				tran := dispatch(s, f)
+				f.gateway.payments[tran.ID.String()] = payment.PaymentStatusSuccess
+
+				if err := process(restart(f, tran), tran); err != nil {
+					return err
+				}
+				return statusOf(f, tran) + " " + balanceOf(s, tran.WalletID)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Restart After Dispatch Without Payment Fails Transaction",
+			ExpResp: "failed dispatched=true",
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newTestService()
+				tran := dispatch(s, f)
+				delete(f.gateway.payments, tran.ID.String())
+
+				if err := process(restart(f, tran), tran); err != nil {
+					return err
+				}
+				return statusOf(f, tran)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Restart After Dispatch Retries Unavailable Gateway",
+			ExpResp: "true created dispatched=true",
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newTestService()
+				tran := dispatch(s, f)
+
+				restarted := restart(f, tran)
+				f.gateway.lost = true
+				err := process(restarted, tran)
+				return fmt.Sprint(errors.Is(err, payment.ErrUnavailable), " ", statusOf(f, tran))
+			},
+			CmpFunc: cmp,
+		},
+	}
+}
@@ -0,0 +1,138 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/internal/payment"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+)
+
+// ReconcilerConfig holds the settings of the stuck transaction reconciler.
+type ReconcilerConfig struct {
+	// Interval is the time between two reconciliation runs.
+	Interval time.Duration
+	// CreatedAfter is how long a transaction can stay created before it is considered stuck.
+	CreatedAfter time.Duration
+	// PendingAfter is how long a transaction can stay pending before its gateway is polled for its status.
+	PendingAfter time.Duration
+	// BatchSize caps the number of stuck transactions handled per status and run.
+	BatchSize int
+}
+
+// StartReconciler recovers stuck transactions and polls the payment gateways for
+// pending ones on startup, then every cfg.Interval until the context is done.
+func (s *Service) StartReconciler(ctx context.Context, cfg ReconcilerConfig) {
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			s.reconcile(ctx, cfg)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// reconcile runs a single reconciliation of the created and pending transactions,
+// and purges the expired idempotency keys.
+func (s *Service) reconcile(ctx context.Context, cfg ReconcilerConfig) {
+	now := time.Now()
+
+	if _, err := s.idempotencyRepo.DeleteExpired(ctx); err != nil {
+		s.log.Error(ctx, "Failed to delete expired idempotency keys", "error", err)
+	}
+
+	created, err := s.transactionRepo.GetStale(ctx, models.TransactionStatusCreated, now.Add(-cfg.CreatedAfter), cfg.BatchSize)
+	if err != nil {
+		s.log.Error(ctx, "Failed to list stuck created transactions", "error", err)
+	}
+	for i := range created {
+		if err := s.reconcileCreated(ctx, &created[i]); err != nil {
+			s.log.Error(ctx, "Failed to reconcile transaction", "transaction_id", created[i].ID, "error", err)
+		}
+	}
+
+	pending, err := s.transactionRepo.GetStale(ctx, models.TransactionStatusPending, now.Add(-cfg.PendingAfter), cfg.BatchSize)
+	if err != nil {
+		s.log.Error(ctx, "Failed to list stuck pending transactions", "error", err)
+	}
+	for i := range pending {
+		if err := s.reconcilePending(ctx, &pending[i]); err != nil {
+			s.log.Error(ctx, "Failed to poll transaction status", "transaction_id", pending[i].ID, "error", err)
+		}
+	}
+}
+
+// reconcileCreated queues a created transaction the queue lost track of again. Its
+// payment details are sealed with the transaction, so the worker can still send it,
+// except for a card payment whose security code is gone, see reconcileDispatched.
+func (s *Service) reconcileCreated(ctx context.Context, tran *models.Transaction) error {
+	queued, err := s.tranQueue.Contains(ctx, tran.ID)
+	if err != nil {
+		return err
+	}
+
+	// the queue still has the transaction, waiting for a retry or in the dead letters.
+	if queued {
+		return s.reconcileDeadLettered(ctx, tran)
+	}
+
+	// reload the transaction, its job might have completed since it was listed.
+	tran, err = s.transactionRepo.GetByID(ctx, tran.ID)
+	if err != nil {
+		return err
+	}
+	if tran.Status != models.TransactionStatusCreated {
+		return nil
+	}
+
+	s.log.Warn(ctx, "Requeuing stuck transaction", "transaction_id", tran.ID)
+	return s.enqueueTransaction(ctx, tran)
+}
+
+// reconcileDeadLettered fails a created transaction whose job is in the dead letters,
+// which happens for jobs dead-lettered before dead-lettering failed their transaction.
+func (s *Service) reconcileDeadLettered(ctx context.Context, tran *models.Transaction) error {
+	dead, err := s.tranQueue.DeadLettered(ctx, tran.ID)
+	if err != nil || !dead {
+		return err
+	}
+
+	return s.deadLetterTransaction(ctx, QueueItem{ID: tran.ID, Gateway: tran.PaymentGateway}, errors.New("job was dead-lettered"))
+}
+
+// reconcilePending polls the payment gateway for the status of a transaction it did not
+// call back for, and applies a final status the same way a callback does. A transaction
+// still pending at the gateway is polled again once cfg.PendingAfter elapsed.
+func (s *Service) reconcilePending(ctx context.Context, tran *models.Transaction) error {
+	if tran.ReferenceID == nil {
+		return nil
+	}
+
+	res, err := s.paymentHandler.GetStatus(ctx, tran.PaymentGateway, *tran.ReferenceID)
+	if err != nil {
+		return err
+	}
+
+	if res.Status == payment.PaymentStatusPending {
+		return s.transactionRepo.Touch(ctx, tran.ID)
+	}
+
+	s.log.Info(ctx, "Applying polled payment status", "transaction_id", tran.ID, "status", res.Status)
+	err = s.applyPaymentStatus(ctx, tran, res, models.TransitionSourceWorker, "payment gateway status query reported")
+	if errs.HasCode(err, errs.Aborted) {
+		// a callback applied a status since the transaction was listed, the next run
+		// polls it again if it is still pending.
+		s.log.Info(ctx, "Transaction updated concurrently, skipping", "transaction_id", tran.ID)
+		return nil
+	}
+	return err
+}
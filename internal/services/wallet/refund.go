@@ -0,0 +1,153 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/3bd-dev/wallet-service/internal/dto/request"
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/google/uuid"
+)
+
+// Refund creates a refund of a completed deposit. Refunds can be full or partial,
+// but the sum of all refunds that did not fail never exceeds the deposited amount.
+// The refunded amount is held on the wallet until the gateway confirms the refund.
+func (s *Service) Refund(ctx context.Context, walletID, tranID uuid.UUID, req request.Refund) (*models.Transaction, error) {
+	if err := errs.Check(req); err != nil {
+		return nil, err
+	}
+
+	var refund *models.Transaction
+	err := s.transactor.WithTx(ctx, func(ctx context.Context) error {
+		// lock the wallet first so concurrent refunds of the same deposit are serialized.
+		if _, err := s.walletRepo.GetByIDForUpdate(ctx, walletID); err != nil {
+			return err
+		}
+
+		original, err := s.transactionRepo.GetByIDAndWalletID(ctx, tranID, walletID)
+		if err != nil {
+			return err
+		}
+
+		if original.Type != models.TransactionTypeDeposit || original.Status != models.TransactionStatusCompleted {
+			return errs.New(errs.InvalidArgument, errors.New("only completed deposits can be refunded"))
+		}
+
+		refundable, err := s.refundable(ctx, original)
+		if err != nil {
+			return err
+		}
+
+		amount := req.Amount
+		if amount == 0 {
+			amount = refundable
+		}
+
+		if amount <= 0 || amount > refundable {
+			return errs.Newf(errs.InvalidArgument, "refund amount must be between 0 and the refundable amount %s", refundable)
+		}
+
+		if err := original.Currency.CheckAmount(amount); err != nil {
+			return errs.New(errs.InvalidArgument, err)
+		}
+
+		refund = &models.Transaction{
+			ID:                   uuid.New(),
+			WalletID:             original.WalletID,
+			Amount:               amount,
+			Currency:             original.Currency,
+			Status:               models.TransactionStatusCreated,
+			Type:                 models.TransactionTypeRefund,
+			Direction:            models.PostingDirectionDebit,
+			PaymentGateway:       original.PaymentGateway,
+			PaymentMethod:        original.PaymentMethod,
+			PaymentMethodDetails: original.PaymentMethodDetails,
+			RelatedTransactionID: &original.ID,
+		}
+
+		if err := s.createTransaction(ctx, refund, "refund requested"); err != nil {
+			return err
+		}
+		if err := s.placeHold(ctx, refund); err != nil {
+			return err
+		}
+		return s.enqueueTransaction(ctx, refund)
+	})
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+
+	return refund, nil
+}
+
+// refundable returns the amount of the deposit that is neither refunded nor being refunded.
+func (s *Service) refundable(ctx context.Context, deposit *models.Transaction) (money.Money, error) {
+	refunded, err := s.refunded(ctx, deposit, func(status models.TransactionStatus) bool {
+		return status != models.TransactionStatusFailed
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deposit.Amount - refunded, nil
+}
+
+// refunded sums the refunds of the deposit whose status matches.
+func (s *Service) refunded(ctx context.Context, deposit *models.Transaction, match func(models.TransactionStatus) bool) (money.Money, error) {
+	related, err := s.transactionRepo.GetByRelatedTransactionID(ctx, deposit.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	var refunded money.Money
+	for _, tran := range related {
+		if tran.Type == models.TransactionTypeRefund && match(tran.Status) {
+			refunded += tran.Amount
+		}
+	}
+	return refunded, nil
+}
+
+// markRefunded moves the deposit of a completed refund to refunded once its whole
+// amount has been refunded.
+func (s *Service) markRefunded(ctx context.Context, refund *models.Transaction, source models.TransitionSource) error {
+	if refund.RelatedTransactionID == nil {
+		return fmt.Errorf("refund %s is not linked to a transaction", refund.ID)
+	}
+
+	deposit, err := s.transactionRepo.GetByID(ctx, *refund.RelatedTransactionID)
+	if err != nil {
+		return err
+	}
+
+	refunded, err := s.refunded(ctx, deposit, func(status models.TransactionStatus) bool {
+		return status == models.TransactionStatusCompleted
+	})
+	if err != nil {
+		return err
+	}
+
+	if refunded < deposit.Amount || deposit.Status != models.TransactionStatusCompleted {
+		return nil
+	}
+	return s.transition(ctx, deposit, models.TransactionStatusRefunded, source, fmt.Sprintf("fully refunded by %s", refund.ID))
+}
+
+// refundReference returns the gateway reference of the payment a refund belongs to.
+func (s *Service) refundReference(ctx context.Context, refund *models.Transaction) (string, error) {
+	if refund.RelatedTransactionID == nil {
+		return "", fmt.Errorf("refund %s is not linked to a transaction", refund.ID)
+	}
+
+	original, err := s.transactionRepo.GetByID(ctx, *refund.RelatedTransactionID)
+	if err != nil {
+		return "", err
+	}
+
+	if original.ReferenceID == nil {
+		return "", fmt.Errorf("transaction %s has no gateway reference", original.ID)
+	}
+	return *original.ReferenceID, nil
+}
@@ -0,0 +1,196 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/3bd-dev/wallet-service/internal/dto/request"
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/3bd-dev/wallet-service/pkg/unitest"
+	"github.com/google/uuid"
+)
+
+func Test_Refund(t *testing.T) {
+	t.Parallel()
+
+	unitest.Run(t, refunds(), "refunds")
+}
+
+func refunds() []unitest.Table {
+	// newDeposit creates a wallet with a completed deposit of 100.
+	newDeposit := func() (*Service, *fakes, *models.Transaction) {
+		s, f := newTestService()
+		walletID := f.newWallet(s, "")
+		return s, f, f.newDeposit(s, walletID, "100")
+	}
+
+	refund := func(s *Service, deposit *models.Transaction, amount string) (*models.Transaction, error) {
+		var req request.Refund
+		if amount != "" {
+			req.Amount = money.MustParse(amount)
+		}
+		return s.Refund(context.Background(), deposit.WalletID, deposit.ID, req)
+	}
+
+	// statusOf returns the status of the deposit.
+	statusOf := func(f *fakes, deposit *models.Transaction) models.TransactionStatus {
+		tran, err := f.transactions.GetByID(context.Background(), deposit.ID)
+		if err != nil {
+			return models.TransactionStatus(err.Error())
+		}
+		return tran.Status
+	}
+
+	cmp := func(got any, exp any) string {
+		if fmt.Sprint(got) != fmt.Sprint(exp) {
+			return fmt.Sprintf("expected %v, got %v", exp, got)
+		}
+		return ""
+	}
+
+	return []unitest.Table{
+		{
+			Name:    "Partial Refund",
+			ExpResp: "40.00 100.00/60.00 1",
+			ExcFunc: func(ctx context.Context) any {
+				s, f, deposit := newDeposit()
+				tran, err := refund(s, deposit, "40")
+				if err != nil {
+					return err
+				}
+				return fmt.Sprint(tran.Amount, " ", balanceOf(s, deposit.WalletID), " ", len(f.queue.items))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Full Refund Without Amount",
+			ExpResp: "100.00 100.00/0.00",
+			ExcFunc: func(ctx context.Context) any {
+				s, _, deposit := newDeposit()
+				tran, err := refund(s, deposit, "")
+				if err != nil {
+					return err
+				}
+				return fmt.Sprint(tran.Amount, " ", balanceOf(s, deposit.WalletID))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Over Refund",
+			ExpResp: "true 100.00/40.00",
+			ExcFunc: func(ctx context.Context) any {
+				s, _, deposit := newDeposit()
+				if _, err := refund(s, deposit, "60"); err != nil {
+					return err
+				}
+				_, err := refund(s, deposit, "40.01")
+				return fmt.Sprint(errs.HasCode(err, errs.InvalidArgument), " ", balanceOf(s, deposit.WalletID))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Refunded Funds Spent",
+			ExpResp: true,
+			ExcFunc: func(ctx context.Context) any {
+				s, f, deposit := newDeposit()
+				f.holds.Create(ctx, &models.Hold{ID: uuid.New(), TransactionID: uuid.New(), WalletID: deposit.WalletID, Amount: money.MustParse("70"), Status: models.HoldStatusActive})
+
+				_, err := refund(s, deposit, "40")
+				return errs.HasCode(err, errs.InsufficientFunds)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Deposit Not Completed",
+			ExpResp: true,
+			ExcFunc: func(ctx context.Context) any {
+				s, f, deposit := newDeposit()
+				deposit.Status = models.TransactionStatusPending
+				f.transactions.Create(ctx, deposit)
+
+				_, err := refund(s, deposit, "40")
+				return errs.HasCode(err, errs.InvalidArgument)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Not A Deposit",
+			ExpResp: true,
+			ExcFunc: func(ctx context.Context) any {
+				s, f, deposit := newDeposit()
+				deposit.Type = models.TransactionTypeWithdrawal
+				f.transactions.Create(ctx, deposit)
+
+				_, err := refund(s, deposit, "40")
+				return errs.HasCode(err, errs.InvalidArgument)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Failed Refund Refundable Again",
+			ExpResp: "100.00 100.00/0.00",
+			ExcFunc: func(ctx context.Context) any {
+				s, _, deposit := newDeposit()
+				failed, err := refund(s, deposit, "60")
+				if err != nil {
+					return err
+				}
+				if err := complete(s, failed.ID, models.TransactionStatusFailed); err != nil {
+					return err
+				}
+
+				tran, err := refund(s, deposit, "")
+				if err != nil {
+					return err
+				}
+				return fmt.Sprint(tran.Amount, " ", balanceOf(s, deposit.WalletID))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Partially Refunded Deposit Stays Completed",
+			ExpResp: "completed 60.00/60.00",
+			ExcFunc: func(ctx context.Context) any {
+				s, f, deposit := newDeposit()
+				tran, err := refund(s, deposit, "40")
+				if err != nil {
+					return err
+				}
+				if err := complete(s, tran.ID, models.TransactionStatusCompleted); err != nil {
+					return err
+				}
+				return fmt.Sprint(statusOf(f, deposit), " ", balanceOf(s, deposit.WalletID))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Fully Refunded Deposit Marked Refunded",
+			ExpResp: "completed refunded 0.00/0.00",
+			ExcFunc: func(ctx context.Context) any {
+				s, f, deposit := newDeposit()
+				first, err := refund(s, deposit, "40")
+				if err != nil {
+					return err
+				}
+				second, err := refund(s, deposit, "")
+				if err != nil {
+					return err
+				}
+
+				if err := complete(s, first.ID, models.TransactionStatusCompleted); err != nil {
+					return err
+				}
+				partial := statusOf(f, deposit)
+
+				if err := complete(s, second.ID, models.TransactionStatusCompleted); err != nil {
+					return err
+				}
+				return fmt.Sprint(partial, " ", statusOf(f, deposit), " ", balanceOf(s, deposit.WalletID))
+			},
+			CmpFunc: cmp,
+		},
+	}
+}
@@ -3,9 +3,12 @@ package wallet
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/3bd-dev/wallet-service/internal/models"
 	"github.com/3bd-dev/wallet-service/internal/payment"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/3bd-dev/wallet-service/pkg/queue"
 	"github.com/google/uuid"
 )
 
@@ -14,19 +17,90 @@ type ITransactionRepo interface {
 	Create(ctx context.Context, transaction *models.Transaction) error
 	GetByIDAndWalletID(ctx context.Context, id, walletID uuid.UUID) (*models.Transaction, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Transaction, error)
+	GetByReferenceID(ctx context.Context, gateway models.PaymentGateway, refID string) (*models.Transaction, error)
 	Update(ctx context.Context, wallet *models.Transaction) error
 	GetByWalletID(ctx context.Context, walletID uuid.UUID) ([]models.Transaction, error)
+	GetByRelatedTransactionID(ctx context.Context, relatedID uuid.UUID) ([]models.Transaction, error)
+	GetStale(ctx context.Context, status models.TransactionStatus, before time.Time, limit int) ([]models.Transaction, error)
+	Touch(ctx context.Context, id uuid.UUID) error
+}
+
+type ITransactionHistoryRepo interface {
+	Create(ctx context.Context, history *models.TransactionStatusHistory) error
+	GetByTransactionID(ctx context.Context, tranID uuid.UUID) ([]models.TransactionStatusHistory, error)
 }
 
 type IWalletRepo interface {
 	Create(ctx context.Context, wallet *models.Wallet) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Wallet, error)
+	GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*models.Wallet, error)
 	List(ctx context.Context) ([]models.Wallet, error)
 }
 
+type ILedgerRepo interface {
+	Post(ctx context.Context, entry *models.LedgerEntry) error
+	Balance(ctx context.Context, walletID uuid.UUID) (money.Money, error)
+}
+
+type IHoldRepo interface {
+	Create(ctx context.Context, hold *models.Hold) error
+	GetByTransactionID(ctx context.Context, tranID uuid.UUID) (*models.Hold, error)
+	Update(ctx context.Context, hold *models.Hold) error
+	ActiveTotal(ctx context.Context, walletID uuid.UUID) (money.Money, error)
+}
+
+type IIdempotencyRepo interface {
+	Get(ctx context.Context, walletID uuid.UUID, op models.IdempotentOperation, key string) (*models.IdempotencyKey, error)
+	Create(ctx context.Context, ik *models.IdempotencyKey) (bool, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// ICallbackRepo defines the interface for gateway callback repository.
+type ICallbackRepo interface {
+	Create(ctx context.Context, callback *models.GatewayCallback) error
+	Update(ctx context.Context, callback *models.GatewayCallback) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.GatewayCallback, error)
+	List(ctx context.Context, status models.CallbackStatus, limit int) ([]models.GatewayCallback, error)
+}
+
+// IPaymentRouter chooses the payment gateway of transactions whose client did not choose one.
+type IPaymentRouter interface {
+	Route(req payment.RouteRequest) (*payment.Route, error)
+}
+
+// ITransactor runs a unit of work in a single database transaction.
+type ITransactor interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// ITransactionQueue queues transactions for asynchronous processing by the payment gateways.
+type ITransactionQueue interface {
+	Enqueue(ctx context.Context, item QueueItem) error
+	StartWorker(ctx context.Context, processFunc func(context.Context, QueueItem) error)
+	OnDeadLetter(fn func(ctx context.Context, item QueueItem, cause error) error)
+	DeadLetters(ctx context.Context) ([]queue.DeadLetter[QueueItem], error)
+	DeadLetter(ctx context.Context, id uuid.UUID) (*queue.DeadLetter[QueueItem], error)
+	Requeue(ctx context.Context, id uuid.UUID) error
+	Contains(ctx context.Context, id uuid.UUID) (bool, error)
+	DeadLettered(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+// ISealer encrypts the payment details stored with the transactions, with a key held outside the database.
+type ISealer interface {
+	Seal(plaintext, additionalData []byte) ([]byte, error)
+	Open(sealed, additionalData []byte) ([]byte, error)
+}
+
 type IPaymentHandler interface {
 	Deposit(ctx context.Context, gateway models.PaymentGateway, req *payment.Request) (*payment.Response, error)
 	Withdraw(ctx context.Context, gateway models.PaymentGateway, req *payment.Request) (*payment.Response, error)
-	VerifyCallback(ctx context.Context, gatewayName models.PaymentGateway, tranID string, data []byte) (*payment.Response, error)
+	Refund(ctx context.Context, gateway models.PaymentGateway, req *payment.Request) (*payment.Response, error)
+	VerifyCallback(ctx context.Context, gatewayName models.PaymentGateway, tranID string, cb *payment.Callback) (*payment.Response, error)
+	ParseCallback(ctx context.Context, gatewayName models.PaymentGateway, cb *payment.Callback) (*payment.Response, error)
+	VerifyGateway(gateway models.PaymentGateway) error
+	GetStatus(ctx context.Context, gateway models.PaymentGateway, refID string) (*payment.Response, error)
+	GetStatusByReference(ctx context.Context, gateway models.PaymentGateway, reference string) (*payment.Response, error)
+	VerifyAmount(gateway models.PaymentGateway, amount money.Money, currency money.Currency) error
 	VerifyMethod(gateway models.PaymentGateway, typ models.TransactionType, method models.PaymentMethod, paymMethDet json.RawMessage) (payment.PaymentMethodDetails, error)
+	Gateways() []payment.GatewayInfo
 }
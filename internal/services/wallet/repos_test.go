@@ -0,0 +1,509 @@
+package wallet
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/internal/payment"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/logger"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/3bd-dev/wallet-service/pkg/queue"
+	"github.com/3bd-dev/wallet-service/pkg/secret"
+	"github.com/google/uuid"
+)
+
+// walletRepo keeps the wallets in memory, and records the order they are locked in.
+type walletRepo struct {
+	mu      sync.Mutex
+	wallets map[uuid.UUID]models.Wallet
+	locked  []uuid.UUID
+}
+
+func (r *walletRepo) Create(ctx context.Context, wallet *models.Wallet) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.wallets[wallet.ID] = *wallet
+	return nil
+}
+
+func (r *walletRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Wallet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wallet, ok := r.wallets[id]
+	if !ok {
+		return nil, errs.Newf(errs.NotFound, "wallet with ID %s not found", id)
+	}
+	return &wallet, nil
+}
+
+func (r *walletRepo) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*models.Wallet, error) {
+	wallet, err := r.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.locked = append(r.locked, id)
+	return wallet, nil
+}
+
+func (r *walletRepo) List(ctx context.Context) ([]models.Wallet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var wallets []models.Wallet
+	for _, wallet := range r.wallets {
+		wallets = append(wallets, wallet)
+	}
+	return wallets, nil
+}
+
+// transactionRepo keeps the transactions in memory, with the version check of the database.
+type transactionRepo struct {
+	mu           sync.Mutex
+	transactions map[uuid.UUID]models.Transaction
+}
+
+func (r *transactionRepo) Create(ctx context.Context, tran *models.Transaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if tran.CreatedAt.IsZero() {
+		tran.CreatedAt = time.Now()
+	}
+	r.transactions[tran.ID] = *tran
+	return nil
+}
+
+func (r *transactionRepo) GetByIDAndWalletID(ctx context.Context, id, walletID uuid.UUID) (*models.Transaction, error) {
+	tran, err := r.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tran.WalletID != walletID {
+		return nil, errs.Newf(errs.NotFound, "transaction with ID %s not found", id)
+	}
+	return tran, nil
+}
+
+func (r *transactionRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tran, ok := r.transactions[id]
+	if !ok {
+		return nil, errs.Newf(errs.NotFound, "transaction with ID %s not found", id)
+	}
+	return &tran, nil
+}
+
+func (r *transactionRepo) GetByReferenceID(ctx context.Context, gateway models.PaymentGateway, refID string) (*models.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, tran := range r.transactions {
+		if tran.PaymentGateway == gateway && tran.ReferenceID != nil && *tran.ReferenceID == refID {
+			return &tran, nil
+		}
+	}
+	return nil, errs.Newf(errs.NotFound, "transaction with reference ID %s not found", refID)
+}
+
+func (r *transactionRepo) Update(ctx context.Context, tran *models.Transaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.transactions[tran.ID]; !ok || stored.Version != tran.Version {
+		return errs.Newf(errs.Aborted, "transaction with ID %s was updated concurrently", tran.ID)
+	}
+	tran.Version++
+	r.transactions[tran.ID] = *tran
+	return nil
+}
+
+func (r *transactionRepo) GetByWalletID(ctx context.Context, walletID uuid.UUID) ([]models.Transaction, error) {
+	return r.find(func(tran models.Transaction) bool {
+		return tran.WalletID == walletID
+	}), nil
+}
+
+func (r *transactionRepo) GetByRelatedTransactionID(ctx context.Context, relatedID uuid.UUID) ([]models.Transaction, error) {
+	return r.find(func(tran models.Transaction) bool {
+		return tran.RelatedTransactionID != nil && *tran.RelatedTransactionID == relatedID
+	}), nil
+}
+
+func (r *transactionRepo) GetStale(ctx context.Context, status models.TransactionStatus, before time.Time, limit int) ([]models.Transaction, error) {
+	return r.find(func(tran models.Transaction) bool {
+		return tran.Status == status && tran.UpdatedAt.Before(before)
+	}), nil
+}
+
+func (r *transactionRepo) Touch(ctx context.Context, id uuid.UUID) error {
+	return nil
+}
+
+func (r *transactionRepo) find(match func(models.Transaction) bool) []models.Transaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var found []models.Transaction
+	for _, tran := range r.transactions {
+		if match(tran) {
+			found = append(found, tran)
+		}
+	}
+	return found
+}
+
+// historyRepo keeps the status history in memory.
+type historyRepo struct {
+	mu      sync.Mutex
+	history []models.TransactionStatusHistory
+}
+
+func (r *historyRepo) Create(ctx context.Context, history *models.TransactionStatusHistory) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.history = append(r.history, *history)
+	return nil
+}
+
+func (r *historyRepo) GetByTransactionID(ctx context.Context, tranID uuid.UUID) ([]models.TransactionStatusHistory, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var history []models.TransactionStatusHistory
+	for _, h := range r.history {
+		if h.TransactionID == tranID {
+			history = append(history, h)
+		}
+	}
+	return history, nil
+}
+
+// ledgerRepo keeps the ledger entries in memory, and rejects unbalanced ones like the database.
+type ledgerRepo struct {
+	mu      sync.Mutex
+	entries []models.LedgerEntry
+}
+
+func (r *ledgerRepo) Post(ctx context.Context, entry *models.LedgerEntry) error {
+	if !entry.IsBalanced() {
+		return errs.Newf(errs.Internal, "ledger entry is not balanced")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, *entry)
+	return nil
+}
+
+func (r *ledgerRepo) Balance(ctx context.Context, walletID uuid.UUID) (money.Money, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var balance money.Money
+	for _, entry := range r.entries {
+		for _, posting := range entry.Postings {
+			if posting.WalletID == nil || *posting.WalletID != walletID {
+				continue
+			}
+			if posting.Direction == models.PostingDirectionCredit {
+				balance += posting.Amount
+			} else {
+				balance -= posting.Amount
+			}
+		}
+	}
+	return balance, nil
+}
+
+// holdRepo keeps the holds in memory, by transaction ID.
+type holdRepo struct {
+	mu    sync.Mutex
+	holds map[uuid.UUID]models.Hold
+}
+
+func (r *holdRepo) Create(ctx context.Context, hold *models.Hold) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.holds[hold.TransactionID] = *hold
+	return nil
+}
+
+func (r *holdRepo) GetByTransactionID(ctx context.Context, tranID uuid.UUID) (*models.Hold, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hold, ok := r.holds[tranID]
+	if !ok {
+		return nil, errs.Newf(errs.NotFound, "hold of transaction %s not found", tranID)
+	}
+	return &hold, nil
+}
+
+func (r *holdRepo) Update(ctx context.Context, hold *models.Hold) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.holds[hold.TransactionID] = *hold
+	return nil
+}
+
+func (r *holdRepo) ActiveTotal(ctx context.Context, walletID uuid.UUID) (money.Money, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var total money.Money
+	for _, hold := range r.holds {
+		if hold.WalletID == walletID && hold.Status == models.HoldStatusActive {
+			total += hold.Amount
+		}
+	}
+	return total, nil
+}
+
+// transactionQueue records the queued transactions, nothing processes them.
+type transactionQueue struct {
+	mu    sync.Mutex
+	items []QueueItem
+}
+
+func (q *transactionQueue) Enqueue(ctx context.Context, item QueueItem) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.items = append(q.items, item)
+	return nil
+}
+
+func (q *transactionQueue) StartWorker(ctx context.Context, processFunc func(context.Context, QueueItem) error) {
+}
+
+func (q *transactionQueue) OnDeadLetter(fn func(ctx context.Context, item QueueItem, cause error) error) {
+}
+
+func (q *transactionQueue) DeadLetters(ctx context.Context) ([]queue.DeadLetter[QueueItem], error) {
+	return nil, nil
+}
+
+func (q *transactionQueue) DeadLetter(ctx context.Context, id uuid.UUID) (*queue.DeadLetter[QueueItem], error) {
+	return nil, queue.ErrNotFound
+}
+
+func (q *transactionQueue) Requeue(ctx context.Context, id uuid.UUID) error {
+	return queue.ErrNotFound
+}
+
+func (q *transactionQueue) Contains(ctx context.Context, id uuid.UUID) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return slices.ContainsFunc(q.items, func(item QueueItem) bool {
+		return item.ID == id
+	}), nil
+}
+
+func (q *transactionQueue) DeadLettered(ctx context.Context, id uuid.UUID) (bool, error) {
+	return false, nil
+}
+
+// paymentGateway is a payment gateway accepting every USD payment, by card or bank transfer.
+type paymentGateway struct {
+	mu   sync.Mutex
+	sent []*payment.Request
+	// payments are the statuses of the payments by merchant reference.
+	payments map[string]payment.PaymentStatus
+	// lost makes the gateway create the payments, but lose the responses to every request.
+	lost bool
+}
+
+func (g *paymentGateway) Deposit(ctx context.Context, req *payment.Request) (*payment.Response, error) {
+	return g.send(req)
+}
+
+func (g *paymentGateway) Withdraw(ctx context.Context, req *payment.Request) (*payment.Response, error) {
+	return g.send(req)
+}
+
+func (g *paymentGateway) Refund(ctx context.Context, req *payment.Request) (*payment.Response, error) {
+	return g.send(req)
+}
+
+func (g *paymentGateway) send(req *payment.Request) (*payment.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.sent = append(g.sent, req)
+	if _, ok := g.payments[req.ID]; !ok {
+		g.payments[req.ID] = payment.PaymentStatusPending
+	}
+
+	if g.lost {
+		return nil, fmt.Errorf("%w: connection reset", payment.ErrUnavailable)
+	}
+	return &payment.Response{ID: "ref-" + req.ID, Status: g.payments[req.ID]}, nil
+}
+
+func (g *paymentGateway) GetStatusByReference(ctx context.Context, reference string) (*payment.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.lost {
+		return nil, fmt.Errorf("%w: connection reset", payment.ErrUnavailable)
+	}
+
+	status, ok := g.payments[reference]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", payment.ErrPaymentNotFound, reference)
+	}
+	return &payment.Response{ID: "ref-" + reference, Status: status}, nil
+}
+
+func (g *paymentGateway) ParseCallback(ctx context.Context, cb *payment.Callback) (*payment.Response, error) {
+	return nil, errs.Newf(errs.Unauthenticated, "callbacks are not signed")
+}
+
+func (g *paymentGateway) GetStatus(ctx context.Context, refID string) (*payment.Response, error) {
+	return &payment.Response{ID: refID, Status: payment.PaymentStatusPending}, nil
+}
+
+func (g *paymentGateway) Capabilities() payment.Capabilities {
+	methods := []models.PaymentMethod{models.PaymentMethodCreditCard, models.PaymentMethodBankTransfer}
+	return payment.Capabilities{
+		Types: []models.TransactionType{models.TransactionTypeDeposit, models.TransactionTypeWithdrawal, models.TransactionTypeRefund},
+		Methods: map[models.TransactionType][]models.PaymentMethod{
+			models.TransactionTypeDeposit:    methods,
+			models.TransactionTypeWithdrawal: methods,
+		},
+		Currencies: []payment.CurrencyCapability{{Currency: money.USD}},
+	}
+}
+
+func (g *paymentGateway) BreakerState() payment.BreakerState {
+	return payment.BreakerStateClosed
+}
+
+// fakes are the in-memory dependencies of a test service.
+type fakes struct {
+	wallets      *walletRepo
+	transactions *transactionRepo
+	history      *historyRepo
+	ledger       *ledgerRepo
+	holds        *holdRepo
+	queue        *transactionQueue
+	gateway      *paymentGateway
+}
+
+// newTestService creates a service without a database, whose only payment gateway is
+// models.PaymentGatewayA.
+func newTestService() (*Service, *fakes) {
+	f := &fakes{
+		wallets:      &walletRepo{wallets: map[uuid.UUID]models.Wallet{}},
+		transactions: &transactionRepo{transactions: map[uuid.UUID]models.Transaction{}},
+		history:      &historyRepo{},
+		ledger:       &ledgerRepo{},
+		holds:        &holdRepo{holds: map[uuid.UUID]models.Hold{}},
+		queue:        &transactionQueue{},
+		gateway:      &paymentGateway{payments: map[string]payment.PaymentStatus{}},
+	}
+	return f.newReplica(), f
+}
+
+// newReplica creates another service replica on the same repositories and gateway. It
+// holds the security codes of the card payments it accepts, like a restarted replica
+// it holds none of the others.
+func (f *fakes) newReplica() *Service {
+	box, err := secret.NewBox(base64.StdEncoding.EncodeToString(make([]byte, secret.KeySize)))
+	if err != nil {
+		panic(err)
+	}
+
+	return NewService(
+		logger.New(io.Discard, logger.LevelError, "TEST"),
+		f.wallets, f.transactions, f.history, f.ledger, f.holds,
+		&idempotencyRepo{keys: map[string]models.IdempotencyKey{}}, nil, transactor{}, f.queue,
+		payment.New(map[models.PaymentGateway]payment.PaymentGateway{models.PaymentGatewayA: f.gateway}), nil,
+		box, securityCodeTTL, "test-key", "/wallets/%s/transactions/%s/callback", "",
+	)
+}
+
+// securityCodeTTL is how long the test services hold card security codes.
+const securityCodeTTL = time.Minute
+
+// newWallet creates a USD wallet funded with a completed deposit of the balance.
+func (f *fakes) newWallet(s *Service, balance string) uuid.UUID {
+	wallet := &models.Wallet{ID: uuid.New(), Currency: money.USD}
+	if err := f.wallets.Create(context.Background(), wallet); err != nil {
+		panic(err)
+	}
+
+	if balance != "" {
+		f.newDeposit(s, wallet.ID, balance)
+	}
+	return wallet.ID
+}
+
+// newDeposit creates a completed deposit of the amount, posted to the ledger of the wallet.
+func (f *fakes) newDeposit(s *Service, walletID uuid.UUID, amount string) *models.Transaction {
+	refID := uuid.NewString()
+	deposit := &models.Transaction{
+		ID:             uuid.New(),
+		WalletID:       walletID,
+		Amount:         money.MustParse(amount),
+		Currency:       money.USD,
+		Status:         models.TransactionStatusCompleted,
+		Type:           models.TransactionTypeDeposit,
+		Direction:      models.PostingDirectionCredit,
+		PaymentGateway: models.PaymentGatewayA,
+		PaymentMethod:  models.PaymentMethodBankTransfer,
+		ReferenceID:    &refID,
+	}
+
+	ctx := context.Background()
+	if err := f.transactions.Create(ctx, deposit); err != nil {
+		panic(err)
+	}
+	if err := s.postTransaction(ctx, deposit); err != nil {
+		panic(err)
+	}
+	return deposit
+}
+
+// complete moves a created transaction through pending to the final status, as its
+// worker and gateway callback would.
+func complete(s *Service, id uuid.UUID, status models.TransactionStatus) error {
+	ctx := context.Background()
+	tran, err := s.transactionRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.transition(ctx, tran, models.TransactionStatusPending, models.TransitionSourceWorker, "sent"); err != nil {
+		return err
+	}
+	return s.transition(ctx, tran, status, models.TransitionSourceCallback, "reported")
+}
+
+// balanceOf returns the ledger and available balances of a wallet, as "ledger/available".
+func balanceOf(s *Service, walletID uuid.UUID) string {
+	wallet, err := s.Get(context.Background(), walletID)
+	if err != nil {
+		return err.Error()
+	}
+	return wallet.Balance.Ledger.String() + "/" + wallet.Balance.Available.String()
+}
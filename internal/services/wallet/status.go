@@ -0,0 +1,100 @@
+package wallet
+
+import (
+	"context"
+
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/google/uuid"
+)
+
+// createTransaction creates a transaction together with the history record of its initial status.
+func (s *Service) createTransaction(ctx context.Context, tran *models.Transaction, reason string) error {
+	return s.transactor.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.transactionRepo.Create(ctx, tran); err != nil {
+			return err
+		}
+		return s.recordStatus(ctx, tran, nil, models.TransitionSourceAPI, reason)
+	})
+}
+
+// transition moves the transaction to a new status through the state machine. The new
+// status, its history record and the resulting money movement are persisted in one
+// database transaction. On error the in-memory status is left unchanged, and the error
+// has the errs.Aborted code when the transaction was updated concurrently.
+func (s *Service) transition(ctx context.Context, tran *models.Transaction, to models.TransactionStatus, source models.TransitionSource, reason string) error {
+	from, version, sealed := tran.Status, tran.Version, tran.SealedPaymentDetails
+	if err := tran.TransitionTo(to); err != nil {
+		return errs.New(errs.InvalidArgument, err)
+	}
+
+	// the payment details are only kept until the transaction is sent to its gateway.
+	if from == models.TransactionStatusCreated {
+		tran.SealedPaymentDetails = nil
+	}
+
+	err := s.transactor.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.transactionRepo.Update(ctx, tran); err != nil {
+			return err
+		}
+
+		if err := s.recordStatus(ctx, tran, &from, source, reason); err != nil {
+			return err
+		}
+
+		return s.settleTransaction(ctx, tran, source)
+	})
+	if err != nil {
+		tran.Status, tran.Version, tran.SealedPaymentDetails = from, version, sealed
+		return err
+	}
+	return nil
+}
+
+// maxConflictRetries is the number of times an update conflicting with a concurrent
+// update of the transaction is retried with the reloaded transaction.
+const maxConflictRetries = 3
+
+// retryOnConflict runs fn with the transaction, and when fn fails because the transaction
+// was updated concurrently, reloads the transaction and runs fn again with it. fn must
+// check the reloaded transaction still needs the update, as the concurrent one might
+// have done it already.
+func (s *Service) retryOnConflict(ctx context.Context, tran *models.Transaction, fn func(tran *models.Transaction) error) error {
+	err := fn(tran)
+	for i := 0; i < maxConflictRetries && errs.HasCode(err, errs.Aborted); i++ {
+		s.log.Warn(ctx, "Transaction updated concurrently, retrying", "transaction_id", tran.ID, "error", err)
+
+		tran, err = s.transactionRepo.GetByID(ctx, tran.ID)
+		if err != nil {
+			return err
+		}
+		err = fn(tran)
+	}
+	return err
+}
+
+// recordStatus persists the history record of the current status of the transaction.
+func (s *Service) recordStatus(ctx context.Context, tran *models.Transaction, from *models.TransactionStatus, source models.TransitionSource, reason string) error {
+	return s.historyRepo.Create(ctx, &models.TransactionStatusHistory{
+		ID:            uuid.New(),
+		TransactionID: tran.ID,
+		FromStatus:    from,
+		ToStatus:      tran.Status,
+		Source:        source,
+		Reason:        reason,
+	})
+}
+
+// TransactionHistory retrieves the status history of a transaction of the given wallet.
+func (s *Service) TransactionHistory(ctx context.Context, id, walletID uuid.UUID) ([]models.TransactionStatusHistory, error) {
+	tran, err := s.transactionRepo.GetByIDAndWalletID(ctx, id, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.historyRepo.GetByTransactionID(ctx, tran.ID)
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
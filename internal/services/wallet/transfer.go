@@ -0,0 +1,104 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/3bd-dev/wallet-service/internal/dto/request"
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/google/uuid"
+)
+
+// Transfer moves funds from the given wallet to another wallet in a single database
+// transaction, no payment gateway is involved. Each wallet gets its own transaction
+// record, linked to the other one, and the outgoing record is returned.
+func (s *Service) Transfer(ctx context.Context, walletID uuid.UUID, req request.Transfer) (*models.Transaction, error) {
+	if err := errs.Check(req); err != nil {
+		return nil, err
+	}
+
+	if walletID == req.ToWalletID {
+		return nil, errs.New(errs.InvalidArgument, errors.New("cannot transfer to the same wallet"))
+	}
+
+	if err := req.Currency.CheckAmount(req.Amount); err != nil {
+		return nil, errs.New(errs.InvalidArgument, err)
+	}
+
+	out := &models.Transaction{
+		ID:        uuid.New(),
+		WalletID:  walletID,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+		Type:      models.TransactionTypeTransfer,
+		Direction: models.PostingDirectionDebit,
+		Status:    models.TransactionStatusCompleted,
+	}
+	in := &models.Transaction{
+		ID:        uuid.New(),
+		WalletID:  req.ToWalletID,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+		Type:      models.TransactionTypeTransfer,
+		Direction: models.PostingDirectionCredit,
+		Status:    models.TransactionStatusCompleted,
+	}
+	out.RelatedTransactionID = &in.ID
+	in.RelatedTransactionID = &out.ID
+
+	err := s.transactor.WithTx(ctx, func(ctx context.Context) error {
+		from, to, err := s.lockWallets(ctx, walletID, req.ToWalletID)
+		if err != nil {
+			return err
+		}
+
+		if from.Currency != req.Currency || to.Currency != req.Currency {
+			return errs.Newf(errs.InvalidArgument, "currency %s does not match the currencies of both wallets", req.Currency)
+		}
+
+		if err := s.ensureAvailable(ctx, from, req.Amount); err != nil {
+			return err
+		}
+
+		if err := s.createTransaction(ctx, out, fmt.Sprintf("transfer to wallet %s", in.WalletID)); err != nil {
+			return err
+		}
+
+		if err := s.createTransaction(ctx, in, fmt.Sprintf("transfer from wallet %s", out.WalletID)); err != nil {
+			return err
+		}
+
+		return s.postTransfer(ctx, out, in)
+	})
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+
+	return out, nil
+}
+
+// lockWallets locks the rows of both wallets, always in the same order so that
+// concurrent transfers between the same wallets cannot deadlock.
+func (s *Service) lockWallets(ctx context.Context, fromID, toID uuid.UUID) (*models.Wallet, *models.Wallet, error) {
+	firstID, secondID := fromID, toID
+	if toID.String() < fromID.String() {
+		firstID, secondID = toID, fromID
+	}
+
+	first, err := s.walletRepo.GetByIDForUpdate(ctx, firstID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	second, err := s.walletRepo.GetByIDForUpdate(ctx, secondID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if first.ID == fromID {
+		return first, second, nil
+	}
+	return second, first, nil
+}
@@ -0,0 +1,141 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/3bd-dev/wallet-service/internal/dto/request"
+	"github.com/3bd-dev/wallet-service/internal/models"
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/money"
+	"github.com/3bd-dev/wallet-service/pkg/unitest"
+	"github.com/google/uuid"
+)
+
+func Test_Transfer(t *testing.T) {
+	t.Parallel()
+
+	unitest.Run(t, transfers(), "transfers")
+}
+
+func transfers() []unitest.Table {
+	low := uuid.MustParse("10000000-0000-0000-0000-000000000000")
+	high := uuid.MustParse("f0000000-0000-0000-0000-000000000000")
+
+	// newWallets creates the wallets low and high, funded with 100 of their currency.
+	newWallets := func(lowCurrency, highCurrency money.Currency) (*Service, *fakes) {
+		s, f := newTestService()
+		for id, currency := range map[uuid.UUID]money.Currency{low: lowCurrency, high: highCurrency} {
+			f.wallets.Create(context.Background(), &models.Wallet{ID: id, Currency: currency})
+			f.newDeposit(s, id, "100")
+		}
+		return s, f
+	}
+
+	transfer := func(s *Service, from, to uuid.UUID, amount string) (*models.Transaction, error) {
+		return s.Transfer(context.Background(), from, request.Transfer{
+			ToWalletID: to,
+			Amount:     money.MustParse(amount),
+			Currency:   money.USD,
+		})
+	}
+
+	cmp := func(got any, exp any) string {
+		if fmt.Sprint(got) != fmt.Sprint(exp) {
+			return fmt.Sprintf("expected %v, got %v", exp, got)
+		}
+		return ""
+	}
+
+	return []unitest.Table{
+		{
+			Name:    "Funds Moved",
+			ExpResp: "70.00/70.00 130.00/130.00",
+			ExcFunc: func(ctx context.Context) any {
+				s, _ := newWallets(money.USD, money.USD)
+				if _, err := transfer(s, low, high, "30"); err != nil {
+					return err
+				}
+				return balanceOf(s, low) + " " + balanceOf(s, high)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Transactions Linked",
+			ExpResp: "debit credit true",
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newWallets(money.USD, money.USD)
+				out, err := transfer(s, low, high, "30")
+				if err != nil {
+					return err
+				}
+
+				in, err := f.transactions.GetByID(ctx, *out.RelatedTransactionID)
+				if err != nil {
+					return err
+				}
+				linked := in.WalletID == high && *in.RelatedTransactionID == out.ID
+				return fmt.Sprint(out.Direction, " ", in.Direction, " ", linked)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Insufficient Funds",
+			ExpResp: "true 100.00/100.00 100.00/100.00",
+			ExcFunc: func(ctx context.Context) any {
+				s, _ := newWallets(money.USD, money.USD)
+				_, err := transfer(s, low, high, "100.01")
+				return fmt.Sprint(errs.HasCode(err, errs.InsufficientFunds), " ", balanceOf(s, low), " ", balanceOf(s, high))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Held Funds Not Transferable",
+			ExpResp: true,
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newWallets(money.USD, money.USD)
+				f.holds.Create(ctx, &models.Hold{ID: uuid.New(), TransactionID: uuid.New(), WalletID: low, Amount: money.MustParse("80"), Status: models.HoldStatusActive})
+
+				_, err := transfer(s, low, high, "30")
+				return errs.HasCode(err, errs.InsufficientFunds)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Same Wallet",
+			ExpResp: "true []",
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newWallets(money.USD, money.USD)
+				_, err := transfer(s, low, low, "30")
+				return fmt.Sprint(errs.HasCode(err, errs.InvalidArgument), " ", f.wallets.locked)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Currency Mismatch",
+			ExpResp: true,
+			ExcFunc: func(ctx context.Context) any {
+				s, _ := newWallets(money.USD, money.EUR)
+				_, err := transfer(s, low, high, "30")
+				return errs.HasCode(err, errs.InvalidArgument)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Wallets Locked In The Same Order",
+			ExpResp: []uuid.UUID{low, high, low, high},
+			ExcFunc: func(ctx context.Context) any {
+				s, f := newWallets(money.USD, money.USD)
+				if _, err := transfer(s, low, high, "30"); err != nil {
+					return err
+				}
+				if _, err := transfer(s, high, low, "30"); err != nil {
+					return err
+				}
+				return f.wallets.locked
+			},
+			CmpFunc: cmp,
+		},
+	}
+}
@@ -3,13 +3,15 @@ package wallet
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/3bd-dev/wallet-service/internal/dto/request"
 	"github.com/3bd-dev/wallet-service/internal/models"
 	"github.com/3bd-dev/wallet-service/internal/payment"
 	"github.com/3bd-dev/wallet-service/pkg/errs"
 	"github.com/3bd-dev/wallet-service/pkg/logger"
-	"github.com/3bd-dev/wallet-service/pkg/queue"
+	"github.com/3bd-dev/wallet-service/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -17,28 +19,62 @@ type Service struct {
 	log             *logger.Logger
 	walletRepo      IWalletRepo
 	transactionRepo ITransactionRepo
+	historyRepo     ITransactionHistoryRepo
+	ledgerRepo      ILedgerRepo
+	holdRepo        IHoldRepo
+	idempotencyRepo IIdempotencyRepo
+	callbackRepo    ICallbackRepo
+	transactor      ITransactor
 	paymentHandler  IPaymentHandler
+	paymentRouter   IPaymentRouter
 	cbformat        string
-	tranQueue       *queue.Queue[QueueItem]
+	whformat        string
+	tranQueue       ITransactionQueue
+	sealer          ISealer
+	securityCodes   *securityCodes
+	hashKey         []byte
 }
 
-func NewService(log *logger.Logger, walletRepo IWalletRepo, transactionRepo ITransactionRepo, paymenth IPaymentHandler, cbformat string) *Service {
+func NewService(log *logger.Logger, walletRepo IWalletRepo, transactionRepo ITransactionRepo, historyRepo ITransactionHistoryRepo, ledgerRepo ILedgerRepo, holdRepo IHoldRepo, idempotencyRepo IIdempotencyRepo, callbackRepo ICallbackRepo, transactor ITransactor, tranQueue ITransactionQueue, paymenth IPaymentHandler, router IPaymentRouter, sealer ISealer, securityCodeTTL time.Duration, idempotencyHashKey string, cbformat, whformat string) *Service {
 	return &Service{
 		log:             log,
 		walletRepo:      walletRepo,
 		transactionRepo: transactionRepo,
+		historyRepo:     historyRepo,
+		ledgerRepo:      ledgerRepo,
+		holdRepo:        holdRepo,
+		idempotencyRepo: idempotencyRepo,
+		callbackRepo:    callbackRepo,
+		transactor:      transactor,
 		paymentHandler:  paymenth,
+		paymentRouter:   router,
 		cbformat:        cbformat,
-		tranQueue:       queue.NewQueue[QueueItem](),
+		whformat:        whformat,
+		tranQueue:       tranQueue,
+		sealer:          sealer,
+		securityCodes:   newSecurityCodes(securityCodeTTL),
+		hashKey:         []byte(idempotencyHashKey),
 	}
 }
 
 // Deposit creates a new deposit transaction for the given wallet.
+// Requests with an idempotency key are only processed once.
 func (s *Service) Deposit(ctx context.Context, walletID uuid.UUID, req request.Deposit) (*models.Transaction, error) {
+	return s.idempotent(ctx, walletID, models.IdempotentOperationDeposit, req.IdempotencyKey, req, func(ctx context.Context) (*models.Transaction, error) {
+		return s.deposit(ctx, walletID, req)
+	})
+}
+
+func (s *Service) deposit(ctx context.Context, walletID uuid.UUID, req request.Deposit) (*models.Transaction, error) {
 	if err := errs.Check(req); err != nil {
 		return nil, err
 	}
 
+	routingReason, err := s.routePayment(ctx, models.TransactionTypeDeposit, &req.Payment, req.Amount, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+
 	paymentMethod, err := s.paymentHandler.VerifyMethod(req.Payment.Gateway, models.TransactionTypeDeposit, req.Payment.Method, req.Payment.MethodDetails)
 	if err != nil {
 		return nil, err
@@ -49,33 +85,59 @@ func (s *Service) Deposit(ctx context.Context, walletID uuid.UUID, req request.D
 		return nil, err
 	}
 
+	if err := s.verifyCurrency(wallet, req.Payment.Gateway, req.Amount, req.Currency); err != nil {
+		return nil, err
+	}
+
 	transaction := &models.Transaction{
 		ID:                   uuid.New(),
 		WalletID:             wallet.ID,
 		Amount:               req.Amount,
+		Currency:             wallet.Currency,
 		Status:               models.TransactionStatusCreated,
 		Type:                 models.TransactionTypeDeposit,
+		Direction:            models.PostingDirectionCredit,
 		PaymentGateway:       req.Payment.Gateway,
 		PaymentMethodDetails: paymentMethod.MaskRaw(),
 		PaymentMethod:        req.Payment.Method,
+		RoutingReason:        routingReason,
 	}
-
-	err = s.transactionRepo.Create(ctx, transaction)
-	if err != nil {
+	if err := s.sealPaymentDetails(transaction, paymentMethod); err != nil {
 		return nil, errs.New(errs.Internal, err)
 	}
 
-	s.enqueueTransaction(transaction.ID, paymentMethod.GetRaw())
+	err = s.transactor.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.createTransaction(ctx, transaction, "deposit requested"); err != nil {
+			return err
+		}
+		return s.enqueueTransaction(ctx, transaction)
+	})
+	if err != nil {
+		s.securityCodes.delete(transaction.ID)
+		return nil, errs.NewError(err)
+	}
 
 	return transaction, nil
 }
 
 // Withdraw creates a new withdrawal transaction for the given wallet.
+// Requests with an idempotency key are only processed once.
 func (s *Service) Withdraw(ctx context.Context, walletID uuid.UUID, req request.Withdraw) (*models.Transaction, error) {
+	return s.idempotent(ctx, walletID, models.IdempotentOperationWithdraw, req.IdempotencyKey, req, func(ctx context.Context) (*models.Transaction, error) {
+		return s.withdraw(ctx, walletID, req)
+	})
+}
+
+func (s *Service) withdraw(ctx context.Context, walletID uuid.UUID, req request.Withdraw) (*models.Transaction, error) {
 	if err := errs.Check(req); err != nil {
 		return nil, err
 	}
 
+	routingReason, err := s.routePayment(ctx, models.TransactionTypeWithdrawal, &req.Payment, req.Amount, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+
 	paymentMethod, err := s.paymentHandler.VerifyMethod(req.Payment.Gateway, models.TransactionTypeWithdrawal, req.Payment.Method, req.Payment.MethodDetails)
 	if err != nil {
 		return nil, err
@@ -86,66 +148,118 @@ func (s *Service) Withdraw(ctx context.Context, walletID uuid.UUID, req request.
 		return nil, err
 	}
 
+	if err := s.verifyCurrency(wallet, req.Payment.Gateway, req.Amount, req.Currency); err != nil {
+		return nil, err
+	}
+
 	transaction := &models.Transaction{
 		ID:                   uuid.New(),
 		WalletID:             wallet.ID,
 		Amount:               req.Amount,
+		Currency:             wallet.Currency,
 		Status:               models.TransactionStatusCreated,
 		Type:                 models.TransactionTypeWithdrawal,
+		Direction:            models.PostingDirectionDebit,
 		PaymentGateway:       req.Payment.Gateway,
 		PaymentMethodDetails: paymentMethod.MaskRaw(),
 		PaymentMethod:        req.Payment.Method,
+		RoutingReason:        routingReason,
+	}
+	if err := s.sealPaymentDetails(transaction, paymentMethod); err != nil {
+		return nil, errs.New(errs.Internal, err)
 	}
 
-	s.enqueueTransaction(transaction.ID, paymentMethod.GetRaw())
-
-	err = s.transactionRepo.Create(ctx, transaction)
+	err = s.transactor.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.createTransaction(ctx, transaction, "withdrawal requested"); err != nil {
+			return err
+		}
+		if err := s.placeHold(ctx, transaction); err != nil {
+			return err
+		}
+		return s.enqueueTransaction(ctx, transaction)
+	})
 	if err != nil {
-		return nil, errs.New(errs.Internal, err)
+		s.securityCodes.delete(transaction.ID)
+		return nil, errs.NewError(err)
 	}
 
 	return transaction, nil
 }
 
-// ProcessCallback processes the callback from the payment gateway.
-func (s *Service) ProcessCallback(ctx context.Context, walletID, tranID uuid.UUID, body []byte) error {
-	transaction, err := s.transactionRepo.GetByIDAndWalletID(ctx, tranID, walletID)
+// routePayment chooses the gateway of a payment whose client did not choose one, and
+// returns why it was chosen. It returns nil for a payment with a gateway.
+func (s *Service) routePayment(ctx context.Context, typ models.TransactionType, pay *request.Payment, amount money.Money, currency money.Currency) (*string, error) {
+	if pay.Gateway != "" {
+		return nil, nil
+	}
+
+	route, err := s.paymentRouter.Route(payment.RouteRequest{
+		Type:     typ,
+		Method:   pay.Method,
+		Amount:   amount,
+		Currency: currency,
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if transaction.Status != models.TransactionStatusPending || transaction.WalletID != walletID {
-		return errs.New(errs.InvalidArgument, errors.New("invalid request"))
+	s.log.Info(ctx, "Routed payment", "gateway", route.Gateway, "reason", route.Reason)
+	pay.Gateway = route.Gateway
+	return &route.Reason, nil
+}
+
+// verifyCurrency checks the request currency matches the wallet currency, that the
+// amount supports it, and that the payment gateway supports both.
+func (s *Service) verifyCurrency(wallet *models.Wallet, gateway models.PaymentGateway, amount money.Money, currency money.Currency) error {
+	if currency != wallet.Currency {
+		return errs.Newf(errs.InvalidArgument, "currency %s does not match wallet currency %s", currency, wallet.Currency)
 	}
 
-	var tranRefID string
-	if transaction.ReferenceID != nil {
-		tranRefID = *transaction.ReferenceID
+	if err := currency.CheckAmount(amount); err != nil {
+		return errs.New(errs.InvalidArgument, err)
 	}
 
-	res, err := s.paymentHandler.VerifyCallback(ctx, transaction.PaymentGateway, tranRefID, body)
+	return s.paymentHandler.VerifyAmount(gateway, amount, currency)
+}
+
+// applyPaymentStatus moves the transaction to the final status reported by its payment
+// gateway, through a callback or a status query. A pending status leaves it unchanged.
+func (s *Service) applyPaymentStatus(ctx context.Context, tran *models.Transaction, res *payment.Response, source models.TransitionSource, reason string) error {
+	status, err := toTransactionStatus(res.Status)
 	if err != nil {
-		return errs.New(errs.Internal, err)
+		return err
 	}
 
-	switch res.Status {
-	case payment.PaymentStatusSuccess:
-		transaction.Status = models.TransactionStatusCompleted
-	case payment.PaymentStatusFailed:
-		transaction.Status = models.TransactionStatusFailed
-	case payment.PaymentStatusPending:
+	if status == models.TransactionStatusPending {
 		return nil
-	case payment.PaymentStatusUnknown:
-		return errs.New(errs.InvalidArgument, errors.New("unknown payment status"))
 	}
 
-	err = s.transactionRepo.Update(ctx, transaction)
+	err = s.transition(ctx, tran, status, source, fmt.Sprintf("%s %s", reason, res.Status))
 	if err != nil {
-		return errs.New(errs.Internal, err)
+		return errs.NewError(err)
 	}
 	return nil
 }
 
+// toTransactionStatus maps a payment status reported by a payment gateway to the transaction status.
+func toTransactionStatus(status payment.PaymentStatus) (models.TransactionStatus, error) {
+	switch status {
+	case payment.PaymentStatusSuccess:
+		return models.TransactionStatusCompleted, nil
+	case payment.PaymentStatusFailed:
+		return models.TransactionStatusFailed, nil
+	case payment.PaymentStatusPending:
+		return models.TransactionStatusPending, nil
+	default:
+		return "", errs.New(errs.InvalidArgument, errors.New("unknown payment status"))
+	}
+}
+
+// PaymentGateways lists the payment gateways with their capabilities and circuit breaker state.
+func (s *Service) PaymentGateways(ctx context.Context) []payment.GatewayInfo {
+	return s.paymentHandler.Gateways()
+}
+
 // GetTransaction retrieves a transaction by its ID and wallet ID.
 func (s *Service) GetTransaction(ctx context.Context, id, walletID uuid.UUID) (*models.Transaction, error) {
 	tran, err := s.transactionRepo.GetByIDAndWalletID(ctx, id, walletID)
@@ -161,8 +275,13 @@ func (s *Service) Create(ctx context.Context, req request.CreateWallet) (*models
 		return nil, err
 	}
 
+	if err := req.Currency.Validate(); err != nil {
+		return nil, errs.New(errs.InvalidArgument, err)
+	}
+
 	wallet := &models.Wallet{
-		ID: uuid.New(),
+		ID:       uuid.New(),
+		Currency: req.Currency,
 	}
 
 	err := s.walletRepo.Create(ctx, wallet)
@@ -181,6 +300,20 @@ func (s *Service) ListTransactions(ctx context.Context, walletID uuid.UUID) ([]m
 	return tran, nil
 }
 
+// Get retrieves a wallet by its ID together with its balances.
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (*models.Wallet, error) {
+	wallet, err := s.walletRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet.Balance, err = s.balance(ctx, wallet)
+	if err != nil {
+		return nil, errs.New(errs.Internal, err)
+	}
+	return wallet, nil
+}
+
 // List retrieves all wallets.
 func (s *Service) List(ctx context.Context) ([]models.Wallet, error) {
 	wallets, err := s.walletRepo.List(ctx)
@@ -0,0 +1,26 @@
+package mid
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/3bd-dev/wallet-service/pkg/errs"
+	"github.com/3bd-dev/wallet-service/pkg/web"
+)
+
+// BearerToken rejects requests whose Authorization header does not carry the token.
+// An empty token rejects every request.
+func BearerToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if token == "" || !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				web.RenderErr(w, errs.Newf(errs.Unauthenticated, "missing or invalid bearer token"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	ContextKeyAfterCommit ContextKey = "after_commit"
+)
+
+// afterCommit collects the callbacks to run once a transaction commits.
+type afterCommit struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// WithAfterCommit returns a context collecting the callbacks registered with AfterCommit,
+// and a function running them. The function should be called once the transaction commits.
+func WithAfterCommit(ctx context.Context) (context.Context, func()) {
+	hooks := &afterCommit{}
+	run := func() {
+		hooks.mu.Lock()
+		fns := hooks.fns
+		hooks.fns = nil
+		hooks.mu.Unlock()
+
+		for _, fn := range fns {
+			fn()
+		}
+	}
+	return context.WithValue(ctx, ContextKeyAfterCommit, hooks), run
+}
+
+// AfterCommit runs fn once the transaction carried by the context commits.
+// fn runs immediately when the context carries no transaction.
+func AfterCommit(ctx context.Context, fn func()) {
+	hooks, ok := ctx.Value(ContextKeyAfterCommit).(*afterCommit)
+	if !ok || ctx.Value(ContextKeyDBTx) == nil {
+		fn()
+		return
+	}
+
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.fns = append(hooks.fns, fn)
+}
@@ -21,18 +21,43 @@ var (
 	// system has been broken. If you see one of these errors,
 	// something is very broken.
 	Internal = ErrCode{value: 3}
+
+	// InsufficientFunds means the wallet's available balance cannot cover
+	// the requested amount.
+	InsufficientFunds = ErrCode{value: 4}
+
+	// Conflict means the request conflicts with the current state of the
+	// resource (e.g., an idempotency key reused with a different request).
+	Conflict = ErrCode{value: 5}
+
+	// Unauthenticated means the request does not have valid credentials
+	// (e.g., a gateway callback with an invalid signature).
+	Unauthenticated = ErrCode{value: 6}
+
+	// Aborted means the operation was aborted by a concurrent update of the
+	// same resource (e.g., an optimistic locking conflict). It can be retried
+	// once the resource is read again.
+	Aborted = ErrCode{value: 7}
 )
 
 var codeNames = map[ErrCode]string{
-	OK:              "ok",
-	InvalidArgument: "invalid_argument",
-	NotFound:        "not_found",
-	Internal:        "internal",
+	OK:                "ok",
+	InvalidArgument:   "invalid_argument",
+	NotFound:          "not_found",
+	Internal:          "internal",
+	InsufficientFunds: "insufficient_funds",
+	Conflict:          "conflict",
+	Unauthenticated:   "unauthenticated",
+	Aborted:           "aborted",
 }
 
 var httpStatus = map[ErrCode]int{
-	OK:              http.StatusOK,
-	InvalidArgument: http.StatusBadRequest,
-	NotFound:        http.StatusNotFound,
-	Internal:        http.StatusInternalServerError,
+	OK:                http.StatusOK,
+	InvalidArgument:   http.StatusBadRequest,
+	NotFound:          http.StatusNotFound,
+	Internal:          http.StatusInternalServerError,
+	InsufficientFunds: http.StatusUnprocessableEntity,
+	Conflict:          http.StatusConflict,
+	Unauthenticated:   http.StatusUnauthorized,
+	Aborted:           http.StatusConflict,
 }
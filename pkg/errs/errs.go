@@ -78,6 +78,12 @@ func NewError(err error) *Error {
 	return New(Internal, err)
 }
 
+// HasCode reports whether err is, or wraps, an Error with the given code.
+func HasCode(err error, code ErrCode) bool {
+	var errsErr *Error
+	return errors.As(err, &errsErr) && errsErr.Code.Equal(code)
+}
+
 // Error implements the error interface.
 func (e *Error) Error() string {
 	return e.Message
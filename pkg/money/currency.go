@@ -0,0 +1,125 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedCurrency is returned for codes that are not supported ISO-4217 currencies.
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// Currency is an ISO-4217 alphabetic currency code.
+type Currency string
+
+const (
+	AED Currency = "AED"
+	AUD Currency = "AUD"
+	BHD Currency = "BHD"
+	CAD Currency = "CAD"
+	CHF Currency = "CHF"
+	CNY Currency = "CNY"
+	EGP Currency = "EGP"
+	EUR Currency = "EUR"
+	GBP Currency = "GBP"
+	INR Currency = "INR"
+	JOD Currency = "JOD"
+	JPY Currency = "JPY"
+	KRW Currency = "KRW"
+	KWD Currency = "KWD"
+	OMR Currency = "OMR"
+	SAR Currency = "SAR"
+	USD Currency = "USD"
+)
+
+// exponents holds the number of minor unit digits of each supported currency. It
+// never exceeds Scale.
+var exponents = map[Currency]int{
+	AED: 2,
+	AUD: 2,
+	BHD: 3,
+	CAD: 2,
+	CHF: 2,
+	CNY: 2,
+	EGP: 2,
+	EUR: 2,
+	GBP: 2,
+	INR: 2,
+	JOD: 3,
+	JPY: 0,
+	KRW: 0,
+	KWD: 3,
+	OMR: 3,
+	SAR: 2,
+	USD: 2,
+}
+
+// Validate checks the currency is a supported ISO-4217 code.
+func (c Currency) Validate() error {
+	if _, ok := exponents[c]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnsupportedCurrency, string(c))
+	}
+	return nil
+}
+
+// Exponent returns the number of minor unit digits of the currency.
+func (c Currency) Exponent() int {
+	return exponents[c]
+}
+
+// CheckAmount checks the amount has no more decimal places than the currency allows,
+// e.g. JPY amounts must be whole numbers.
+func (c Currency) CheckAmount(m Money) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	if m.Minor()%c.step() != 0 {
+		return fmt.Errorf("%w: %s has more than %d decimal places for %s", ErrInvalidAmount, m, c.Exponent(), c)
+	}
+	return nil
+}
+
+// ToMinorUnits returns the amount as an integer of the minor units of the currency,
+// e.g. cents for USD and yen for JPY, as some payment gateways expect amounts.
+func (c Currency) ToMinorUnits(m Money) (int64, error) {
+	if err := c.CheckAmount(m); err != nil {
+		return 0, err
+	}
+	return m.Minor() / c.step(), nil
+}
+
+// Format returns the amount as a decimal string with the decimal places of the
+// currency, e.g. "500" for JPY, "10.50" for USD and "1.005" for KWD, as payment
+// gateways expect amounts. An amount CheckAmount rejects keeps all its decimals.
+func (c Currency) Format(m Money) string {
+	if c.CheckAmount(m) != nil {
+		return m.String()
+	}
+
+	minor := m.Minor()
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+
+	units := minor / minorPerUnit
+	if c.Exponent() == 0 {
+		return fmt.Sprintf("%s%d", sign, units)
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, units, c.Exponent(), minor%minorPerUnit/c.step())
+}
+
+// step returns the number of Money minor units in one minor unit of the currency.
+func (c Currency) step() int64 {
+	step := int64(1)
+	for i := c.Exponent(); i < Scale; i++ {
+		step *= 10
+	}
+	return step
+}
+
+// String returns the currency code.
+func (c Currency) String() string {
+	return string(c)
+}
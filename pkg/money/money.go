@@ -0,0 +1,200 @@
+// Package money provides an exact decimal money type backed by integer units of
+// the smallest minor unit of the supported currencies.
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Scale is the number of decimal places kept by Money, the largest exponent of
+	// the supported currencies (e.g. KWD), matching the DECIMAL(18, 3) columns it is
+	// stored in. The exponent of the currency of an amount is enforced with
+	// Currency.CheckAmount.
+	Scale = 3
+
+	// displayScale is the minimum number of decimal places of a formatted amount.
+	displayScale = 2
+
+	// maxIntegerDigits keeps amounts within DECIMAL(18, 3) and int64 range.
+	maxIntegerDigits = 18 - Scale
+
+	minorPerUnit = 1000
+)
+
+// ErrInvalidAmount is returned when a value cannot be represented exactly as Money.
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// Money is an exact monetary amount expressed in thousandths of the currency unit,
+// so amounts of currencies with 0, 2 or 3 minor digits are all represented exactly.
+// It never goes through float64, so the value stored in the database is exactly the
+// value sent to the payment gateways.
+//
+// Money does not carry its currency. Every amount is stored next to the currency of
+// its wallet, transaction or hold, and amounts are only added or compared within one
+// wallet, whose currency deposits, withdrawals and transfers must match. Keeping it a
+// plain integer keeps that arithmetic and the DECIMAL columns simple. Use
+// Currency.CheckAmount to validate an amount for its currency, Currency.Format to
+// format it with the decimal places of the currency, and Currency.ToMinorUnits for
+// its minor units.
+type Money int64
+
+// FromMinor creates Money from an amount of thousandths of the currency unit.
+func FromMinor(minor int64) Money {
+	return Money(minor)
+}
+
+// Parse parses a decimal string such as "10", "10.5" or "-10.50".
+// Amounts with more than Scale significant decimal places are rejected.
+func Parse(s string) (Money, error) {
+	str := strings.TrimSpace(s)
+	neg := strings.HasPrefix(str, "-")
+	str = strings.TrimPrefix(str, "-")
+
+	intPart, fracPart, _ := strings.Cut(str, ".")
+	if intPart == "" || !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	intPart = strings.TrimLeft(intPart, "0")
+	if len(intPart) > maxIntegerDigits {
+		return 0, fmt.Errorf("%w: %q exceeds %d integer digits", ErrInvalidAmount, s, maxIntegerDigits)
+	}
+
+	if trimmed := strings.TrimRight(fracPart, "0"); len(trimmed) > Scale {
+		return 0, fmt.Errorf("%w: %q has more than %d decimal places", ErrInvalidAmount, s, Scale)
+	}
+	fracPart = (fracPart + strings.Repeat("0", Scale))[:Scale]
+
+	minor, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	if neg {
+		minor = -minor
+	}
+	return Money(minor), nil
+}
+
+// MustParse is like Parse but panics if the amount is invalid.
+func MustParse(s string) Money {
+	m, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Minor returns the amount in thousandths of the currency unit.
+func (m Money) Minor() int64 {
+	return int64(m)
+}
+
+// String returns the amount as a decimal string with two decimal places, or three
+// when the amount needs them, e.g. "10.50" and "1.005".
+func (m Money) String() string {
+	minor := int64(m)
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+
+	frac := fmt.Sprintf("%0*d", Scale, minor%minorPerUnit)
+	for len(frac) > displayScale && frac[len(frac)-1] == '0' {
+		frac = frac[:len(frac)-1]
+	}
+	return fmt.Sprintf("%s%d.%s", sign, minor/minorPerUnit, frac)
+}
+
+// MarshalJSON encodes the amount as a JSON number, see String.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON decodes the amount from a JSON number or string without
+// going through float64.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		data = []byte(s)
+	}
+
+	v, err := Parse(string(data))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, used by the XML encoder.
+func (m Money) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, used by the XML decoder.
+func (m *Money) UnmarshalText(text []byte) error {
+	v, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Value implements driver.Valuer and stores the amount as an exact decimal.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan implements sql.Scanner for DECIMAL columns.
+func (m *Money) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		// parsed rather than multiplied, so an integer too large for Money is rejected.
+		s = strconv.FormatInt(v, 10)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("money: cannot scan %T", src)
+	}
+
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,343 @@
+package money
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/3bd-dev/wallet-service/pkg/unitest"
+)
+
+func Test_Money(t *testing.T) {
+	t.Parallel()
+
+	unitest.Run(t, parse(), "parse")
+	unitest.Run(t, encode(), "encode")
+	unitest.Run(t, scan(), "scan")
+	unitest.Run(t, currencyAmount(), "currencyAmount")
+	unitest.Run(t, format(), "format")
+}
+
+func parse() []unitest.Table {
+	type result struct {
+		money Money
+		err   error
+	}
+
+	cmp := func(got any, exp any) string {
+		gotRes := got.(result)
+		expRes := exp.(result)
+		if !errors.Is(gotRes.err, expRes.err) {
+			return fmt.Sprintf("expected error %v, got %v", expRes.err, gotRes.err)
+		}
+		if gotRes.money != expRes.money {
+			return fmt.Sprintf("expected %d minor units, got %d", expRes.money, gotRes.money)
+		}
+		return ""
+	}
+
+	tests := []unitest.Table{}
+	for _, tc := range []struct {
+		in  string
+		exp result
+	}{
+		{in: "10", exp: result{money: 10000}},
+		{in: "10.5", exp: result{money: 10500}},
+		{in: "10.05", exp: result{money: 10050}},
+		{in: "0.01", exp: result{money: 10}},
+		{in: "-3.20", exp: result{money: -3200}},
+		{in: "19.990", exp: result{money: 19990}},
+		{in: "0.1", exp: result{money: 100}},
+		{in: "10.005", exp: result{money: 10005}},
+		{in: "10.0050", exp: result{money: 10005}},
+		{in: "10.0005", exp: result{err: ErrInvalidAmount}},
+		{in: "1e2", exp: result{err: ErrInvalidAmount}},
+		{in: ".5", exp: result{err: ErrInvalidAmount}},
+		{in: "abc", exp: result{err: ErrInvalidAmount}},
+		{in: "1234567890123456", exp: result{err: ErrInvalidAmount}},
+	} {
+		tc := tc
+		tests = append(tests, unitest.Table{
+			Name:    tc.in,
+			ExpResp: tc.exp,
+			ExcFunc: func(ctx context.Context) any {
+				m, err := Parse(tc.in)
+				return result{money: m, err: err}
+			},
+			CmpFunc: cmp,
+		})
+	}
+
+	return tests
+}
+
+func encode() []unitest.Table {
+	type payload struct {
+		XMLName xml.Name `json:"-" xml:"payload"`
+		Amount  Money    `json:"amount" xml:"amount"`
+	}
+
+	cmp := func(got any, exp any) string {
+		if got.(string) != exp.(string) {
+			return fmt.Sprintf("expected %s, got %s", exp, got)
+		}
+		return ""
+	}
+
+	tests := []unitest.Table{
+		{
+			Name:    "JSON Number",
+			ExpResp: `{"amount":10.10}`,
+			ExcFunc: func(ctx context.Context) any {
+				b, _ := json.Marshal(payload{Amount: MustParse("10.1")})
+				return string(b)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "JSON Three Decimals",
+			ExpResp: `{"amount":1.005}`,
+			ExcFunc: func(ctx context.Context) any {
+				b, _ := json.Marshal(payload{Amount: MustParse("1.005")})
+				return string(b)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "XML Text",
+			ExpResp: `<payload><amount>0.07</amount></payload>`,
+			ExcFunc: func(ctx context.Context) any {
+				b, _ := xml.Marshal(payload{Amount: MustParse("0.07")})
+				return string(b)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "JSON Round Trip",
+			ExpResp: "4011.29",
+			ExcFunc: func(ctx context.Context) any {
+				var p payload
+				if err := json.Unmarshal([]byte(`{"amount": 4011.29}`), &p); err != nil {
+					return err.Error()
+				}
+				return p.Amount.String()
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "JSON Too Many Decimals",
+			ExpResp: "true",
+			ExcFunc: func(ctx context.Context) any {
+				var p payload
+				err := json.Unmarshal([]byte(`{"amount": 0.0001}`), &p)
+				return fmt.Sprint(errors.Is(err, ErrInvalidAmount))
+			},
+			CmpFunc: cmp,
+		},
+	}
+
+	return tests
+}
+
+func scan() []unitest.Table {
+	cmp := func(got any, exp any) string {
+		if got.(Money) != exp.(Money) {
+			return fmt.Sprintf("expected %v, got %v", exp, got)
+		}
+		return ""
+	}
+
+	tests := []unitest.Table{
+		{
+			Name:    "Decimal Bytes",
+			ExpResp: Money(12340),
+			ExcFunc: func(ctx context.Context) any {
+				var m Money
+				m.Scan([]byte("12.34"))
+				return m
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Integer",
+			ExpResp: Money(5000),
+			ExcFunc: func(ctx context.Context) any {
+				var m Money
+				m.Scan(int64(5))
+				return m
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Integer Overflow",
+			ExpResp: ErrInvalidAmount,
+			ExcFunc: func(ctx context.Context) any {
+				var m Money
+				return m.Scan(int64(1) << 62)
+			},
+			CmpFunc: func(got any, exp any) string {
+				if err, _ := got.(error); !errors.Is(err, exp.(error)) {
+					return fmt.Sprintf("expected error %v, got %v", exp, got)
+				}
+				return ""
+			},
+		},
+	}
+
+	return tests
+}
+
+func currencyAmount() []unitest.Table {
+	cmp := func(got any, exp any) string {
+		gotErr, _ := got.(error)
+		expErr, _ := exp.(error)
+		if !errors.Is(gotErr, expErr) {
+			return fmt.Sprintf("expected error %v, got %v", expErr, gotErr)
+		}
+		return ""
+	}
+
+	tests := []unitest.Table{
+		{
+			Name:    "USD Cents",
+			ExpResp: nil,
+			ExcFunc: func(ctx context.Context) any {
+				return USD.CheckAmount(MustParse("10.99"))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "JPY Whole Units",
+			ExpResp: nil,
+			ExcFunc: func(ctx context.Context) any {
+				return JPY.CheckAmount(MustParse("1500"))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "JPY Fraction",
+			ExpResp: ErrInvalidAmount,
+			ExcFunc: func(ctx context.Context) any {
+				return JPY.CheckAmount(MustParse("1500.50"))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "KWD Fils",
+			ExpResp: nil,
+			ExcFunc: func(ctx context.Context) any {
+				return KWD.CheckAmount(MustParse("1.005"))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "USD Fraction Of Cent",
+			ExpResp: ErrInvalidAmount,
+			ExcFunc: func(ctx context.Context) any {
+				return USD.CheckAmount(MustParse("1.005"))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "Unknown Currency",
+			ExpResp: ErrUnsupportedCurrency,
+			ExcFunc: func(ctx context.Context) any {
+				return Currency("XYZ").CheckAmount(MustParse("1"))
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "USD Minor Units",
+			ExpResp: int64(1099),
+			ExcFunc: func(ctx context.Context) any {
+				return minorUnits(USD, MustParse("10.99"))
+			},
+			CmpFunc: cmpMinorUnits,
+		},
+		{
+			Name:    "JPY Minor Units",
+			ExpResp: int64(1500),
+			ExcFunc: func(ctx context.Context) any {
+				return minorUnits(JPY, MustParse("1500"))
+			},
+			CmpFunc: cmpMinorUnits,
+		},
+		{
+			Name:    "KWD Minor Units",
+			ExpResp: int64(1005),
+			ExcFunc: func(ctx context.Context) any {
+				return minorUnits(KWD, MustParse("1.005"))
+			},
+			CmpFunc: cmpMinorUnits,
+		},
+		{
+			Name:    "JPY Fraction Minor Units",
+			ExpResp: ErrInvalidAmount,
+			ExcFunc: func(ctx context.Context) any {
+				return minorUnits(JPY, MustParse("1500.50"))
+			},
+			CmpFunc: cmpMinorUnits,
+		},
+	}
+
+	return tests
+}
+
+func format() []unitest.Table {
+	cmp := func(got any, exp any) string {
+		if got != exp {
+			return fmt.Sprintf("expected %v, got %v", exp, got)
+		}
+		return ""
+	}
+
+	tests := []unitest.Table{}
+	for _, tc := range []struct {
+		currency Currency
+		in       string
+		exp      string
+	}{
+		{currency: USD, in: "10.5", exp: "10.50"},
+		{currency: USD, in: "-3.2", exp: "-3.20"},
+		{currency: JPY, in: "500", exp: "500"},
+		{currency: KWD, in: "1.005", exp: "1.005"},
+		{currency: KWD, in: "2", exp: "2.000"},
+		{currency: JPY, in: "500.5", exp: "500.50"},
+	} {
+		tc := tc
+		tests = append(tests, unitest.Table{
+			Name:    fmt.Sprintf("%s %s", tc.in, tc.currency),
+			ExpResp: tc.exp,
+			ExcFunc: func(ctx context.Context) any {
+				return tc.currency.Format(MustParse(tc.in))
+			},
+			CmpFunc: cmp,
+		})
+	}
+
+	return tests
+}
+
+func minorUnits(c Currency, m Money) any {
+	units, err := c.ToMinorUnits(m)
+	if err != nil {
+		return err
+	}
+	return units
+}
+
+func cmpMinorUnits(got any, exp any) string {
+	if expErr, ok := exp.(error); ok {
+		if gotErr, _ := got.(error); !errors.Is(gotErr, expErr) {
+			return fmt.Sprintf("expected error %v, got %v", expErr, got)
+		}
+		return ""
+	}
+	if got != exp {
+		return fmt.Sprintf("expected %v, got %v", exp, got)
+	}
+	return ""
+}
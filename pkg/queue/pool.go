@@ -0,0 +1,165 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Partitioned is implemented by queue items that belong to a partition, e.g. the
+// payment gateway of a transaction. Partitions with a limit are processed by at most
+// that many workers at a time, so a slow partition cannot starve the others.
+type Partitioned interface {
+	PartitionKey() string
+}
+
+// Identified is implemented by queue items that carry their own job ID. A durable
+// queue holds at most one job per ID, so enqueueing such an item again is a no-op
+// until its job is processed.
+type Identified interface {
+	JobID() uuid.UUID
+}
+
+// Owned is implemented by queue items that only the replica enqueueing them can process
+// for a while, e.g. because their job needs data that replica keeps in memory. Other
+// replicas claim the job once OwnedFor elapsed. Items owned for zero are not owned.
+type Owned interface {
+	OwnedFor() time.Duration
+}
+
+// partitionKey returns the partition of an item, or an empty string when the item is not partitioned.
+func partitionKey[T any](item T) string {
+	if p, ok := any(item).(Partitioned); ok {
+		return p.PartitionKey()
+	}
+	return ""
+}
+
+// PoolConfig holds the settings of the workers consuming a queue.
+type PoolConfig struct {
+	// Workers is the number of items processed concurrently, at least one.
+	Workers int
+	// Limits caps the number of items processed concurrently per partition.
+	// Partitions without a limit are only capped by Workers.
+	Limits map[string]int
+}
+
+// pool runs the workers of a queue. Idle workers sleep until an item is enqueued
+// or a partition slot is released.
+type pool struct {
+	workers int
+	limiter *limiter
+	wake    chan struct{}
+}
+
+func newPool(cfg PoolConfig) *pool {
+	workers := max(cfg.Workers, 1)
+	return &pool{
+		workers: workers,
+		limiter: newLimiter(cfg.Limits),
+		wake:    make(chan struct{}, workers),
+	}
+}
+
+// notify wakes up an idle worker, if any.
+func (p *pool) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// done releases the partition slot taken for an item and wakes up a worker,
+// which might have skipped items of that partition.
+func (p *pool) done(key string) {
+	p.limiter.release(key)
+	p.notify()
+}
+
+// run starts the workers. work processes a single item and reports whether it found one.
+// Workers without an item sleep until notified, or for at most poll when it is positive.
+func (p *pool) run(ctx context.Context, poll time.Duration, work func(context.Context) bool) {
+	for i := 0; i < p.workers; i++ {
+		go func() {
+			for ctx.Err() == nil {
+				if work(ctx) {
+					continue
+				}
+				p.wait(ctx, poll)
+			}
+		}()
+	}
+}
+
+func (p *pool) wait(ctx context.Context, poll time.Duration) {
+	var tick <-chan time.Time
+	if poll > 0 {
+		t := time.NewTimer(poll)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	select {
+	case <-p.wake:
+	case <-tick:
+	case <-ctx.Done():
+	}
+}
+
+// limiter counts the items processed per partition.
+type limiter struct {
+	mu      sync.Mutex
+	limits  map[string]int
+	running map[string]int
+	// picking is the number of picks in flight, whose partition is not known yet.
+	picking int
+}
+
+func newLimiter(limits map[string]int) *limiter {
+	return &limiter{
+		limits:  limits,
+		running: make(map[string]int),
+	}
+}
+
+// reserve calls pick with the partitions that cannot take another item, and takes a slot
+// in the partition of the picked item. It reports whether an item was picked.
+//
+// pick runs outside of the limiter lock, so workers claim items concurrently. Any pick in
+// flight could land in any partition, so a partition is saturated once its running items
+// plus the picks in flight reach its limit, which keeps concurrent workers from
+// exceeding a limit.
+func (l *limiter) reserve(pick func(saturated []string) (string, bool)) bool {
+	l.mu.Lock()
+	var saturated []string
+	for key, limit := range l.limits {
+		if l.running[key]+l.picking >= limit {
+			saturated = append(saturated, key)
+		}
+	}
+	l.picking++
+	l.mu.Unlock()
+
+	key, ok := pick(saturated)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.picking--
+	if ok {
+		l.running[key]++
+	}
+	return ok
+}
+
+// release frees the slot taken for an item of the partition.
+func (l *limiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.running[key] > 0 {
+		l.running[key]--
+	}
+}
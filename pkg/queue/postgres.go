@@ -0,0 +1,369 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/3bd-dev/wallet-service/pkg/database"
+	"github.com/3bd-dev/wallet-service/pkg/logger"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	jobStatusQueued     = "queued"
+	jobStatusProcessing = "processing"
+)
+
+// job is a row of the queue_jobs table.
+type job struct {
+	ID           uuid.UUID
+	Queue        string
+	PartitionKey string
+	Payload      json.RawMessage
+	Status       string
+	Attempts     int
+	RunAt        time.Time
+	LastError    *string
+	LockedUntil  *time.Time
+	Owner        *string
+	OwnedUntil   *time.Time
+	CreatedAt    time.Time
+}
+
+func (job) TableName() string {
+	return "queue_jobs"
+}
+
+// deadJob is a row of the queue_dead_letters table.
+type deadJob struct {
+	ID           uuid.UUID
+	Queue        string
+	PartitionKey string
+	Payload      json.RawMessage
+	Attempts     int
+	LastError    string
+	CreatedAt    time.Time
+	FailedAt     time.Time
+}
+
+func (deadJob) TableName() string {
+	return "queue_dead_letters"
+}
+
+// PostgresConfig holds the settings of a Postgres backed queue.
+type PostgresConfig struct {
+	// Name identifies the queue, several queues can share the jobs table.
+	Name string
+	// PollInterval is how long an idle worker waits before looking for jobs again.
+	PollInterval time.Duration
+	// LeaseTimeout is how long a claimed job stays invisible to other workers.
+	// Jobs of a crashed worker are claimed again once their lease expires.
+	LeaseTimeout time.Duration
+	// Pool configures the workers of this replica. Partition limits apply per replica.
+	Pool PoolConfig
+	// Retry controls how failed jobs are retried before they are dead-lettered.
+	Retry RetryPolicy
+	// Replica identifies this replica, it owns the jobs of the Owned items it enqueues.
+	// It must be unique per process, as a restarted replica lost the data it kept.
+	Replica string
+	// Log receives the failed job attempts and the errors of the workers.
+	Log *logger.Logger
+}
+
+// Postgres is a durable queue stored in the queue_jobs table. Jobs are written with
+// the database transaction carried by the context, so they can be enqueued atomically
+// with the rows they refer to (outbox pattern). Workers claim jobs with
+// SELECT ... FOR UPDATE SKIP LOCKED, which makes the queue safe to consume from
+// several service replicas.
+//
+// Workers wake up as soon as a job is committed by this replica, and poll the table
+// every PollInterval for jobs enqueued by other replicas, due for a retry or with
+// an expired lease. Jobs of Owned items are only claimed by the replica that enqueued
+// them, until their ownership expires. Jobs failing more than the retry policy allows are moved to the
+// queue_dead_letters table, from where they can be requeued.
+type Postgres[T any] struct {
+	db           database.IDatabase
+	cfg          PostgresConfig
+	pool         *pool
+	onDeadLetter func(context.Context, T, error) error
+}
+
+// NewPostgres creates a new Postgres queue instance
+func NewPostgres[T any](db database.IDatabase, cfg PostgresConfig) *Postgres[T] {
+	return &Postgres[T]{
+		db:   db,
+		cfg:  cfg,
+		pool: newPool(cfg.Pool),
+	}
+}
+
+// Enqueue stores an item in the queue, within the database transaction of the context if any.
+func (q *Postgres[T]) Enqueue(ctx context.Context, item T) error {
+	payload, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("queue: marshal item: %w", err)
+	}
+
+	id := uuid.New()
+	if i, ok := any(item).(Identified); ok {
+		id = i.JobID()
+	}
+
+	// an item with a job ID is only enqueued once, until its job is processed.
+	j := &job{
+		ID:           id,
+		Queue:        q.cfg.Name,
+		PartitionKey: partitionKey(item),
+		Payload:      payload,
+		Status:       jobStatusQueued,
+		RunAt:        time.Now(),
+	}
+	if o, ok := any(item).(Owned); ok && o.OwnedFor() > 0 && q.cfg.Replica != "" {
+		ownedUntil := j.RunAt.Add(o.OwnedFor())
+		j.Owner, j.OwnedUntil = &q.cfg.Replica, &ownedUntil
+	}
+
+	err = q.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(j).Error
+	if err != nil {
+		return err
+	}
+
+	// the job is only visible to workers once the transaction commits.
+	database.AfterCommit(ctx, q.pool.notify)
+	return nil
+}
+
+// dequeue claims the oldest due job outside of the excluded partitions, skipping the jobs
+// owned by other replicas, and counts the attempt.
+func (q *Postgres[T]) dequeue(ctx context.Context, excluded []string) (*job, bool, error) {
+	var claimed job
+
+	args := []any{jobStatusProcessing, q.cfg.LeaseTimeout.Seconds(), q.cfg.Name, jobStatusQueued, jobStatusProcessing, q.cfg.Replica}
+	partitionFilter := ""
+	if len(excluded) > 0 {
+		partitionFilter = "AND partition_key NOT IN ?"
+		args = append(args, excluded)
+	}
+
+	err := q.db.WithContext(ctx).Raw(`
+		UPDATE queue_jobs SET status = ?, attempts = attempts + 1, locked_until = NOW() + make_interval(secs => ?)
+		WHERE id = (
+			SELECT id FROM queue_jobs
+			WHERE queue = ? AND ((status = ? AND run_at <= NOW()) OR (status = ? AND locked_until < NOW()))
+				AND (owner IS NULL OR owner = ? OR owned_until < NOW()) `+partitionFilter+`
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, partition_key, payload, attempts`,
+		args...,
+	).Scan(&claimed).Error
+	if err != nil {
+		return nil, false, fmt.Errorf("queue: claim job: %w", err)
+	}
+
+	if claimed.ID == uuid.Nil {
+		return nil, false, nil
+	}
+	return &claimed, true, nil
+}
+
+// ack removes a processed job from the queue.
+func (q *Postgres[T]) ack(ctx context.Context, id uuid.UUID) error {
+	return q.db.WithContext(ctx).Where("id = ?", id).Delete(&job{}).Error
+}
+
+// fail schedules a retry of a failed job, or dead-letters it when the error is
+// permanent or the job ran out of attempts. item is nil when the payload of the job
+// could not be decoded.
+func (q *Postgres[T]) fail(ctx context.Context, j *job, item *T, cause error) error {
+	if IsPermanent(cause) || q.cfg.Retry.exhausted(j.Attempts) {
+		if item == nil || q.onDeadLetter == nil {
+			return q.deadLetter(ctx, j, cause)
+		}
+
+		// the job is retried until the dead letter handler succeeds.
+		err := q.onDeadLetter(ctx, *item, cause)
+		if err == nil {
+			return q.deadLetter(ctx, j, cause)
+		}
+		q.cfg.Log.Error(ctx, "Dead letter handler failed", "queue", q.cfg.Name, "job_id", j.ID, "error", err)
+		cause = fmt.Errorf("%w; dead letter handler: %w", cause, err)
+	}
+
+	return q.db.WithContext(ctx).Model(&job{}).Where("id = ?", j.ID).Updates(map[string]any{
+		"status":       jobStatusQueued,
+		"run_at":       time.Now().Add(q.cfg.Retry.Backoff(j.Attempts)),
+		"last_error":   cause.Error(),
+		"locked_until": nil,
+	}).Error
+}
+
+// deadLetter moves a failed job to the queue_dead_letters table.
+func (q *Postgres[T]) deadLetter(ctx context.Context, j *job, cause error) error {
+	return q.db.WithContext(ctx).Exec(`
+		WITH moved AS (
+			DELETE FROM queue_jobs WHERE id = ?
+			RETURNING id, queue, partition_key, payload, attempts, created_at
+		)
+		INSERT INTO queue_dead_letters (id, queue, partition_key, payload, attempts, last_error, created_at)
+		SELECT id, queue, partition_key, payload, attempts, ?, created_at FROM moved`,
+		j.ID, cause.Error(),
+	).Error
+}
+
+// OnDeadLetter sets a function called with the item of a job about to be dead-lettered,
+// e.g. to fail the work the item stands for. The job is retried instead while fn fails,
+// so fn must be safe to call again. It should be set before the workers start.
+func (q *Postgres[T]) OnDeadLetter(fn func(ctx context.Context, item T, cause error) error) {
+	q.onDeadLetter = fn
+}
+
+// StartWorker starts the workers processing items from the queue asynchronously.
+// Items for which processFunc returns an error are retried with exponential backoff.
+func (q *Postgres[T]) StartWorker(ctx context.Context, processFunc func(context.Context, T) error) {
+	q.pool.run(ctx, q.cfg.PollInterval, func(ctx context.Context) bool {
+		var (
+			claimed *job
+			err     error
+		)
+
+		ok := q.pool.limiter.reserve(func(saturated []string) (string, bool) {
+			var ok bool
+			claimed, ok, err = q.dequeue(ctx, saturated)
+			if !ok {
+				return "", false
+			}
+			return claimed.PartitionKey, true
+		})
+		if err != nil {
+			q.cfg.Log.Error(ctx, "Claiming job failed", "queue", q.cfg.Name, "error", err)
+		}
+		if !ok {
+			return false
+		}
+		defer q.pool.done(claimed.PartitionKey)
+
+		item := new(T)
+		if err = json.Unmarshal(claimed.Payload, item); err != nil {
+			item, err = nil, Permanent(fmt.Errorf("unmarshal job: %w", err))
+		} else {
+			err = processFunc(ctx, *item)
+		}
+
+		if err == nil {
+			err = q.ack(ctx, claimed.ID)
+		} else {
+			q.cfg.Log.Warn(ctx, "Job attempt failed", "queue", q.cfg.Name, "job_id", claimed.ID, "attempt", claimed.Attempts, "error", err)
+			err = q.fail(ctx, claimed, item, err)
+		}
+		if err != nil {
+			q.cfg.Log.Error(ctx, "Settling job failed", "queue", q.cfg.Name, "job_id", claimed.ID, "error", err)
+		}
+		return true
+	})
+}
+
+// DeadLetters lists the dead-lettered items of the queue, most recent first.
+func (q *Postgres[T]) DeadLetters(ctx context.Context) ([]DeadLetter[T], error) {
+	var jobs []deadJob
+	err := q.db.WithContext(ctx).Where("queue = ?", q.cfg.Name).Order("failed_at DESC").Find(&jobs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]DeadLetter[T], 0, len(jobs))
+	for _, j := range jobs {
+		letter, err := toDeadLetter[T](j)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+// DeadLetter retrieves a dead-lettered item of the queue by its ID.
+func (q *Postgres[T]) DeadLetter(ctx context.Context, id uuid.UUID) (*DeadLetter[T], error) {
+	var j deadJob
+	err := q.db.WithContext(ctx).Where("id = ? AND queue = ?", id, q.cfg.Name).First(&j).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	letter, err := toDeadLetter[T](j)
+	if err != nil {
+		return nil, err
+	}
+	return &letter, nil
+}
+
+// Requeue moves a dead-lettered item back to the queue with a fresh attempt count.
+func (q *Postgres[T]) Requeue(ctx context.Context, id uuid.UUID) error {
+	res := q.db.WithContext(ctx).Exec(`
+		WITH moved AS (
+			DELETE FROM queue_dead_letters WHERE id = ? AND queue = ?
+			RETURNING id, queue, partition_key, payload, created_at
+		)
+		INSERT INTO queue_jobs (id, queue, partition_key, payload, status, attempts, run_at, created_at)
+		SELECT id, queue, partition_key, payload, ?, 0, NOW(), created_at FROM moved`,
+		id, q.cfg.Name, jobStatusQueued,
+	)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	database.AfterCommit(ctx, q.pool.notify)
+	return nil
+}
+
+// Contains reports whether the queue holds a job with the given ID, either waiting,
+// being processed or dead-lettered.
+func (q *Postgres[T]) Contains(ctx context.Context, id uuid.UUID) (bool, error) {
+	var found bool
+	err := q.db.WithContext(ctx).Raw(`
+		SELECT EXISTS (SELECT 1 FROM queue_jobs WHERE id = ? AND queue = ?)
+			OR EXISTS (SELECT 1 FROM queue_dead_letters WHERE id = ? AND queue = ?)`,
+		id, q.cfg.Name, id, q.cfg.Name,
+	).Scan(&found).Error
+	return found, err
+}
+
+// DeadLettered reports whether the job with the given ID is dead-lettered.
+func (q *Postgres[T]) DeadLettered(ctx context.Context, id uuid.UUID) (bool, error) {
+	var found bool
+	err := q.db.WithContext(ctx).Raw(`SELECT EXISTS (SELECT 1 FROM queue_dead_letters WHERE id = ? AND queue = ?)`,
+		id, q.cfg.Name,
+	).Scan(&found).Error
+	return found, err
+}
+
+func toDeadLetter[T any](j deadJob) (DeadLetter[T], error) {
+	var item T
+	if err := json.Unmarshal(j.Payload, &item); err != nil {
+		return DeadLetter[T]{}, fmt.Errorf("queue: unmarshal dead letter %s: %w", j.ID, err)
+	}
+
+	return DeadLetter[T]{
+		ID:           j.ID,
+		Queue:        j.Queue,
+		PartitionKey: j.PartitionKey,
+		Item:         item,
+		Attempts:     j.Attempts,
+		LastError:    j.LastError,
+		EnqueuedAt:   j.CreatedAt,
+		FailedAt:     j.FailedAt,
+	}, nil
+}
@@ -0,0 +1,308 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/3bd-dev/wallet-service/pkg/unitest"
+)
+
+type item struct {
+	partition string
+}
+
+// jobs stands in for the queue_jobs table, claiming the oldest item outside of the excluded partitions.
+type jobs struct {
+	mu    sync.Mutex
+	items []item
+}
+
+func (j *jobs) add(it item) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.items = append(j.items, it)
+}
+
+func (j *jobs) claim(excluded []string) (item, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for i, it := range j.items {
+		if !slices.Contains(excluded, it.partition) {
+			j.items = slices.Delete(j.items, i, i+1)
+			return it, true
+		}
+	}
+	return item{}, false
+}
+
+// startWorkers consumes the jobs with the pool the way Postgres.StartWorker does.
+func startWorkers(ctx context.Context, p *pool, j *jobs, process func(item)) {
+	p.run(ctx, 0, func(ctx context.Context) bool {
+		var claimed item
+		ok := p.limiter.reserve(func(saturated []string) (string, bool) {
+			var ok bool
+			claimed, ok = j.claim(saturated)
+			return claimed.partition, ok
+		})
+		if !ok {
+			return false
+		}
+		defer p.done(claimed.partition)
+
+		process(claimed)
+		return true
+	})
+}
+
+func Test_Queue(t *testing.T) {
+	t.Parallel()
+
+	unitest.Run(t, workerPool(), "workerPool")
+	unitest.Run(t, reserve(), "reserve")
+	unitest.Run(t, backoff(), "backoff")
+}
+
+func backoff() []unitest.Table {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	cmp := func(got any, exp any) string {
+		if got.(time.Duration) != exp.(time.Duration) {
+			return fmt.Sprintf("expected %s, got %s", exp, got)
+		}
+		return ""
+	}
+
+	tests := []unitest.Table{}
+	for _, tc := range []struct {
+		attempts int
+		exp      time.Duration
+	}{
+		{attempts: 1, exp: time.Second},
+		{attempts: 2, exp: 2 * time.Second},
+		{attempts: 4, exp: 8 * time.Second},
+		{attempts: 5, exp: 10 * time.Second},
+		{attempts: 60, exp: 10 * time.Second},
+	} {
+		tests = append(tests, unitest.Table{
+			Name:    fmt.Sprintf("attempt %d", tc.attempts),
+			ExpResp: tc.exp,
+			ExcFunc: func(ctx context.Context) any {
+				return policy.Backoff(tc.attempts)
+			},
+			CmpFunc: cmp,
+		})
+	}
+	return tests
+}
+
+func workerPool() []unitest.Table {
+	type result struct {
+		processed map[string]int
+		maxSlow   int
+	}
+
+	cmp := func(got any, exp any) string {
+		gotRes := got.(result)
+		expRes := exp.(result)
+		if gotRes.maxSlow != expRes.maxSlow {
+			return fmt.Sprintf("expected at most %d concurrent slow items, got %d", expRes.maxSlow, gotRes.maxSlow)
+		}
+		for k, v := range expRes.processed {
+			if gotRes.processed[k] != v {
+				return fmt.Sprintf("expected %d %s items processed, got %d", v, k, gotRes.processed[k])
+			}
+		}
+		return ""
+	}
+
+	// run processes the items with three workers and a limit of one slow item at a time.
+	// Slow items block until all fast items are processed, so a limited partition must
+	// not keep the workers from the other partitions.
+	run := func(ctx context.Context, items []item) any {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		p := newPool(PoolConfig{Workers: 3, Limits: map[string]int{"slow": 1}})
+		j := &jobs{}
+
+		var (
+			mu        sync.Mutex
+			wg        sync.WaitGroup
+			fastDone  sync.WaitGroup
+			running   int
+			res       = result{processed: map[string]int{}}
+			fastCount int
+		)
+		for _, it := range items {
+			if it.partition == "fast" {
+				fastCount++
+			}
+		}
+		wg.Add(len(items))
+		fastDone.Add(fastCount)
+
+		startWorkers(ctx, p, j, func(it item) {
+			defer wg.Done()
+
+			mu.Lock()
+			res.processed[it.partition]++
+			if it.partition == "slow" {
+				running++
+				res.maxSlow = max(res.maxSlow, running)
+			}
+			mu.Unlock()
+
+			if it.partition == "fast" {
+				fastDone.Done()
+				return
+			}
+
+			fastDone.Wait()
+			mu.Lock()
+			running--
+			mu.Unlock()
+		})
+
+		// enqueue after the workers started, so they have to be woken up.
+		for _, it := range items {
+			j.add(it)
+			p.notify()
+		}
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		return res
+	}
+
+	return []unitest.Table{
+		{
+			Name: "fast items are not starved by a limited partition",
+			ExpResp: result{
+				processed: map[string]int{"slow": 3, "fast": 2},
+				maxSlow:   1,
+			},
+			ExcFunc: func(ctx context.Context) any {
+				return run(ctx, []item{{"slow"}, {"slow"}, {"slow"}, {"fast"}, {"fast"}})
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name: "unpartitioned items",
+			ExpResp: result{
+				processed: map[string]int{"": 4},
+			},
+			ExcFunc: func(ctx context.Context) any {
+				return run(ctx, []item{{""}, {""}, {""}, {""}})
+			},
+			CmpFunc: cmp,
+		},
+	}
+}
+
+func reserve() []unitest.Table {
+	cmp := func(got any, exp any) string {
+		if fmt.Sprint(got) != fmt.Sprint(exp) {
+			return fmt.Sprintf("expected %v, got %v", exp, got)
+		}
+		return ""
+	}
+
+	// nested reserves a slot of partition a, then reserves again from within a pick in
+	// flight, which would deadlock if picks ran under the limiter lock. It returns the
+	// partitions the nested pick was told are saturated.
+	nested := func(limit int) []string {
+		l := newLimiter(map[string]int{"a": limit})
+		l.reserve(func([]string) (string, bool) { return "a", true })
+
+		var saturated []string
+		l.reserve(func([]string) (string, bool) {
+			l.reserve(func(s []string) (string, bool) {
+				saturated = s
+				return "", false
+			})
+			return "a", true
+		})
+		return saturated
+	}
+
+	return []unitest.Table{
+		{
+			Name:    "picks in flight count against the limit",
+			ExpResp: []string{"a"},
+			ExcFunc: func(ctx context.Context) any {
+				return nested(2)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "partition below its limit",
+			ExpResp: []string(nil),
+			ExcFunc: func(ctx context.Context) any {
+				return nested(3)
+			},
+			CmpFunc: cmp,
+		},
+		{
+			Name:    "workers pick concurrently",
+			ExpResp: 3,
+			ExcFunc: func(ctx context.Context) any {
+				l := newLimiter(nil)
+
+				var (
+					mu      sync.Mutex
+					picking int
+					most    int
+					wg      sync.WaitGroup
+				)
+				for i := 0; i < 3; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						l.reserve(func([]string) (string, bool) {
+							mu.Lock()
+							picking++
+							most = max(most, picking)
+							mu.Unlock()
+
+							// wait for the other picks, as a slow claim query would.
+							deadline := time.Now().Add(time.Second)
+							for time.Now().Before(deadline) {
+								mu.Lock()
+								all := most == 3
+								mu.Unlock()
+								if all {
+									break
+								}
+								time.Sleep(time.Millisecond)
+							}
+
+							mu.Lock()
+							picking--
+							mu.Unlock()
+							return "", false
+						})
+					}()
+				}
+				wg.Wait()
+				return most
+			},
+			CmpFunc: cmp,
+		},
+	}
+}
@@ -0,0 +1,82 @@
+package queue
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned when a dead letter does not exist.
+var ErrNotFound = errors.New("queue: item not found")
+
+// RetryPolicy controls how items whose processing failed are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times an item is processed before it is dead-lettered.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubled for every further retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries.
+	MaxDelay time.Duration
+}
+
+// exhausted reports whether an item processed attempts times should not be retried anymore.
+func (p RetryPolicy) exhausted(attempts int) bool {
+	return attempts >= max(p.MaxAttempts, 1)
+}
+
+// Backoff returns the delay before retrying an item processed attempts times.
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+
+	if p.MaxDelay > 0 {
+		return min(delay, p.MaxDelay)
+	}
+	return delay
+}
+
+// permanentError marks an error that retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps an error returned by a process function so the item is
+// dead-lettered right away instead of being retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether the error was marked with Permanent.
+func IsPermanent(err error) bool {
+	var perr *permanentError
+	return errors.As(err, &perr)
+}
+
+// DeadLetter is an item whose processing failed for good.
+type DeadLetter[T any] struct {
+	ID           uuid.UUID `json:"id"`
+	Queue        string    `json:"queue"`
+	PartitionKey string    `json:"partition_key"`
+	Item         T         `json:"item"`
+	Attempts     int       `json:"attempts"`
+	LastError    string    `json:"last_error"`
+	EnqueuedAt   time.Time `json:"enqueued_at"`
+	FailedAt     time.Time `json:"failed_at"`
+}
@@ -124,7 +124,7 @@ func (c *Client) do(ctx context.Context, method, reqURL string, body interface{}
 	contentType := JSONContentType
 	var err error
 
-	if options != nil {
+	if options != nil && options.Headers.Get("Content-Type") != "" {
 		contentType = options.Headers.Get("Content-Type")
 	}
 
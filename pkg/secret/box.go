@@ -0,0 +1,71 @@
+// Package secret protects sensitive values stored in the database with a key held
+// outside of it, so a copy of the database alone does not reveal them.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the size of the keys of a Box, in bytes.
+const KeySize = 32
+
+// ErrOpen is returned when a sealed value was tampered with, or sealed with another
+// key or additional data.
+var ErrOpen = errors.New("secret: message authentication failed")
+
+// Box encrypts and authenticates values with AES-256-GCM.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// NewBox creates a box from a base64 encoded key of KeySize bytes.
+func NewBox(key string) (*Box, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("secret: decode key: %w", err)
+	}
+	if len(raw) != KeySize {
+		return nil, fmt.Errorf("secret: key must be %d bytes, got %d", KeySize, len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts the plaintext with a random nonce, prepended to the result. The
+// additional data, e.g. the ID of the row the value belongs to, is authenticated but
+// not encrypted, so a sealed value copied to another row does not open.
+func (b *Box) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize(), b.aead.NonceSize()+len(plaintext)+b.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("secret: generate nonce: %w", err)
+	}
+	return b.aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open decrypts a value sealed with the same key and additional data.
+func (b *Box) Open(sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < b.aead.NonceSize() {
+		return nil, ErrOpen
+	}
+
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrOpen
+	}
+	return plaintext, nil
+}
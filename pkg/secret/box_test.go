@@ -0,0 +1,132 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/3bd-dev/wallet-service/pkg/unitest"
+)
+
+func Test_Box(t *testing.T) {
+	t.Parallel()
+
+	unitest.Run(t, newBox(), "newBox")
+	unitest.Run(t, sealOpen(), "sealOpen")
+}
+
+var testKey = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, KeySize))
+
+func newBox() []unitest.Table {
+	cmp := func(got any, exp any) string {
+		if (got == nil) != (exp == nil) {
+			return fmt.Sprintf("expected error %v, got %v", exp, got)
+		}
+		return ""
+	}
+
+	tests := []unitest.Table{}
+	for _, tc := range []struct {
+		name   string
+		key    string
+		expErr bool
+	}{
+		{name: "valid", key: testKey},
+		{name: "not base64", key: "not a key!", expErr: true},
+		{name: "short", key: base64.StdEncoding.EncodeToString([]byte("short")), expErr: true},
+	} {
+		var exp any
+		if tc.expErr {
+			exp = errors.New("invalid key")
+		}
+		tests = append(tests, unitest.Table{
+			Name:    tc.name,
+			ExpResp: exp,
+			ExcFunc: func(ctx context.Context) any {
+				if _, err := NewBox(tc.key); err != nil {
+					return err
+				}
+				return nil
+			},
+			CmpFunc: cmp,
+		})
+	}
+	return tests
+}
+
+func sealOpen() []unitest.Table {
+	type result struct {
+		plaintext string
+		err       error
+	}
+
+	cmp := func(got any, exp any) string {
+		gotRes := got.(result)
+		expRes := exp.(result)
+		if !errors.Is(gotRes.err, expRes.err) {
+			return fmt.Sprintf("expected error %v, got %v", expRes.err, gotRes.err)
+		}
+		if gotRes.plaintext != expRes.plaintext {
+			return fmt.Sprintf("expected %q, got %q", expRes.plaintext, gotRes.plaintext)
+		}
+		return ""
+	}
+
+	box, err := NewBox(testKey)
+	if err != nil {
+		panic(err)
+	}
+	otherKey, err := NewBox(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{8}, KeySize)))
+	if err != nil {
+		panic(err)
+	}
+
+	tests := []unitest.Table{}
+	for _, tc := range []struct {
+		name   string
+		tamper func(sealed []byte) []byte
+		opener *Box
+		ad     string
+		exp    result
+	}{
+		{name: "round trip", ad: "row-1", exp: result{plaintext: "4111111111111111"}},
+		{name: "other additional data", ad: "row-2", exp: result{err: ErrOpen}},
+		{name: "other key", opener: otherKey, ad: "row-1", exp: result{err: ErrOpen}},
+		{name: "tampered", ad: "row-1", tamper: func(sealed []byte) []byte {
+			sealed[len(sealed)-1] ^= 1
+			return sealed
+		}, exp: result{err: ErrOpen}},
+		{name: "truncated", ad: "row-1", tamper: func(sealed []byte) []byte {
+			return sealed[:4]
+		}, exp: result{err: ErrOpen}},
+	} {
+		tests = append(tests, unitest.Table{
+			Name:    tc.name,
+			ExpResp: tc.exp,
+			ExcFunc: func(ctx context.Context) any {
+				sealed, err := box.Seal([]byte("4111111111111111"), []byte("row-1"))
+				if err != nil {
+					return result{err: err}
+				}
+				if bytes.Contains(sealed, []byte("4111111111111111")) {
+					return result{err: errors.New("sealed value contains the plaintext")}
+				}
+				if tc.tamper != nil {
+					sealed = tc.tamper(sealed)
+				}
+
+				opener := box
+				if tc.opener != nil {
+					opener = tc.opener
+				}
+				plaintext, err := opener.Open(sealed, []byte(tc.ad))
+				return result{plaintext: string(plaintext), err: err}
+			},
+			CmpFunc: cmp,
+		})
+	}
+	return tests
+}